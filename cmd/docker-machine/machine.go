@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"path/filepath"
 
@@ -22,6 +23,7 @@ import (
 	"github.com/leoh0/machine/libmachine/drivers/plugin"
 	"github.com/leoh0/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/persist"
 	"github.com/leoh0/machine/version"
 	"github.com/urfave/cli"
 )
@@ -112,6 +114,30 @@ func main() {
 			Value:  mcndirs.GetBaseDir(),
 			Usage:  "Configures storage path",
 		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_STORAGE_DRIVER",
+			Name:   "storage-driver",
+			Value:  persist.FilestoreDriver,
+			Usage:  fmt.Sprintf("Storage driver for machine configurations: [%s, %s]", persist.FilestoreDriver, persist.BoltstoreDriver),
+		},
+		cli.IntFlag{
+			EnvVar: "MACHINE_LOCK_TIMEOUT",
+			Name:   "lock-timeout",
+			Value:  int(persist.DefaultLockTimeout / time.Second),
+			Usage:  "Seconds to wait for another docker-machine process to release a lock on the store",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_OUTPUT",
+			Name:   "output",
+			Value:  "text",
+			Usage:  "Output format of the commands: [text, json]",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_LOG_FORMAT",
+			Name:   "log-format",
+			Value:  "text",
+			Usage:  "Format of the log messages: [text, json]",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_TLS_CA_CERT",
 			Name:   "tls-ca-cert",
@@ -136,6 +162,17 @@ func main() {
 			Usage:  "Private key used in client TLS auth",
 			Value:  "",
 		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_TLS_SIGNER",
+			Name:   "tls-signer",
+			Usage:  "Helper binary signing the certificates of new machines, instead of the CA key",
+			Value:  "",
+		},
+		cli.IntFlag{
+			EnvVar: "MACHINE_AUTO_ROTATE_DAYS",
+			Name:   "auto-rotate-days",
+			Usage:  "Regenerate the server certificate of a machine seen by ls or env when it expires in less than this many days (0 to disable)",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_GITHUB_API_TOKEN",
 			Name:   "github-api-token",
@@ -147,6 +184,11 @@ func main() {
 			Name:   "native-ssh",
 			Usage:  "Use the native (Go-based) SSH implementation.",
 		},
+		cli.BoolFlag{
+			EnvVar: "MACHINE_NO_SSH_REUSE",
+			Name:   "no-ssh-reuse",
+			Usage:  "Open a new SSH connection for every command run on a machine instead of sharing one.",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_BUGSNAG_API_TOKEN",
 			Name:   "bugsnag-api-token",
@@ -20,6 +20,11 @@ var (
 	errActiveTimeout = errors.New("Error getting active host: timeout")
 )
 
+// ActiveResult is the result of the active command.
+type ActiveResult struct {
+	Name string `json:"name"`
+}
+
 func cmdActive(c CommandLine, api libmachine.API) error {
 	if len(c.Args()) > 0 {
 		return ErrTooManyArguments
@@ -39,7 +44,11 @@ func cmdActive(c CommandLine, api libmachine.API) error {
 		return err
 	}
 
-	fmt.Println(active.Name)
+	output.printResult(ActiveResult{
+		Name: active.Name,
+	}, func() {
+		fmt.Println(active.Name)
+	})
 	return nil
 }
 
@@ -0,0 +1,575 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/drivers/rpcdriver"
+	"github.com/leoh0/machine/libmachine/engine"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/mcnerror"
+	"github.com/leoh0/machine/libmachine/mcnflag"
+	"github.com/leoh0/machine/libmachine/persist"
+	"github.com/leoh0/machine/libmachine/swarm"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	applyCreate      = "create"
+	applyReprovision = "reprovision"
+	applyRemove      = "remove"
+	applyUnchanged   = "unchanged"
+	applyError       = "error"
+)
+
+var (
+	errNoManifest = errors.New("Error: A manifest must be given with --file")
+
+	planWriter io.Writer = os.Stdout
+)
+
+// Manifest describes the machines 'apply' converges the store to.
+type Manifest struct {
+	Machines []MachineSpec `json:"machines"`
+}
+
+// MachineSpec is the desired state of a single machine. Engine and Swarm
+// hold engine.Options and swarm.Options fields; the ones left out keep their
+// current value, or the 'create' default for a new machine. DriverOptions
+// are keyed by driver flag name and are only used when creating the machine.
+type MachineSpec struct {
+	Name          string                 `json:"name"`
+	Driver        string                 `json:"driver"`
+	DriverOptions map[string]interface{} `json:"driverOptions"`
+	Engine        json.RawMessage        `json:"engine"`
+	Swarm         json.RawMessage        `json:"swarm"`
+	Labels        map[string]string      `json:"labels"`
+}
+
+type applyStep struct {
+	Action        string
+	Name          string
+	DriverName    string
+	Reason        string
+	spec          *MachineSpec
+	engineOptions *engine.Options
+	swarmOptions  *swarm.Options
+	err           error
+}
+
+// ApplyPlanResult is the result of the apply --dry-run command for one
+// machine of the manifest, or of the store with --prune.
+type ApplyPlanResult struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Driver string `json:"driver"`
+	Reason string `json:"reason"`
+}
+
+func cmdApply(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 0 {
+		return ErrTooManyArguments
+	}
+
+	if c.String("file") == "" {
+		c.ShowHelp()
+		return errNoManifest
+	}
+
+	manifest, err := readManifest(c.String("file"))
+	if err != nil {
+		return err
+	}
+
+	steps, err := planApply(api, manifest, c.Bool("prune"))
+	if err != nil {
+		return err
+	}
+
+	// In --output json mode, the plan is the result of a dry run. Once
+	// applied, the outcome on each machine is the result instead.
+	planResults := []ApplyPlanResult{}
+	for _, step := range steps {
+		planResults = append(planResults, ApplyPlanResult{
+			Name:   step.Name,
+			Action: step.Action,
+			Driver: step.DriverName,
+			Reason: step.Reason,
+		})
+	}
+
+	var planErr error
+	output.printResult(planResults, func() {
+		planErr = printPlan(planWriter, steps)
+	})
+	if planErr != nil {
+		return planErr
+	}
+
+	if c.Bool("dry-run") {
+		return nil
+	}
+
+	if removals := stepsOf(steps, applyRemove); len(removals) > 0 {
+		log.Warnf("The machines %s will be removed.", strings.Join(removals, ", "))
+		if !userConfirm(c.Bool("y"), c.Bool("force")) {
+			return nil
+		}
+	}
+
+	results := []machineActionResult{}
+	for _, step := range steps {
+		if step.Action == applyUnchanged {
+			continue
+		}
+
+		if step.Action == applyError {
+			results = append(results, machineActionResult{
+				Name: step.Name,
+				Err:  fmt.Errorf("Error loading host %q: %s", step.Name, step.err),
+			})
+			continue
+		}
+
+		log.Infof("Applying %s of %q...", step.Action, step.Name)
+		results = append(results, machineActionResult{
+			Name: step.Name,
+			Err:  executeApplyStep(c, api, step),
+		})
+	}
+
+	if len(results) == 0 {
+		log.Info("All machines are up to date.")
+		return nil
+	}
+
+	return summarizeActionResults("apply", results)
+}
+
+func readManifest(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading manifest: %s", err)
+	}
+
+	return parseManifest(data)
+}
+
+// parseManifest reads a YAML or JSON manifest. YAML documents are converted
+// to JSON first, so both formats follow the JSON field names of the
+// engine.Options and swarm.Options structs.
+func parseManifest(data []byte) (*Manifest, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("Error parsing manifest: %s", err)
+	}
+
+	jsonData, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing manifest: %s", err)
+	}
+
+	manifest := &Manifest{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(manifest); err != nil {
+		return nil, fmt.Errorf("Error parsing manifest: %s", err)
+	}
+
+	names := map[string]bool{}
+	for _, spec := range manifest.Machines {
+		if !host.ValidateHostName(spec.Name) {
+			return nil, fmt.Errorf("Error in manifest for machine %q: %w", spec.Name, mcnerror.ErrInvalidHostname)
+		}
+		if spec.Driver == "" {
+			return nil, fmt.Errorf("Error in manifest for machine %q: no driver specified", spec.Name)
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("Error in manifest: machine %q is described more than once", spec.Name)
+		}
+		names[spec.Name] = true
+	}
+
+	return manifest, nil
+}
+
+// planApply compares the manifest with the store and returns the steps
+// needed to converge. Machines missing from the manifest are only removed
+// if prune is set.
+func planApply(api libmachine.API, manifest *Manifest, prune bool) ([]*applyStep, error) {
+	steps := []*applyStep{}
+	wanted := map[string]bool{}
+
+	for i := range manifest.Machines {
+		spec := &manifest.Machines[i]
+		wanted[spec.Name] = true
+
+		exists, err := api.Exists(spec.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Error checking if host exists: %s", err)
+		}
+
+		step := &applyStep{
+			Name:       spec.Name,
+			DriverName: spec.Driver,
+			spec:       spec,
+		}
+
+		if !exists {
+			step.Action = applyCreate
+			if err := desiredOptions(step, spec, defaultEngineOptions(), defaultSwarmOptions()); err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+			continue
+		}
+
+		h, err := api.Load(spec.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if h.DriverName != spec.Driver {
+			return nil, fmt.Errorf("Error: machine %q uses the %s driver but the manifest asks for %s, remove it first", spec.Name, h.DriverName, spec.Driver)
+		}
+
+		var currentEngine *engine.Options
+		var currentSwarm *swarm.Options
+		if h.HostOptions != nil {
+			currentEngine = h.HostOptions.EngineOptions
+			currentSwarm = h.HostOptions.SwarmOptions
+		}
+		if currentEngine == nil {
+			currentEngine = defaultEngineOptions()
+		}
+		if currentSwarm == nil {
+			currentSwarm = defaultSwarmOptions()
+		}
+
+		if err := desiredOptions(step, spec, currentEngine, currentSwarm); err != nil {
+			return nil, err
+		}
+
+		step.Action = applyUnchanged
+
+		changed := []string{}
+		if !sameOptions(currentEngine, step.engineOptions) {
+			changed = append(changed, "engine")
+		}
+		if !sameOptions(currentSwarm, step.swarmOptions) {
+			changed = append(changed, "swarm")
+		}
+		if len(changed) > 0 {
+			step.Action = applyReprovision
+			step.Reason = fmt.Sprintf("%s options changed", strings.Join(changed, " and "))
+		}
+
+		steps = append(steps, step)
+	}
+
+	if prune {
+		hosts, hostsInError, err := persist.LoadAllHosts(api)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, h := range hosts {
+			if !wanted[h.Name] {
+				steps = append(steps, &applyStep{
+					Action:     applyRemove,
+					Name:       h.Name,
+					DriverName: h.DriverName,
+					Reason:     "not in manifest",
+				})
+			}
+		}
+
+		// The machines which cannot be loaded are neither removed nor
+		// left out silently.
+		for _, failure := range loadFailures(hostsInError) {
+			if !wanted[failure.Name] {
+				steps = append(steps, &applyStep{
+					Action: applyError,
+					Name:   failure.Name,
+					Reason: strings.Replace(failure.Err.Error(), "\n", " ", -1),
+					err:    failure.Err,
+				})
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+// stepsOf returns the names of the machines of steps with the given action.
+func stepsOf(steps []*applyStep, action string) []string {
+	names := []string{}
+	for _, step := range steps {
+		if step.Action == action {
+			names = append(names, step.Name)
+		}
+	}
+	return names
+}
+
+// defaultEngineOptions and defaultSwarmOptions are the options 'create'
+// gives a machine when its flags are left out.
+func defaultEngineOptions() *engine.Options {
+	return &engine.Options{
+		TLSVerify:  true,
+		InstallURL: createFlagDefault("engine-install-url"),
+	}
+}
+
+func defaultSwarmOptions() *swarm.Options {
+	return &swarm.Options{
+		Host:     createFlagDefault("swarm-host"),
+		Image:    createFlagDefault("swarm-image"),
+		Strategy: createFlagDefault("swarm-strategy"),
+	}
+}
+
+// createFlagDefault returns the default value of the 'create' string flag
+// with the given name.
+func createFlagDefault(name string) string {
+	for _, f := range SharedCreateFlags {
+		if flag, ok := f.(cli.StringFlag); ok && flag.Name == name {
+			return flag.Value
+		}
+	}
+	return ""
+}
+
+// desiredOptions overlays the options of the spec on top of the current
+// ones.
+func desiredOptions(step *applyStep, spec *MachineSpec, currentEngine *engine.Options, currentSwarm *swarm.Options) error {
+	step.engineOptions = &engine.Options{}
+	if err := overlayOptions(currentEngine, spec.Engine, step.engineOptions); err != nil {
+		return fmt.Errorf("Error in engine options of machine %q: %s", spec.Name, err)
+	}
+
+	if spec.Labels != nil {
+		labels := []string{}
+		for k, v := range spec.Labels {
+			labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+		}
+		sort.Strings(labels)
+		step.engineOptions.Labels = labels
+	}
+
+	step.swarmOptions = &swarm.Options{}
+	if err := overlayOptions(currentSwarm, spec.Swarm, step.swarmOptions); err != nil {
+		return fmt.Errorf("Error in swarm options of machine %q: %s", spec.Name, err)
+	}
+	step.swarmOptions.IsSwarm = step.swarmOptions.Agent || step.swarmOptions.Master
+
+	return nil
+}
+
+func overlayOptions(current interface{}, overlay json.RawMessage, out interface{}) error {
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return err
+	}
+
+	if len(overlay) == 0 || string(overlay) == "null" {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(overlay))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(out)
+}
+
+func sameOptions(a, b interface{}) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+func printPlan(w io.Writer, steps []*applyStep) error {
+	tw := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tNAME\tDRIVER\tREASON")
+
+	for _, step := range steps {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", step.Action, step.Name, step.DriverName, step.Reason)
+	}
+
+	return tw.Flush()
+}
+
+func executeApplyStep(c CommandLine, api libmachine.API, step *applyStep) error {
+	if step.Action != applyRemove {
+		unlock, err := persist.LockMachines(api, []string{step.Name})
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	switch step.Action {
+	case applyCreate:
+		return applyCreateMachine(c, api, step)
+	case applyReprovision:
+		// The machine is loaded again once locked, in case it changed
+		// since the plan.
+		h, err := api.Load(step.Name)
+		if err != nil {
+			return err
+		}
+
+		if h.HostOptions == nil {
+			h.HostOptions = &host.Options{}
+		}
+		h.HostOptions.EngineOptions = step.engineOptions
+		h.HostOptions.SwarmOptions = step.swarmOptions
+
+		// Provisioning sets the swarm mode join tokens, which are saved
+		// with the options.
+		if err := h.Provision(); err != nil {
+			return err
+		}
+		if err := api.Save(h); err != nil {
+			return fmt.Errorf("Error saving host to store: %w", err)
+		}
+		return nil
+	case applyRemove:
+		if err := removeRemoteMachine(step.Name, api); err != nil {
+			return fmt.Errorf("Error removing host %q: %s", step.Name, err)
+		}
+		return removeLocalMachine(step.Name, api)
+	}
+
+	return nil
+}
+
+func applyCreateMachine(c CommandLine, api libmachine.API, step *applyStep) error {
+	// The machine may have been created since the plan.
+	exists, err := api.Exists(step.Name)
+	if err != nil {
+		return fmt.Errorf("Error checking if host exists: %s", err)
+	}
+	if exists {
+		return mcnerror.ErrHostAlreadyExists{
+			Name: step.Name,
+		}
+	}
+
+	rawDriver, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: step.Name,
+		StorePath:   c.GlobalString("storage-path"),
+	})
+	if err != nil {
+		return fmt.Errorf("Error attempting to marshal bare driver data: %s", err)
+	}
+
+	h, err := api.NewHost(step.DriverName, rawDriver)
+	if err != nil {
+		return fmt.Errorf("Error getting new host: %s", err)
+	}
+
+	h.HostOptions = &host.Options{
+		AuthOptions:   newAuthOptions(c, step.Name, []string{}),
+		EngineOptions: step.engineOptions,
+		SwarmOptions:  step.swarmOptions,
+	}
+
+	driverOpts, err := specDriverOpts(h.Driver.GetCreateFlags(), step.spec.DriverOptions)
+	if err != nil {
+		return fmt.Errorf("Error in driver options of machine %q: %s", step.Name, err)
+	}
+
+	if err := h.Driver.SetConfigFromFlags(driverOpts); err != nil {
+		return fmt.Errorf("Error setting machine configuration from manifest: %s", err)
+	}
+
+	if err := api.CreateContext(c.CommandContext(), h); err != nil {
+		return err
+	}
+
+	return api.Save(h)
+}
+
+// specDriverOpts builds the options sent to the driver from its create
+// flag defaults and the values given in the manifest, converted to the type
+// of each flag.
+func specDriverOpts(mcnFlags []mcnflag.Flag, values map[string]interface{}) (drivers.DriverOptions, error) {
+	driverOpts := rpcdriver.RPCFlags{
+		Values: make(map[string]interface{}),
+	}
+
+	known := map[string]mcnflag.Flag{}
+	for _, f := range mcnFlags {
+		known[f.String()] = f
+		driverOpts.Values[f.String()] = f.Default()
+
+		if f.Default() == nil {
+			driverOpts.Values[f.String()] = false
+		}
+	}
+
+	for name, value := range values {
+		f, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown driver option %q", name)
+		}
+
+		converted, err := convertDriverOptValue(f, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q: %s", name, err)
+		}
+
+		driverOpts.Values[name] = converted
+	}
+
+	return driverOpts, nil
+}
+
+func convertDriverOptValue(f mcnflag.Flag, value interface{}) (interface{}, error) {
+	switch f.(type) {
+	case *mcnflag.BoolFlag:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case *mcnflag.IntFlag:
+		if n, ok := value.(float64); ok && n == float64(int(n)) {
+			return int(n), nil
+		}
+	case *mcnflag.StringFlag:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64, bool:
+			return fmt.Sprint(v), nil
+		}
+	case *mcnflag.StringSliceFlag:
+		switch v := value.(type) {
+		case string:
+			return []string{v}, nil
+		case []interface{}:
+			slice := []string{}
+			for _, item := range v {
+				slice = append(slice, fmt.Sprint(item))
+			}
+			return slice, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %T", value)
+}
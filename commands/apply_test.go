@@ -0,0 +1,375 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/leoh0/machine/commands/commandstest"
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/auth"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/libmachinetest"
+	"github.com/leoh0/machine/libmachine/mcnerror"
+	"github.com/leoh0/machine/libmachine/mcnflag"
+	"github.com/leoh0/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifest = `
+machines:
+  - name: web
+    driver: virtualbox
+    driverOptions:
+      virtualbox-memory: 2048
+    engine:
+      StorageDriver: overlay2
+    labels:
+      role: web
+  - name: db
+    driver: amazonec2
+`
+
+func TestParseManifest(t *testing.T) {
+	manifest, err := parseManifest([]byte(testManifest))
+
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Machines, 2)
+	assert.Equal(t, "web", manifest.Machines[0].Name)
+	assert.Equal(t, "virtualbox", manifest.Machines[0].Driver)
+	assert.Equal(t, float64(2048), manifest.Machines[0].DriverOptions["virtualbox-memory"])
+	assert.Equal(t, map[string]string{"role": "web"}, manifest.Machines[0].Labels)
+}
+
+func TestParseManifestJSON(t *testing.T) {
+	manifest, err := parseManifest([]byte(`{"machines": [{"name": "web", "driver": "none"}]}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "none", manifest.Machines[0].Driver)
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	testCases := []string{
+		"machines:\n  - name: web\n",
+		"machines:\n  - name: web\n    driver: none\n  - name: web\n    driver: none\n",
+		"machines:\n  - name: '-web'\n    driver: none\n",
+		"machines:\n  - name: web\n    driver: none\n    colour: blue\n",
+		"machines: [",
+	}
+
+	for _, tc := range testCases {
+		_, err := parseManifest([]byte(tc))
+		assert.Error(t, err, tc)
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	manifest, err := parseManifest([]byte(testManifest))
+	assert.NoError(t, err)
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "db",
+				DriverName: "amazonec2",
+				Driver:     &fakedriver.Driver{MockState: state.Running},
+				HostOptions: &host.Options{
+					EngineOptions: defaultEngineOptions(),
+					SwarmOptions:  defaultSwarmOptions(),
+					AuthOptions:   &auth.Options{},
+				},
+			},
+			{Name: "old", DriverName: "amazonec2", Driver: &fakedriver.Driver{MockState: state.Running}},
+		},
+	}
+
+	steps, err := planApply(api, manifest, false)
+
+	assert.NoError(t, err)
+	assert.Len(t, steps, 2)
+	assert.Equal(t, applyCreate, steps[0].Action)
+	assert.Equal(t, "web", steps[0].Name)
+	assert.Equal(t, "overlay2", steps[0].engineOptions.StorageDriver)
+	assert.Equal(t, []string{"role=web"}, steps[0].engineOptions.Labels)
+	assert.True(t, steps[0].engineOptions.TLSVerify)
+	assert.Equal(t, applyUnchanged, steps[1].Action)
+	assert.Equal(t, "db", steps[1].Name)
+}
+
+func TestPlanApplyReprovisionAndPrune(t *testing.T) {
+	manifest, err := parseManifest([]byte(`
+machines:
+  - name: db
+    driver: amazonec2
+    swarm:
+      Master: true
+      Discovery: token://1234
+`))
+	assert.NoError(t, err)
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "db",
+				DriverName: "amazonec2",
+				Driver:     &fakedriver.Driver{MockState: state.Running},
+				HostOptions: &host.Options{
+					EngineOptions: defaultEngineOptions(),
+					SwarmOptions:  defaultSwarmOptions(),
+					AuthOptions:   &auth.Options{},
+				},
+			},
+			{Name: "old", DriverName: "amazonec2", Driver: &fakedriver.Driver{MockState: state.Running}},
+		},
+	}
+
+	steps, err := planApply(api, manifest, true)
+
+	assert.NoError(t, err)
+	assert.Len(t, steps, 2)
+	assert.Equal(t, applyReprovision, steps[0].Action)
+	assert.Equal(t, "swarm options changed", steps[0].Reason)
+	assert.True(t, steps[0].swarmOptions.IsSwarm)
+	assert.Equal(t, "swarm:latest", steps[0].swarmOptions.Image)
+	assert.Equal(t, applyRemove, steps[1].Action)
+	assert.Equal(t, "old", steps[1].Name)
+}
+
+func TestPlanApplyPruneReportsLoadErrors(t *testing.T) {
+	manifest, err := parseManifest([]byte("machines:\n  - name: db\n    driver: amazonec2\n"))
+	assert.NoError(t, err)
+
+	api := &brokenHostAPI{
+		FakeAPI: &libmachinetest.FakeAPI{
+			Hosts: []*host.Host{
+				{
+					Name:       "db",
+					DriverName: "amazonec2",
+					Driver:     &fakedriver.Driver{MockState: state.Running},
+					HostOptions: &host.Options{
+						EngineOptions: defaultEngineOptions(),
+						SwarmOptions:  defaultSwarmOptions(),
+						AuthOptions:   &auth.Options{},
+					},
+				},
+			},
+		},
+		broken: []string{"broken"},
+	}
+
+	steps, err := planApply(api, manifest, true)
+
+	assert.NoError(t, err)
+	assert.Len(t, steps, 2)
+	assert.Equal(t, applyUnchanged, steps[0].Action)
+	assert.Equal(t, applyError, steps[1].Action)
+	assert.Equal(t, "broken", steps[1].Name)
+	assert.Equal(t, "invalid config", steps[1].Reason)
+}
+
+func TestPlanApplyDriverMismatch(t *testing.T) {
+	manifest, err := parseManifest([]byte("machines:\n  - name: db\n    driver: google\n"))
+	assert.NoError(t, err)
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "db",
+				DriverName: "amazonec2",
+				Driver:     &fakedriver.Driver{MockState: state.Running},
+				HostOptions: &host.Options{
+					EngineOptions: defaultEngineOptions(),
+					SwarmOptions:  defaultSwarmOptions(),
+					AuthOptions:   &auth.Options{},
+				},
+			},
+			{Name: "old", DriverName: "amazonec2", Driver: &fakedriver.Driver{MockState: state.Running}},
+		},
+	}
+
+	_, err = planApply(api, manifest, false)
+
+	assert.EqualError(t, err, `Error: machine "db" uses the amazonec2 driver but the manifest asks for google, remove it first`)
+}
+
+func TestExecuteApplyStepCreateChecksExistenceOnceLocked(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "web", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Running}},
+		},
+	}
+	step := &applyStep{
+		Action:     applyCreate,
+		Name:       "web",
+		DriverName: "virtualbox",
+		spec:       &MachineSpec{Name: "web", Driver: "virtualbox"},
+	}
+
+	err := executeApplyStep(&commandstest.FakeCommandLine{}, api, step)
+
+	assert.Equal(t, mcnerror.ErrHostAlreadyExists{Name: "web"}, err)
+}
+
+func TestCmdApplyDryRun(t *testing.T) {
+	buf := &bytes.Buffer{}
+	originalWriter := planWriter
+	planWriter = buf
+	defer func() { planWriter = originalWriter }()
+
+	file, err := ioutil.TempFile("", "machines.yaml")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString(testManifest)
+	file.Close()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "db",
+				DriverName: "amazonec2",
+				Driver:     &fakedriver.Driver{MockState: state.Running},
+				HostOptions: &host.Options{
+					EngineOptions: defaultEngineOptions(),
+					SwarmOptions:  defaultSwarmOptions(),
+					AuthOptions:   &auth.Options{},
+				},
+			},
+			{Name: "old", DriverName: "amazonec2", Driver: &fakedriver.Driver{MockState: state.Running}},
+		},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"file":    file.Name(),
+				"dry-run": true,
+				"prune":   true,
+			},
+		},
+	}
+
+	err = cmdApply(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Regexp(t, `create\s+web\s+virtualbox`, buf.String())
+	assert.Regexp(t, `unchanged\s+db\s+amazonec2`, buf.String())
+	assert.Regexp(t, `remove\s+old\s+amazonec2\s+not in manifest`, buf.String())
+	assert.True(t, libmachinetest.Exists(api, "old"))
+}
+
+func TestCmdApplyDryRunJSONOutput(t *testing.T) {
+	buf, restore := captureJSONOutput()
+	defer restore()
+
+	file, err := ioutil.TempFile("", "machines.yaml")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("machines:\n  - name: web\n    driver: virtualbox\n")
+	file.Close()
+
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"file":    file.Name(),
+				"dry-run": true,
+			},
+		},
+	}
+
+	err = cmdApply(commandLine, &libmachinetest.FakeAPI{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, output.flush("apply", err))
+	assert.Equal(t, `{
+    "command": "apply",
+    "result": [
+        {
+            "name": "web",
+            "action": "create",
+            "driver": "virtualbox",
+            "reason": ""
+        }
+    ]
+}
+`, buf.String())
+}
+
+func TestSpecDriverOpts(t *testing.T) {
+	mcnFlags := []mcnflag.Flag{
+		&mcnflag.IntFlag{Name: "memory", Value: 1024},
+		&mcnflag.StringFlag{Name: "region", Value: "us-east-1"},
+		&mcnflag.StringSliceFlag{Name: "tags"},
+		&mcnflag.BoolFlag{Name: "private"},
+	}
+
+	opts, err := specDriverOpts(mcnFlags, map[string]interface{}{
+		"memory": float64(2048),
+		"tags":   []interface{}{"a", "b"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2048, opts.Int("memory"))
+	assert.Equal(t, "us-east-1", opts.String("region"))
+	assert.Equal(t, []string{"a", "b"}, opts.StringSlice("tags"))
+	assert.False(t, opts.Bool("private"))
+
+	_, err = specDriverOpts(mcnFlags, map[string]interface{}{"cpus": float64(2)})
+	assert.EqualError(t, err, `unknown driver option "cpus"`)
+
+	_, err = specDriverOpts(mcnFlags, map[string]interface{}{"memory": "lots"})
+	assert.EqualError(t, err, `invalid value for "memory": unexpected string`)
+}
+
+func TestCmdApplyPruneAsksForConfirmation(t *testing.T) {
+	originalWriter := planWriter
+	planWriter = new(bytes.Buffer)
+	defer func() { planWriter = originalWriter }()
+
+	file, err := ioutil.TempFile("", "machines.yaml")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("machines:\n  - name: db\n    driver: amazonec2\n")
+	file.Close()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "db",
+				DriverName: "amazonec2",
+				Driver:     &fakedriver.Driver{MockState: state.Running},
+				HostOptions: &host.Options{
+					EngineOptions: defaultEngineOptions(),
+					SwarmOptions:  defaultSwarmOptions(),
+					AuthOptions:   &auth.Options{},
+				},
+			},
+			{Name: "old", DriverName: "amazonec2", Driver: &fakedriver.Driver{MockState: state.Running}},
+		},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"file":  file.Name(),
+				"prune": true,
+			},
+		},
+	}
+
+	// Without a terminal to answer, the removal is declined.
+	assert.NoError(t, cmdApply(commandLine, api))
+	assert.True(t, libmachinetest.Exists(api, "old"))
+
+	commandLine.LocalFlags.Data["y"] = true
+
+	assert.NoError(t, cmdApply(commandLine, api))
+	assert.False(t, libmachinetest.Exists(api, "old"))
+	assert.True(t, libmachinetest.Exists(api, "db"))
+}
+
+func TestDefaultOptionsFollowCreateFlags(t *testing.T) {
+	assert.Equal(t, drivers.DefaultEngineInstallURL, defaultEngineOptions().InstallURL)
+	assert.Equal(t, "tcp://0.0.0.0:3376", defaultSwarmOptions().Host)
+	assert.Equal(t, "swarm:latest", defaultSwarmOptions().Image)
+	assert.Equal(t, "spread", defaultSwarmOptions().Strategy)
+}
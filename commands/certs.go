@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/leoh0/machine/commands/mcndirs"
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/auth"
+	"github.com/leoh0/machine/libmachine/cert"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/persist"
+	"github.com/leoh0/machine/libmachine/state"
+)
+
+const (
+	certTypeCA     = "ca"
+	certTypeClient = "client"
+	certTypeServer = "server"
+)
+
+var (
+	errExpectedCABundleAndKey = errors.New("Error: Expected a CA certificate bundle and its key as arguments")
+
+	certsWriter io.Writer = os.Stdout
+
+	// certWarningWriter receives the certificate expiry warnings, on
+	// stderr so that they do not mix with the output scripts parse.
+	certWarningWriter io.Writer = os.Stderr
+)
+
+// CertStatusResult is the result of the certs status command for one
+// certificate. Machine is empty for the CA and client certificates, which
+// are shared by the machines.
+type CertStatusResult struct {
+	Type     string     `json:"type"`
+	Machine  string     `json:"machine,omitempty"`
+	Path     string     `json:"path"`
+	Expires  *time.Time `json:"expires"`
+	DaysLeft int        `json:"daysLeft"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// status tells if the certificate is valid, expiring or expired.
+func (r CertStatusResult) status() string {
+	switch {
+	case r.Error != "":
+		return r.Error
+	case r.DaysLeft < 0:
+		return "Expired"
+	case r.DaysLeft < cert.ExpiryWarningDays:
+		return "Expiring"
+	}
+	return "Valid"
+}
+
+func newCertStatusResult(certType, machine, certPath string) CertStatusResult {
+	result := CertStatusResult{
+		Type:    certType,
+		Machine: machine,
+		Path:    certPath,
+	}
+
+	expiry, err := cert.CertificateExpiry(certPath)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Expires = &expiry
+	result.DaysLeft = cert.DaysLeft(expiry)
+	return result
+}
+
+func cmdCertsStatus(c CommandLine, api libmachine.API) error {
+	hosts, err := certsStatusHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	results := []CertStatusResult{}
+	seen := map[string]bool{}
+	addShared := func(certType, certPath string) {
+		if certPath == "" || seen[certPath] {
+			return
+		}
+		seen[certPath] = true
+		results = append(results, newCertStatusResult(certType, "", certPath))
+	}
+
+	addShared(certTypeCA, tlsPath(c, "tls-ca-cert", "ca.pem"))
+	addShared(certTypeClient, tlsPath(c, "tls-client-cert", "cert.pem"))
+	for _, h := range hosts {
+		if authOptions := h.AuthOptions(); authOptions != nil {
+			addShared(certTypeCA, authOptions.CaCertPath)
+			addShared(certTypeClient, authOptions.ClientCertPath)
+		}
+	}
+
+	for _, h := range hosts {
+		if authOptions := h.AuthOptions(); authOptions != nil {
+			results = append(results, newCertStatusResult(certTypeServer, h.Name, authOptions.ServerCertPath))
+		}
+	}
+
+	if output.isJSON() {
+		output.printResult(results, nil)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(certsWriter, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tMACHINE\tEXPIRES\tDAYS LEFT\tSTATUS\tPATH")
+	for _, result := range results {
+		machine := result.Machine
+		if machine == "" {
+			machine = "-"
+		}
+
+		expires, daysLeft := "-", "-"
+		if result.Expires != nil {
+			expires = result.Expires.Format("2006-01-02")
+			daysLeft = fmt.Sprint(result.DaysLeft)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Type, machine, expires, daysLeft, result.status(), result.Path)
+	}
+
+	return w.Flush()
+}
+
+func cmdCertsImportCA(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 2 {
+		return errExpectedCABundleAndKey
+	}
+	bundleFile, keyFile := c.Args()[0], c.Args()[1]
+
+	authOptions := &auth.Options{
+		CertDir:          mcndirs.GetMachineCertDir(),
+		CaCertPath:       tlsPath(c, "tls-ca-cert", "ca.pem"),
+		CaPrivateKeyPath: tlsPath(c, "tls-ca-key", "ca-key.pem"),
+		ClientCertPath:   tlsPath(c, "tls-client-cert", "cert.pem"),
+		ClientKeyPath:    tlsPath(c, "tls-client-key", "key.pem"),
+	}
+
+	if _, err := os.Stat(authOptions.CaCertPath); err == nil && !c.Bool("force") {
+		return fmt.Errorf("Error: A CA already exists in %s, use --force to replace it", authOptions.CaCertPath)
+	}
+
+	if err := cert.ImportCA(bundleFile, keyFile, authOptions.CaCertPath, authOptions.CaPrivateKeyPath); err != nil {
+		return fmt.Errorf("Error importing the CA: %s", err)
+	}
+
+	// The client certificate of the previous CA is of no use anymore.
+	os.Remove(authOptions.ClientCertPath)
+	os.Remove(authOptions.ClientKeyPath)
+
+	if err := cert.BootstrapCertificates(authOptions); err != nil {
+		return fmt.Errorf("Error generating the client certificate: %s", err)
+	}
+
+	log.Infof("Imported the CA into %s. Run `docker-machine regenerate-certs <machine>` to issue the certificates of the existing machines from it.", authOptions.CaCertPath)
+	return nil
+}
+
+// certsStatusHosts returns the machines named on the command line, or all
+// the machines.
+func certsStatusHosts(c CommandLine, api libmachine.API) ([]*host.Host, error) {
+	if len(c.Args()) == 0 {
+		hosts, _, err := persist.LoadAllHosts(api)
+		return hosts, err
+	}
+
+	hosts := []*host.Host{}
+	for _, name := range c.Args() {
+		h, err := api.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h)
+	}
+
+	return hosts, nil
+}
+
+// checkCertsExpiry warns about the certificates of the machines which are
+// about to expire. With --auto-rotate-days, the server certificates of the
+// running machines are regenerated instead.
+func checkCertsExpiry(c CommandLine, hosts ...*host.Host) {
+	autoRotateDays := c.GlobalInt("auto-rotate-days")
+	seen := map[string]bool{}
+
+	for _, h := range hosts {
+		authOptions := h.AuthOptions()
+		if authOptions == nil {
+			continue
+		}
+
+		for _, certPath := range []string{authOptions.CaCertPath, authOptions.ClientCertPath} {
+			if certPath == "" || seen[certPath] {
+				continue
+			}
+			seen[certPath] = true
+			warnIfExpiring(certPath, "")
+		}
+
+		if autoRotateDays > 0 && isRunning(h) {
+			if _, err := h.RotateCertsIfExpiring(autoRotateDays); err != nil {
+				log.Warnf("Error regenerating the server certificate of %q: %s", h.Name, err)
+			}
+		}
+
+		warnIfExpiring(authOptions.ServerCertPath, h.Name)
+	}
+}
+
+// warnIfExpiring warns when the certificate at certPath expires in less than
+// cert.ExpiryWarningDays. Missing or unreadable certificates are left to the
+// commands which use them.
+func warnIfExpiring(certPath, machine string) {
+	expiry, err := cert.CertificateExpiry(certPath)
+	if err != nil {
+		log.Debugf("Error reading the expiry of %s: %s", certPath, err)
+		return
+	}
+
+	daysLeft := cert.DaysLeft(expiry)
+	if daysLeft >= cert.ExpiryWarningDays {
+		return
+	}
+
+	verb := "expires"
+	if daysLeft < 0 {
+		verb = "expired"
+	}
+
+	if machine == "" {
+		fmt.Fprintf(certWarningWriter, "WARNING: The certificate %s %s on %s.\n", certPath, verb, expiry.Format("2006-01-02"))
+		return
+	}
+
+	fmt.Fprintf(certWarningWriter, "WARNING: The server certificate of %q %s on %s. Run `docker-machine regenerate-certs %s` to renew it.\n", machine, verb, expiry.Format("2006-01-02"), machine)
+}
+
+func isRunning(h *host.Host) bool {
+	currentState, err := h.Driver.GetState()
+	return err == nil && currentState == state.Running
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leoh0/machine/commands/commandstest"
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/auth"
+	"github.com/leoh0/machine/libmachine/cert"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCertsTestHost generates a CA valid for a year and a server certificate
+// valid for validityDays in dir.
+func newCertsTestHost(t *testing.T, dir string, validityDays int) *host.Host {
+	authOptions := &auth.Options{
+		CaCertPath:       filepath.Join(dir, "ca.pem"),
+		CaPrivateKeyPath: filepath.Join(dir, "ca-key.pem"),
+		ClientCertPath:   filepath.Join(dir, "cert.pem"),
+		ServerCertPath:   filepath.Join(dir, "server.pem"),
+		ServerKeyPath:    filepath.Join(dir, "server-key.pem"),
+	}
+
+	assert.NoError(t, cert.GenerateCACertificateWithOptions(&cert.Options{
+		CertFile:     authOptions.CaCertPath,
+		KeyFile:      authOptions.CaPrivateKeyPath,
+		Org:          "test-org",
+		Bits:         2048,
+		ValidityDays: 365,
+	}))
+	assert.NoError(t, cert.GenerateCert(&cert.Options{
+		Hosts:        []string{"127.0.0.1"},
+		CertFile:     authOptions.ServerCertPath,
+		KeyFile:      authOptions.ServerKeyPath,
+		CAFile:       authOptions.CaCertPath,
+		CAKeyFile:    authOptions.CaPrivateKeyPath,
+		Org:          "test-org",
+		Bits:         2048,
+		ValidityDays: validityDays,
+	}))
+
+	return &host.Host{
+		Name:        "default",
+		Driver:      &fakedriver.Driver{},
+		HostOptions: &host.Options{AuthOptions: authOptions},
+	}
+}
+
+func TestCmdCertsStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	buf := &bytes.Buffer{}
+	original := certsWriter
+	certsWriter = buf
+	defer func() { certsWriter = original }()
+
+	h := newCertsTestHost(t, dir, 10)
+	commandLine := &commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"tls-ca-cert":     h.AuthOptions().CaCertPath,
+			"tls-client-cert": h.AuthOptions().ClientCertPath,
+		}},
+	}
+
+	err = cmdCertsStatus(commandLine, &libmachinetest.FakeAPI{Hosts: []*host.Host{h}})
+
+	assert.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 4)
+	assert.Regexp(t, `^ca\s+-\s+\d{4}-\d{2}-\d{2}\s+36\d\s+Valid\s`, string(lines[1]))
+	assert.Regexp(t, `^client\s+-\s+-\s+-\s+open .*cert.pem: no such file or directory`, string(lines[2]))
+	assert.Regexp(t, `^server\s+default\s+\d{4}-\d{2}-\d{2}\s+9\s+Expiring\s`, string(lines[3]))
+}
+
+func TestCmdCertsStatusJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, restore := captureJSONOutput()
+	defer restore()
+
+	h := newCertsTestHost(t, dir, 10)
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"default"},
+		GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"tls-ca-cert":     h.AuthOptions().CaCertPath,
+			"tls-client-cert": h.AuthOptions().ClientCertPath,
+		}},
+	}
+
+	err = cmdCertsStatus(commandLine, &libmachinetest.FakeAPI{Hosts: []*host.Host{h}})
+
+	assert.NoError(t, err)
+	results := output.result.([]CertStatusResult)
+	server := results[len(results)-1]
+	assert.Equal(t, "server", server.Type)
+	assert.Equal(t, "default", server.Machine)
+	assert.Equal(t, 9, server.DaysLeft)
+}
+
+func TestCheckCertsExpiryWarnsOnCertWarningWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	buf := &bytes.Buffer{}
+	original := certWarningWriter
+	certWarningWriter = buf
+	defer func() { certWarningWriter = original }()
+
+	h := newCertsTestHost(t, dir, 10)
+
+	checkCertsExpiry(&commandstest.FakeCommandLine{}, h)
+
+	assert.Regexp(t, `^WARNING: The server certificate of "default" expires on \d{4}-\d{2}-\d{2}\. Run .docker-machine regenerate-certs default. to renew it\.\n$`, buf.String())
+}
@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/leoh0/machine/commands/mcndirs"
 	"github.com/leoh0/machine/libmachine"
@@ -54,13 +58,38 @@ type CommandLine interface {
 
 	GlobalString(name string) string
 
+	GlobalInt(name string) int
+
 	FlagNames() (names []string)
 
 	Generic(name string) interface{}
+
+	// CommandContext is done once the command is interrupted.
+	CommandContext() context.Context
 }
 
 type contextCommandLine struct {
 	*cli.Context
+	once sync.Once
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
+// CommandContext handles Ctrl-C from its first call on, the commands which
+// never look at the context keep the default handling.
+func (c *contextCommandLine) CommandContext() context.Context {
+	c.once.Do(func() {
+		c.ctx, c.stop = interruptContext()
+	})
+	return c.ctx
+}
+
+// close restores the default handling of Ctrl-C.
+func (c *contextCommandLine) close() {
+	c.once.Do(func() {})
+	if c.stop != nil {
+		c.stop()
+	}
 }
 
 func (c *contextCommandLine) ShowHelp() {
@@ -99,6 +128,10 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 		hostsToLoad []string
 	)
 
+	if len(c.StringSlice("selector")) > 0 {
+		return runActionForSelector(actionName, c, api)
+	}
+
 	// If user did not specify a machine name explicitly, use the 'default'
 	// machine if it exists.  This allows short form commands such as
 	// 'docker-machine stop' for convenience.
@@ -113,6 +146,13 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 		hostsToLoad = c.Args()
 	}
 
+	// The machines are locked from their load until they are saved.
+	unlock, err := persist.LockMachines(api, hostsToLoad)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	hosts, hostsInError := persist.LoadHosts(api, hostsToLoad)
 
 	if len(hostsInError) > 0 {
@@ -127,13 +167,20 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 		return ErrHostLoad
 	}
 
-	if errs := runActionForeachMachine(actionName, hosts); len(errs) > 0 {
+	results := runActionForeachMachine(c.CommandContext(), actionName, hosts, c.Int("parallel"))
+
+	// The ip action has its own result.
+	if actionName != "ip" {
+		appendActionResults(actionName, results)
+	}
+
+	if errs := actionErrs(results); len(errs) > 0 {
 		return consolidateErrs(errs)
 	}
 
 	for _, h := range hosts {
 		if err := api.Save(h); err != nil {
-			return fmt.Errorf("Error saving host to store: %s", err)
+			return fmt.Errorf("Error saving host to store: %w", err)
 		}
 	}
 
@@ -142,25 +189,72 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 
 func runCommand(command func(commandLine CommandLine, api libmachine.API) error) func(context *cli.Context) {
 	return func(context *cli.Context) {
-		api := libmachine.NewClient(mcndirs.GetBaseDir(), mcndirs.GetMachineCertDir())
+		storePath := context.GlobalString("storage-path")
+		certsDir := mcndirs.GetMachineCertDir()
+
+		store, err := persist.NewStore(context.GlobalString("storage-driver"), storePath, certsDir, certsDir)
+		if err != nil {
+			log.Error(err)
+			osExit(1)
+			return
+		}
+
+		switch logFormat := context.GlobalString("log-format"); logFormat {
+		case "", "text":
+		case "json":
+			log.SetMachineLogger(log.NewJSONMachineLogger())
+		default:
+			log.Errorf("Error: Unknown log format %q, expected text or json", logFormat)
+			osExit(1)
+			return
+		}
+
+		out, err := newCommandOutput(context.GlobalString("output"), os.Stdout)
+		if err != nil {
+			log.Error(err)
+			osExit(1)
+			return
+		}
+		output = out
+
+		// Keep stdout for the JSON document.
+		if output.isJSON() {
+			log.SetOutWriter(os.Stderr)
+		}
+
+		api := libmachine.NewClientWithStore(store, certsDir)
 		defer api.Close()
 
 		if context.GlobalBool("native-ssh") {
 			api.SSHClientType = ssh.Native
 		}
 		api.GithubAPIToken = context.GlobalString("github-api-token")
-		api.Filestore.Path = context.GlobalString("storage-path")
 
 		// TODO (nathanleclaire): These should ultimately be accessed
 		// through the libmachine client by the rest of the code and
 		// not through their respective modules.  For now, however,
 		// they are also being set the way that they originally were
 		// set to preserve backwards compatibility.
-		mcndirs.BaseDir = api.Filestore.Path
+		mcndirs.BaseDir = storePath
 		mcnutils.GithubAPIToken = api.GithubAPIToken
+		if lockTimeout := context.GlobalInt("lock-timeout"); lockTimeout > 0 {
+			persist.DefaultLockTimeout = time.Duration(lockTimeout) * time.Second
+		}
 		ssh.SetDefaultClient(api.SSHClientType)
+		ssh.SetConnectionReuse(!context.GlobalBool("no-ssh-reuse"))
+
+		commandLine := &contextCommandLine{Context: context}
+		defer commandLine.close()
+
+		err = command(commandLine, api)
 
-		if err := command(&contextCommandLine{context}, api); err != nil {
+		if output.isJSON() {
+			if flushErr := output.flush(context.Command.FullName(), err); flushErr != nil {
+				log.Error(flushErr)
+			}
+		}
+
+		if err != nil {
 			log.Error(err)
 
 			if crashErr, ok := err.(crashreport.CrashError); ok {
@@ -179,6 +273,20 @@ func runCommand(command func(commandLine CommandLine, api libmachine.API) error)
 	}
 }
 
+// interruptContext returns a context which is done on Ctrl-C, to abort the
+// running command. Closing the API afterwards stops the driver plugins, along
+// with what they were still doing. The default handling is restored after
+// the first Ctrl-C, a second one kills a command slow to stop.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return ctx, stop
+}
+
 func confirmInput(msg string) (bool, error) {
 	fmt.Printf("%s (y/n): ", msg)
 
@@ -192,6 +300,19 @@ func confirmInput(msg string) (bool, error) {
 	return confirmed, nil
 }
 
+var bulkActionFlags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "selector",
+		Usage: "Act on every machine matching a filter, using the same syntax as 'ls --filter'",
+		Value: &cli.StringSlice{},
+	},
+	cli.IntFlag{
+		Name:  "parallel",
+		Usage: "Maximum number of machines to act on concurrently, 0 for no limit",
+		Value: defaultParallelActions,
+	},
+}
+
 var Commands = []cli.Command{
 	{
 		Name:   "active",
@@ -205,6 +326,58 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "apply",
+		Usage:       "Create, re-provision or remove machines to match a manifest",
+		Description: "The manifest is a YAML or JSON file listing the machines, their driver, driver options, engine and swarm options and labels.",
+		Action:      runCommand(cmdApply),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "Manifest describing the machines",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only print the changes which would be made",
+			},
+			cli.BoolFlag{
+				Name:  "prune",
+				Usage: "Remove the machines which are not in the manifest",
+			},
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: "Remove the pruned machines without asking for confirmation",
+			},
+			cli.BoolFlag{
+				Name:  "y",
+				Usage: "Assumes automatic yes to the removal of the pruned machines",
+			},
+		},
+	},
+	{
+		Name:  "certs",
+		Usage: "Manage the TLS certificates of the machines",
+		Subcommands: []cli.Command{
+			{
+				Name:        "status",
+				Usage:       "Show when the CA, client and server certificates expire",
+				Description: "Arguments are zero or more machine names, all the machines by default.",
+				Action:      runCommand(cmdCertsStatus),
+			},
+			{
+				Name:        "import-ca",
+				Usage:       "Issue the certificates from an existing CA",
+				Description: "Arguments are a PEM bundle, starting with the CA which signs followed by the CAs which issued it, and the key of the CA.",
+				Action:      runCommand(cmdCertsImportCA),
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "force, f",
+						Usage: "Replace the current CA",
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:        "config",
 		Usage:       "Print the connection config for machine",
@@ -249,6 +422,53 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "export",
+		Usage:       "Export a machine to an archive",
+		Description: "Argument is a machine name. The archive holds the machine configuration, certificates and SSH keys.",
+		Action:      runCommand(cmdExport),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "Archive to write, defaults to <machine>.tar.gz",
+			},
+		},
+	},
+	{
+		Name:  "forwards",
+		Usage: "Manage the port forwards and proxies running in the background",
+		Subcommands: []cli.Command{
+			{
+				Name:   "ls",
+				Usage:  "List the port forwards and proxies running in the background",
+				Action: runCommand(cmdForwardsLs),
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "quiet, q",
+						Usage: "Enable quiet mode",
+					},
+				},
+			},
+			{
+				Name:        "stop",
+				Usage:       "Stop port forwards or proxies running in the background",
+				Description: "Argument(s) are one or more IDs given by 'forwards ls'.",
+				Action:      runCommand(cmdForwardsStop),
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "all, a",
+						Usage: "Stop all of them",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "import",
+		Usage:       "Import a machine from an archive made by export",
+		Description: "Argument is an archive path.",
+		Action:      runCommand(cmdImport),
+	},
 	{
 		Name:        "inspect",
 		Usage:       "Inspect information about a machine",
@@ -273,6 +493,7 @@ var Commands = []cli.Command{
 		Usage:       "Kill a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdKill),
+		Flags:       bulkActionFlags,
 	},
 	{
 		Name:   "ls",
@@ -299,17 +520,65 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "migrate-store",
+		Usage:       "Copy machines from another storage driver into the current one",
+		Description: "Machines are copied into the store selected with the global --storage-driver flag.",
+		Action:      runCommand(cmdMigrateStore),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "from",
+				Usage: "Storage driver to copy the machines from",
+				Value: persist.FilestoreDriver,
+			},
+		},
+	},
+	{
+		Name:        "port-forward",
+		Usage:       "Forward local ports to a machine",
+		Description: "Arguments are a machine name and one or more [bind_address:]port:[host:]hostport, the host defaulting to the machine itself.",
+		Action:      runCommand(cmdPortForward),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "background, d",
+				Usage: "Run in the background, see 'docker-machine forwards'",
+			},
+		},
+	},
 	{
 		Name:   "provision",
 		Usage:  "Re-provision existing machines",
 		Action: runCommand(cmdProvision),
+		Flags: append([]cli.Flag{
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print what provisioning would do, without changing the machines",
+			},
+		}, bulkActionFlags...),
+	},
+	{
+		Name:        "proxy",
+		Usage:       "Run a SOCKS5 proxy to the network of a machine",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdProxy),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "address, a",
+				Usage: "Local address to listen on",
+				Value: "127.0.0.1:1080",
+			},
+			cli.BoolFlag{
+				Name:  "background, d",
+				Usage: "Run in the background, see 'docker-machine forwards'",
+			},
+		},
 	},
 	{
 		Name:        "regenerate-certs",
 		Usage:       "Regenerate TLS Certificates for a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdRegenerateCerts),
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.BoolFlag{
 				Name:  "force, f",
 				Usage: "Force rebuild and do not prompt",
@@ -318,6 +587,26 @@ var Commands = []cli.Command{
 				Name:  "client-certs",
 				Usage: "Also regenerate client certificates and CA.",
 			},
+		}, bulkActionFlags...),
+	},
+	{
+		Name:        "resize",
+		Usage:       "Change the CPUs, memory or disk size of a machine",
+		Description: "Argument is a machine name. Some drivers require the machine to be stopped, the disk can only grow.",
+		Action:      runCommand(cmdResize),
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "cpus",
+				Usage: "Number of CPUs",
+			},
+			cli.IntFlag{
+				Name:  "memory",
+				Usage: "Size of memory in MB",
+			},
+			cli.IntFlag{
+				Name:  "disk",
+				Usage: "Size of disk in MB",
+			},
 		},
 	},
 	{
@@ -325,9 +614,10 @@ var Commands = []cli.Command{
 		Usage:       "Restart a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdRestart),
+		Flags:       bulkActionFlags,
 	},
 	{
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.BoolFlag{
 				Name:  "force, f",
 				Usage: "Remove local configuration even if machine cannot be removed, also implies an automatic yes (`-y`)",
@@ -336,7 +626,7 @@ var Commands = []cli.Command{
 				Name:  "y",
 				Usage: "Assumes automatic yes to proceed with remove, without prompting further user confirmation",
 			},
-		},
+		}, bulkActionFlags...),
 		Name:        "rm",
 		Usage:       "Remove a machine",
 		Description: "Argument(s) are one or more machine names.",
@@ -349,10 +639,22 @@ var Commands = []cli.Command{
 		Action:          runCommand(cmdSSH),
 		SkipFlagParsing: true,
 	},
+	{
+		Name:        "ssh-keyscan",
+		Usage:       "Pin the SSH host key of a machine and print its fingerprint",
+		Description: "Argument is a machine name. The host key is pinned on the first connection to the machine, and checked on the next ones.",
+		Action:      runCommand(cmdSSHKeyscan),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "reset",
+				Usage: "Forget the pinned host key first, when the machine legitimately got a new key",
+			},
+		},
+	},
 	{
 		Name:        "scp",
 		Usage:       "Copy files between machines",
-		Description: "Arguments are [[user@]machine:][path] [[user@]machine:][path].",
+		Description: "Arguments are [[user@]machine:][path] [[user@]machine:][path]. With --native-ssh, or when the scp binary is missing, the files are copied over the SFTP subsystem of the SSH server of the machine.",
 		Action:      runCommand(cmdScp),
 		Flags: []cli.Flag{
 			cli.BoolFlag{
@@ -361,7 +663,7 @@ var Commands = []cli.Command{
 			},
 			cli.BoolFlag{
 				Name:  "delta, d",
-				Usage: "Reduce amount of data sent over network by sending only the differences (uses rsync)",
+				Usage: "Reduce amount of data sent over network by sending only the differences (uses rsync, or with --native-ssh checksums computed by find and sha256sum on the machine)",
 			},
 			cli.BoolFlag{
 				Name:  "quiet, q",
@@ -369,16 +671,67 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:  "snapshot",
+		Usage: "Manage the snapshots of a machine",
+		Subcommands: []cli.Command{
+			{
+				Name:        "create",
+				Usage:       "Take a snapshot of a machine",
+				Description: "Arguments are a machine name and a snapshot name.",
+				Action:      runCommand(cmdSnapshotCreate),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "description, d",
+						Usage: "Description of the snapshot",
+					},
+				},
+			},
+			{
+				Name:        "ls",
+				Usage:       "List the snapshots of a machine",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdSnapshotLs),
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "quiet, q",
+						Usage: "Enable quiet mode",
+					},
+				},
+			},
+			{
+				Name:        "restore",
+				Usage:       "Restore a machine to a snapshot",
+				Description: "Arguments are a machine name and a snapshot name.",
+				Action:      runCommand(cmdSnapshotRestore),
+			},
+			{
+				Name:        "rm",
+				Usage:       "Remove a snapshot of a machine",
+				Description: "Arguments are a machine name and a snapshot name.",
+				Action:      runCommand(cmdSnapshotRm),
+			},
+		},
+	},
 	{
 		Name:        "mount",
-		Usage:       "Mount or unmount a directory from a machine with SSHFS.",
-		Description: "Arguments are [machine:][path] [mountpoint]",
+		Usage:       "Mount or unmount a directory from a machine with SSHFS, or sync it over SSH.",
+		Description: "Arguments are [machine:][path] [mountpoint]. With --sync, the mountpoint is an existing local directory whose changes are pushed to the path.",
 		Action:      runCommand(cmdMount),
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "unmount, u",
 				Usage: "Unmount instead of mount",
 			},
+			cli.BoolFlag{
+				Name:  "sync, s",
+				Usage: "Push the changes of the local directory to the machine over SSH until interrupted, instead of mounting it with SSHFS (needs the SFTP subsystem, find and sha256sum on the machine)",
+			},
+			cli.IntFlag{
+				Name:  "sync-interval",
+				Usage: "Seconds between the checks for local changes with --sync",
+				Value: 2,
+			},
 		},
 	},
 	{
@@ -386,6 +739,7 @@ var Commands = []cli.Command{
 		Usage:       "Start a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdStart),
+		Flags:       bulkActionFlags,
 	},
 	{
 		Name:        "status",
@@ -398,12 +752,14 @@ var Commands = []cli.Command{
 		Usage:       "Stop a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdStop),
+		Flags:       bulkActionFlags,
 	},
 	{
 		Name:        "upgrade",
 		Usage:       "Upgrade a machine to the latest version of Docker",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdUpgrade),
+		Flags:       bulkActionFlags,
 	},
 	{
 		Name:        "url",
@@ -418,6 +774,12 @@ var Commands = []cli.Command{
 	},
 }
 
+// IPResult is the result of the ip command for one machine.
+type IPResult struct {
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+}
+
 func printIP(h *host.Host) func() error {
 	return func() error {
 		ip, err := h.Driver.GetIP()
@@ -425,23 +787,27 @@ func printIP(h *host.Host) func() error {
 			return fmt.Errorf("Error getting IP address: %s", err)
 		}
 
-		fmt.Println(ip)
+		output.appendResult(IPResult{
+			Name: h.Name,
+			IP:   ip,
+		}, func() {
+			fmt.Println(ip)
+		})
 
 		return nil
 	}
 }
 
 // machineCommand maps the command name to the corresponding machine command.
-// We run commands concurrently and communicate back an error if there was one.
-func machineCommand(actionName string, host *host.Host, errorChan chan<- error) {
+func machineCommand(ctx context.Context, actionName string, host *host.Host) error {
 	// TODO: These actions should have their own type.
 	commands := map[string](func() error){
 		"configureAuth":    host.ConfigureAuth,
 		"configureAllAuth": host.ConfigureAllAuth,
-		"start":            host.Start,
-		"stop":             host.Stop,
-		"restart":          host.Restart,
-		"kill":             host.Kill,
+		"start":            func() error { return host.StartContext(ctx) },
+		"stop":             func() error { return host.StopContext(ctx) },
+		"restart":          func() error { return host.RestartContext(ctx) },
+		"kill":             func() error { return host.KillContext(ctx) },
 		"upgrade":          host.Upgrade,
 		"ip":               printIP(host),
 		"provision":        host.Provision,
@@ -449,34 +815,66 @@ func machineCommand(actionName string, host *host.Host, errorChan chan<- error)
 
 	log.Debugf("command=%s machine=%s", actionName, host.Name)
 
-	errorChan <- commands[actionName]()
+	return commands[actionName]()
+}
+
+// runActionForeachMachine will run the command across multiple machines, at
+// most parallel at a time (no limit if parallel is not positive), and return
+// the outcome for each machine in the order they were given. Machines still
+// waiting for their turn when ctx is done are left alone.
+func runActionForeachMachine(ctx context.Context, actionName string, machines []*host.Host, parallel int) []machineActionResult {
+	names := make([]string, len(machines))
+	for i, machine := range machines {
+		names[i] = machine.Name
+	}
+
+	return runForeachMachine(ctx, names, parallel, func(i int) error {
+		return machineCommand(ctx, actionName, machines[i])
+	})
 }
 
-// runActionForeachMachine will run the command across multiple machines
-func runActionForeachMachine(actionName string, machines []*host.Host) []error {
+// runForeachMachine calls fn with the index of each of the named machines,
+// like runActionForeachMachine runs an action.
+func runForeachMachine(ctx context.Context, names []string, parallel int, fn func(i int) error) []machineActionResult {
 	var (
-		numConcurrentActions = 0
-		errorChan            = make(chan error)
-		errs                 = []error{}
+		results = make([]machineActionResult, len(names))
+		wg      sync.WaitGroup
 	)
 
-	for _, machine := range machines {
-		numConcurrentActions++
-		go machineCommand(actionName, machine, errorChan)
+	if parallel <= 0 {
+		parallel = len(names)
 	}
 
-	// TODO: We should probably only do 5-10 of these
-	// at a time, since otherwise cloud providers might
-	// rate limit us.
-	for i := 0; i < numConcurrentActions; i++ {
-		if err := <-errorChan; err != nil {
-			errs = append(errs, err)
-		}
+	// Cloud providers might rate limit us if we act on too many machines
+	// at once.
+	sem := make(chan struct{}, parallel)
+
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := ctx.Err(); err != nil {
+				results[i] = machineActionResult{
+					Name: name,
+					Err:  err,
+				}
+				return
+			}
+
+			results[i] = machineActionResult{
+				Name: name,
+				Err:  fn(i),
+			}
+		}(i, name)
 	}
 
-	close(errorChan)
+	wg.Wait()
 
-	return errs
+	return results
 }
 
 func consolidateErrs(errs []error) error {
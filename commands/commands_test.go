@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"testing"
@@ -75,7 +76,7 @@ func TestRunActionForeachMachine(t *testing.T) {
 		},
 	}
 
-	runActionForeachMachine("start", machines)
+	runActionForeachMachine(context.Background(), "start", machines, 0)
 
 	for _, machine := range machines {
 		machineState, _ := machine.Driver.GetState()
@@ -83,7 +84,7 @@ func TestRunActionForeachMachine(t *testing.T) {
 		assert.Equal(t, state.Running, machineState)
 	}
 
-	runActionForeachMachine("stop", machines)
+	runActionForeachMachine(context.Background(), "stop", machines, 2)
 
 	for _, machine := range machines {
 		machineState, _ := machine.Driver.GetState()
@@ -92,6 +93,28 @@ func TestRunActionForeachMachine(t *testing.T) {
 	}
 }
 
+func TestRunActionForeachMachineCancelled(t *testing.T) {
+	machines := []*host.Host{
+		{
+			Name:       "foo",
+			DriverName: "fakedriver",
+			Driver: &fakedriver.Driver{
+				MockState: state.Stopped,
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := runActionForeachMachine(ctx, "start", machines, 0)
+
+	assert.Equal(t, []machineActionResult{{Name: "foo", Err: context.Canceled}}, results)
+
+	machineState, _ := machines[0].Driver.GetState()
+	assert.Equal(t, state.Stopped, machineState)
+}
+
 func TestPrintIPEmptyGivenLocalEngine(t *testing.T) {
 	stdoutGetter := commandstest.NewStdoutGetter()
 	defer stdoutGetter.Stop()
@@ -241,3 +264,17 @@ func checkErrorCodeForCommand(command func(commandLine CommandLine, api libmachi
 
 	return setExitCode
 }
+
+func TestContextCommandLineHandlesInterruptOnDemand(t *testing.T) {
+	c := &contextCommandLine{}
+	c.close()
+	assert.Nil(t, c.ctx)
+
+	c = &contextCommandLine{}
+	ctx := c.CommandContext()
+	assert.NoError(t, ctx.Err())
+	assert.Equal(t, ctx, c.CommandContext())
+
+	c.close()
+	<-ctx.Done()
+}
@@ -1,6 +1,8 @@
 package commandstest
 
 import (
+	"context"
+
 	"github.com/urfave/cli"
 )
 
@@ -12,6 +14,7 @@ type FakeCommandLine struct {
 	LocalFlags, GlobalFlags *FakeFlagger
 	HelpShown, VersionShown bool
 	CliArgs                 []string
+	Ctx                     context.Context
 }
 
 func (ff FakeFlagger) String(key string) string {
@@ -43,19 +46,31 @@ func (ff FakeFlagger) Bool(key string) bool {
 }
 
 func (fcli *FakeCommandLine) IsSet(key string) bool {
+	if fcli.LocalFlags == nil {
+		return false
+	}
 	_, ok := fcli.LocalFlags.Data[key]
 	return ok
 }
 
 func (fcli *FakeCommandLine) String(key string) string {
+	if fcli.LocalFlags == nil {
+		return ""
+	}
 	return fcli.LocalFlags.String(key)
 }
 
 func (fcli *FakeCommandLine) StringSlice(key string) []string {
+	if fcli.LocalFlags == nil {
+		return []string{}
+	}
 	return fcli.LocalFlags.StringSlice(key)
 }
 
 func (fcli *FakeCommandLine) Int(key string) int {
+	if fcli.LocalFlags == nil {
+		return 0
+	}
 	return fcli.LocalFlags.Int(key)
 }
 
@@ -70,6 +85,13 @@ func (fcli *FakeCommandLine) GlobalString(key string) string {
 	return fcli.GlobalFlags.String(key)
 }
 
+func (fcli *FakeCommandLine) GlobalInt(key string) int {
+	if fcli.GlobalFlags == nil {
+		return 0
+	}
+	return fcli.GlobalFlags.Int(key)
+}
+
 func (fcli *FakeCommandLine) Generic(name string) interface{} {
 	return fcli.LocalFlags.Data[name]
 }
@@ -83,6 +105,13 @@ func (fcli *FakeCommandLine) FlagNames() []string {
 	return flagNames
 }
 
+func (fcli *FakeCommandLine) CommandContext() context.Context {
+	if fcli.Ctx == nil {
+		return context.Background()
+	}
+	return fcli.Ctx
+}
+
 func (fcli *FakeCommandLine) ShowHelp() {
 	fcli.HelpShown = true
 }
@@ -11,6 +11,16 @@ import (
 	"github.com/leoh0/machine/libmachine/log"
 )
 
+// ConfigResult is the result of the config command.
+type ConfigResult struct {
+	Name      string `json:"name"`
+	TLSVerify bool   `json:"tlsVerify"`
+	TLSCACert string `json:"tlsCaCert"`
+	TLSCert   string `json:"tlsCert"`
+	TLSKey    string `json:"tlsKey"`
+	Host      string `json:"host"`
+}
+
 func cmdConfig(c CommandLine, api libmachine.API) error {
 	// Ensure that log messages always go to stderr when this command is
 	// being run (it is intended to be run in a subshell)
@@ -39,8 +49,17 @@ func cmdConfig(c CommandLine, api libmachine.API) error {
 
 	// TODO(nathanleclaire): These magic strings for the certificate file
 	// names should be cross-package constants.
-	fmt.Printf("--tlsverify\n--tlscacert=%q\n--tlscert=%q\n--tlskey=%q\n-H=%s\n",
-		tlsCACert, tlsCert, tlsKey, dockerHost)
+	output.printResult(ConfigResult{
+		Name:      host.Name,
+		TLSVerify: true,
+		TLSCACert: tlsCACert,
+		TLSCert:   tlsCert,
+		TLSKey:    tlsKey,
+		Host:      dockerHost,
+	}, func() {
+		fmt.Printf("--tlsverify\n--tlscacert=%q\n--tlscert=%q\n--tlskey=%q\n-H=%s\n",
+			tlsCACert, tlsCert, tlsKey, dockerHost)
+	})
 
 	return nil
 }
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"errors"
@@ -17,6 +19,7 @@ import (
 	"github.com/leoh0/machine/commands/mcndirs"
 	"github.com/leoh0/machine/libmachine"
 	"github.com/leoh0/machine/libmachine/auth"
+	"github.com/leoh0/machine/libmachine/cert"
 	"github.com/leoh0/machine/libmachine/crashreport"
 	"github.com/leoh0/machine/libmachine/drivers"
 	"github.com/leoh0/machine/libmachine/drivers/rpcdriver"
@@ -25,12 +28,18 @@ import (
 	"github.com/leoh0/machine/libmachine/log"
 	"github.com/leoh0/machine/libmachine/mcnerror"
 	"github.com/leoh0/machine/libmachine/mcnflag"
+	"github.com/leoh0/machine/libmachine/persist"
+	"github.com/leoh0/machine/libmachine/ssh"
 	"github.com/leoh0/machine/libmachine/swarm"
 	"github.com/urfave/cli"
 )
 
 var (
 	errNoMachineName = errors.New("Error: No machine name specified")
+
+	// cloudInitDrivers are the drivers which pass the cloud-init user-data
+	// to their instances.
+	cloudInitDrivers = []string{"amazonec2", "digitalocean", "google"}
 )
 
 var (
@@ -77,6 +86,40 @@ var (
 			Usage: "Specify environment variables to set in the engine",
 			Value: &cli.StringSlice{},
 		},
+		cli.BoolFlag{
+			Name:  "engine-daemon-json",
+			Usage: "Write the engine settings to the daemon.json of the machine, merged with its existing settings, instead of daemon flags (not supported on RancherOS)",
+		},
+		cli.StringFlag{
+			Name:  "engine-log-driver",
+			Usage: "Specify a log driver to use with the engine",
+		},
+		cli.StringSliceFlag{
+			Name:  "engine-log-opt",
+			Usage: "Specify log driver options for the engine in the form key=value",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringFlag{
+			Name:  "engine-cgroup-driver",
+			Usage: "Specify a cgroup driver to use with the engine (cgroupfs or systemd)",
+		},
+		cli.StringSliceFlag{
+			Name:  "engine-default-address-pool",
+			Usage: "Specify a default address pool for the networks of the engine in the form base=10.10.0.0/16,size=24",
+			Value: &cli.StringSlice{},
+		},
+		cli.BoolFlag{
+			Name:  "cloud-init",
+			Usage: "Provision with a cloud-init user-data passed at creation instead of over SSH (amazonec2, digitalocean and google drivers). The server certificate is still installed over SSH once the IP of the machine is known",
+		},
+		cli.StringFlag{
+			Name:  "pre-provision-script",
+			Usage: "Run a local script as root on the machine before provisioning it, each time it is provisioned",
+		},
+		cli.StringFlag{
+			Name:  "post-provision-script",
+			Usage: "Run a local script as root on the machine after provisioning it, each time it is provisioned",
+		},
 		cli.BoolFlag{
 			Name:  "swarm",
 			Usage: "Configure Machine to join a Swarm cluster",
@@ -125,14 +168,56 @@ var (
 			Name:  "swarm-experimental",
 			Usage: "Enable Swarm experimental features",
 		},
+		cli.BoolFlag{
+			Name:  "swarm-mode-manager",
+			Usage: "Make the engine a manager of a Docker swarm mode cluster",
+		},
+		cli.BoolFlag{
+			Name:  "swarm-mode-worker",
+			Usage: "Make the engine a worker of a Docker swarm mode cluster",
+		},
+		cli.StringFlag{
+			Name:  "swarm-mode-join",
+			Usage: "Manager machine of the swarm mode cluster to join (default: create a new cluster)",
+			Value: "",
+		},
 		cli.StringSliceFlag{
 			Name:  "tls-san",
 			Usage: "Support extra SANs for TLS certs",
 			Value: &cli.StringSlice{},
 		},
+		cli.StringFlag{
+			Name:  "tls-key-type",
+			Usage: "Type of the keys of the TLS certs: rsa or ecdsa",
+			Value: cert.KeyTypeRSA,
+		},
+		cli.StringFlag{
+			Name:  "machine-tls-ca-cert",
+			Usage: "CA bundle to issue the TLS certs of this machine from, instead of the global CA",
+		},
+		cli.StringFlag{
+			Name:  "machine-tls-ca-key",
+			Usage: "Private key of the CA given with --machine-tls-ca-cert",
+		},
+		cli.IntFlag{
+			Name:  "tls-validity-days",
+			Usage: "Number of days the generated TLS certs are valid",
+			Value: cert.DefaultValidityDays,
+		},
+		cli.StringFlag{
+			Name:  "ssh-key-type",
+			Usage: "Type of the SSH key: rsa, ecdsa or ed25519",
+			Value: ssh.KeyTypeRSA,
+		},
 	}
 )
 
+// CreateResult is the result of the create command.
+type CreateResult struct {
+	Name   string `json:"name"`
+	Driver string `json:"driver"`
+}
+
 func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	if len(c.Args()) > 1 {
 		return fmt.Errorf("Invalid command line. Found extra arguments %v", c.Args()[1:])
@@ -146,13 +231,21 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 
 	validName := host.ValidateHostName(name)
 	if !validName {
-		return fmt.Errorf("Error creating machine: %s", mcnerror.ErrInvalidHostname)
+		return fmt.Errorf("Error creating machine: %w", mcnerror.ErrInvalidHostname)
 	}
 
 	if err := validateSwarmDiscovery(c.String("swarm-discovery")); err != nil {
 		return fmt.Errorf("Error parsing swarm discovery: %s", err)
 	}
 
+	if err := cert.ValidateKeyType(c.String("tls-key-type")); err != nil {
+		return err
+	}
+
+	if err := ssh.ValidateKeyType(c.String("ssh-key-type")); err != nil {
+		return err
+	}
+
 	// TODO: Fix hacky JSON solution
 	rawDriver, err := json.Marshal(&drivers.BaseDriver{
 		MachineName: name,
@@ -163,32 +256,63 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	}
 
 	driverName := c.String("driver")
+	if c.Bool("cloud-init") && !supportsCloudInit(driverName) {
+		return fmt.Errorf("Error: The %s driver does not support --cloud-init, only %s do", driverName, strings.Join(cloudInitDrivers, ", "))
+	}
+
 	h, err := api.NewHost(driverName, rawDriver)
 	if err != nil {
 		return fmt.Errorf("Error getting new host: %s", err)
 	}
 
+	authOptions := newAuthOptions(c, name, c.StringSlice("tls-san"))
+	authOptions.TLSKeyType = c.String("tls-key-type")
+	authOptions.SSHKeyType = c.String("ssh-key-type")
+	authOptions.CertValidityDays = c.Int("tls-validity-days")
+	if err := setMachineCA(authOptions, c.String("machine-tls-ca-cert"), c.String("machine-tls-ca-key")); err != nil {
+		return err
+	}
+
+	addressPools := []engine.AddressPool{}
+	for _, p := range c.StringSlice("engine-default-address-pool") {
+		pool, err := engine.ParseAddressPool(p)
+		if err != nil {
+			return err
+		}
+		addressPools = append(addressPools, pool)
+	}
+
+	preProvisionScript, err := provisionScriptPath(c.String("pre-provision-script"))
+	if err != nil {
+		return err
+	}
+	postProvisionScript, err := provisionScriptPath(c.String("post-provision-script"))
+	if err != nil {
+		return err
+	}
+
+	for _, o := range c.StringSlice("engine-log-opt") {
+		if !strings.Contains(o, "=") {
+			return fmt.Errorf("Invalid log option %q, expected key=value", o)
+		}
+	}
+
 	h.HostOptions = &host.Options{
-		AuthOptions: &auth.Options{
-			CertDir:          mcndirs.GetMachineCertDir(),
-			CaCertPath:       tlsPath(c, "tls-ca-cert", "ca.pem"),
-			CaPrivateKeyPath: tlsPath(c, "tls-ca-key", "ca-key.pem"),
-			ClientCertPath:   tlsPath(c, "tls-client-cert", "cert.pem"),
-			ClientKeyPath:    tlsPath(c, "tls-client-key", "key.pem"),
-			ServerCertPath:   filepath.Join(mcndirs.GetMachineDir(), name, "server.pem"),
-			ServerKeyPath:    filepath.Join(mcndirs.GetMachineDir(), name, "server-key.pem"),
-			StorePath:        filepath.Join(mcndirs.GetMachineDir(), name),
-			ServerCertSANs:   c.StringSlice("tls-san"),
-		},
+		AuthOptions: authOptions,
 		EngineOptions: &engine.Options{
-			ArbitraryFlags:   c.StringSlice("engine-opt"),
-			Env:              c.StringSlice("engine-env"),
-			InsecureRegistry: c.StringSlice("engine-insecure-registry"),
-			Labels:           c.StringSlice("engine-label"),
-			RegistryMirror:   c.StringSlice("engine-registry-mirror"),
-			StorageDriver:    c.String("engine-storage-driver"),
-			TLSVerify:        true,
-			InstallURL:       c.String("engine-install-url"),
+			ArbitraryFlags:      c.StringSlice("engine-opt"),
+			Env:                 c.StringSlice("engine-env"),
+			InsecureRegistry:    c.StringSlice("engine-insecure-registry"),
+			Labels:              c.StringSlice("engine-label"),
+			RegistryMirror:      c.StringSlice("engine-registry-mirror"),
+			StorageDriver:       c.String("engine-storage-driver"),
+			TLSVerify:           true,
+			InstallURL:          c.String("engine-install-url"),
+			DaemonJSON:          c.Bool("engine-daemon-json"),
+			LogDriver:           c.String("engine-log-driver"),
+			LogOpts:             c.StringSlice("engine-log-opt"),
+			CgroupDriver:        c.String("engine-cgroup-driver"),
+			DefaultAddressPools: addressPools,
 		},
 		SwarmOptions: &swarm.Options{
 			IsSwarm:            c.Bool("swarm") || c.Bool("swarm-master"),
@@ -203,7 +327,20 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 			ArbitraryJoinFlags: c.StringSlice("swarm-join-opt"),
 			IsExperimental:     c.Bool("swarm-experimental"),
 		},
+		CloudInit:           c.Bool("cloud-init"),
+		PreProvisionScript:  preProvisionScript,
+		PostProvisionScript: postProvisionScript,
+	}
+
+	if err := setSwarmMode(h.HostOptions.SwarmOptions, c, api); err != nil {
+		return err
+	}
+
+	unlock, err := persist.LockMachines(api, []string{h.Name})
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
 	exists, err := api.Exists(h.Name)
 	if err != nil {
@@ -225,7 +362,7 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	// 	return fmt.Errorf("Error setting machine configuration from flags provided: %s", err)
 	// }
 
-	if err := api.Create(h); err != nil {
+	if err := api.CreateContext(c.CommandContext(), h); err != nil {
 		// Wait for all the logs to reach the client
 		time.Sleep(2 * time.Second)
 
@@ -244,10 +381,15 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	}
 
 	if err := api.Save(h); err != nil {
-		return fmt.Errorf("Error attempting to save store: %s", err)
+		return fmt.Errorf("Error attempting to save store: %w", err)
 	}
 
-	log.Infof("To see how to connect your Docker Client to the Docker Engine running on this virtual machine, run: %s env %s", os.Args[0], name)
+	output.printResult(CreateResult{
+		Name:   h.Name,
+		Driver: h.DriverName,
+	}, func() {
+		log.Infof("To see how to connect your Docker Client to the Docker Engine running on this virtual machine, run: %s env %s", os.Args[0], name)
+	})
 
 	return nil
 }
@@ -452,6 +594,144 @@ func validateSwarmDiscovery(discovery string) error {
 	return fmt.Errorf("Swarm Discovery URL was in the wrong format: %s", discovery)
 }
 
+// newAuthOptions returns the TLS configuration of a new machine, honoring
+// the global --tls-* flags.
+func newAuthOptions(c CommandLine, name string, serverCertSANs []string) *auth.Options {
+	return &auth.Options{
+		CertDir:          mcndirs.GetMachineCertDir(),
+		CaCertPath:       tlsPath(c, "tls-ca-cert", "ca.pem"),
+		CaPrivateKeyPath: tlsPath(c, "tls-ca-key", "ca-key.pem"),
+		ClientCertPath:   tlsPath(c, "tls-client-cert", "cert.pem"),
+		ClientKeyPath:    tlsPath(c, "tls-client-key", "key.pem"),
+		ServerCertPath:   filepath.Join(mcndirs.GetMachineDir(), name, "server.pem"),
+		ServerKeyPath:    filepath.Join(mcndirs.GetMachineDir(), name, "server-key.pem"),
+		StorePath:        filepath.Join(mcndirs.GetMachineDir(), name),
+		ServerCertSANs:   serverCertSANs,
+		SignerPath:       c.GlobalString("tls-signer"),
+	}
+}
+
+// setMachineCA makes a machine use its own CA instead of the global one. The
+// client certificate must then be issued by this CA too, so the machine gets
+// its own, in its directory.
+func setMachineCA(authOptions *auth.Options, caCertPath, caKeyPath string) error {
+	if caCertPath == "" && caKeyPath == "" {
+		return nil
+	}
+	if caCertPath == "" || caKeyPath == "" {
+		return errors.New("Error: --machine-tls-ca-cert and --machine-tls-ca-key must be used together")
+	}
+
+	caCertPath, err := filepath.Abs(caCertPath)
+	if err != nil {
+		return err
+	}
+	caKeyPath, err = filepath.Abs(caKeyPath)
+	if err != nil {
+		return err
+	}
+
+	// A missing CA is generated, like the global one.
+	if _, err := os.Stat(caCertPath); err == nil {
+		if err := cert.ValidateCA(caCertPath, caKeyPath); err != nil {
+			return fmt.Errorf("Error with the CA of the machine: %s", err)
+		}
+	}
+
+	authOptions.CaCertPath = caCertPath
+	authOptions.CaPrivateKeyPath = caKeyPath
+	authOptions.CertDir = filepath.Join(authOptions.StorePath, "certs")
+	authOptions.ClientCertPath = filepath.Join(authOptions.CertDir, "cert.pem")
+	authOptions.ClientKeyPath = filepath.Join(authOptions.CertDir, "key.pem")
+
+	return nil
+}
+
+// provisionScriptPath returns the absolute path of a provisioning script,
+// which is run again each time the machine is provisioned.
+func provisionScriptPath(scriptPath string) (string, error) {
+	if scriptPath == "" {
+		return "", nil
+	}
+
+	scriptPath, err := filepath.Abs(scriptPath)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(scriptPath)
+	if err != nil {
+		return "", fmt.Errorf("Error with the provisioning script: %s", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("Error with the provisioning script: %s is a directory", scriptPath)
+	}
+
+	return scriptPath, nil
+}
+
+func supportsCloudInit(driverName string) bool {
+	for _, name := range cloudInitDrivers {
+		if name == driverName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setSwarmMode sets the swarm mode role of a new machine, and the address
+// and join token of the manager machine it joins.
+func setSwarmMode(swarmOptions *swarm.Options, c CommandLine, api libmachine.API) error {
+	manager, worker, join := c.Bool("swarm-mode-manager"), c.Bool("swarm-mode-worker"), c.String("swarm-mode-join")
+	switch {
+	case manager && worker:
+		return errors.New("Error: --swarm-mode-manager and --swarm-mode-worker cannot be used together")
+	case manager:
+		swarmOptions.ModeRole = swarm.ModeManager
+	case worker:
+		swarmOptions.ModeRole = swarm.ModeWorker
+	case join != "":
+		return errors.New("Error: --swarm-mode-join needs --swarm-mode-manager or --swarm-mode-worker")
+	default:
+		return nil
+	}
+
+	if join == "" {
+		if worker {
+			return errors.New("Error: --swarm-mode-worker needs a manager to join with --swarm-mode-join")
+		}
+		return nil
+	}
+
+	h, err := api.Load(join)
+	if err != nil {
+		return fmt.Errorf("Error loading the swarm mode manager %q: %s", join, err)
+	}
+
+	var managerOptions *swarm.Options
+	if h.HostOptions != nil {
+		managerOptions = h.HostOptions.SwarmOptions
+	}
+	if managerOptions == nil || managerOptions.ModeRole != swarm.ModeManager {
+		return fmt.Errorf("Error: %q is not a swarm mode manager", join)
+	}
+
+	ip, err := h.Driver.GetIP()
+	if err != nil {
+		return fmt.Errorf("Error getting the IP address of %q: %s", join, err)
+	}
+
+	swarmOptions.ModeJoin = net.JoinHostPort(ip, strconv.Itoa(swarm.ModePort))
+	if manager {
+		swarmOptions.ModeManagerToken = managerOptions.ModeManagerToken
+	} else {
+		swarmOptions.ModeWorkerToken = managerOptions.ModeWorkerToken
+	}
+
+	return nil
+}
+
 func tlsPath(c CommandLine, flag string, defaultName string) string {
 	path := c.GlobalString(flag)
 	if path != "" {
@@ -1,12 +1,21 @@
 package commands
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"flag"
 
 	"github.com/leoh0/machine/commands/commandstest"
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/auth"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/libmachinetest"
 	"github.com/leoh0/machine/libmachine/mcnflag"
+	"github.com/leoh0/machine/libmachine/state"
+	"github.com/leoh0/machine/libmachine/swarm"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -118,3 +127,151 @@ func TestGetDriverOpts(t *testing.T) {
 		assert.Equal(t, tt.expected["stringslice_defaulted"], driverOpts.StringSlice("stringslice_defaulted"))
 	}
 }
+
+func TestCreateRejectsUnknownKeyTypes(t *testing.T) {
+	for _, flags := range []map[string]interface{}{
+		{"tls-key-type": "dsa"},
+		{"ssh-key-type": "dsa"},
+	} {
+		commandLine := &commandstest.FakeCommandLine{
+			CliArgs:    []string{"foo"},
+			LocalFlags: &commandstest.FakeFlagger{Data: flags},
+		}
+
+		err := cmdCreateInner(commandLine, &libmachinetest.FakeAPI{})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `key type "dsa"`)
+	}
+}
+
+func TestSetMachineCA(t *testing.T) {
+	authOptions := &auth.Options{
+		CertDir:        "/certs",
+		CaCertPath:     "/certs/ca.pem",
+		ClientCertPath: "/certs/cert.pem",
+		StorePath:      "/machines/foo",
+	}
+
+	err := setMachineCA(authOptions, "/corp/missing-ca.pem", "/corp/missing-ca-key.pem")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/corp/missing-ca.pem", authOptions.CaCertPath)
+	assert.Equal(t, "/corp/missing-ca-key.pem", authOptions.CaPrivateKeyPath)
+	assert.Equal(t, filepath.Join("/machines/foo", "certs"), authOptions.CertDir)
+	assert.Equal(t, filepath.Join("/machines/foo", "certs", "cert.pem"), authOptions.ClientCertPath)
+	assert.Equal(t, filepath.Join("/machines/foo", "certs", "key.pem"), authOptions.ClientKeyPath)
+}
+
+func TestSetMachineCANeedsCertAndKey(t *testing.T) {
+	authOptions := &auth.Options{CaCertPath: "/certs/ca.pem"}
+
+	assert.NoError(t, setMachineCA(authOptions, "", ""))
+	assert.Equal(t, "/certs/ca.pem", authOptions.CaCertPath)
+
+	assert.Error(t, setMachineCA(authOptions, "/corp/ca.pem", ""))
+}
+
+func TestProvisionScriptPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "setup.sh")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\n"), 0755))
+
+	path, err := provisionScriptPath(script)
+	assert.NoError(t, err)
+	assert.Equal(t, script, path)
+
+	path, err = provisionScriptPath("")
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+
+	_, err = provisionScriptPath(filepath.Join(dir, "missing.sh"))
+	assert.Error(t, err)
+
+	_, err = provisionScriptPath(dir)
+	assert.Error(t, err)
+}
+
+func TestSetSwarmMode(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "manager",
+				Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"},
+				HostOptions: &host.Options{
+					SwarmOptions: &swarm.Options{
+						ModeRole:         swarm.ModeManager,
+						ModeManagerToken: "SWMTKN-manager",
+						ModeWorkerToken:  "SWMTKN-worker",
+					},
+				},
+			},
+			{
+				Name:        "worker",
+				Driver:      &fakedriver.Driver{MockIP: "10.0.0.6"},
+				HostOptions: &host.Options{SwarmOptions: &swarm.Options{ModeRole: swarm.ModeWorker}},
+			},
+		},
+	}
+
+	cases := []struct {
+		flags    map[string]interface{}
+		expected swarm.Options
+	}{
+		{
+			map[string]interface{}{},
+			swarm.Options{},
+		},
+		{
+			map[string]interface{}{"swarm-mode-manager": true},
+			swarm.Options{ModeRole: swarm.ModeManager},
+		},
+		{
+			map[string]interface{}{"swarm-mode-worker": true, "swarm-mode-join": "manager"},
+			swarm.Options{ModeRole: swarm.ModeWorker, ModeJoin: "10.0.0.5:2377", ModeWorkerToken: "SWMTKN-worker"},
+		},
+		{
+			map[string]interface{}{"swarm-mode-manager": true, "swarm-mode-join": "manager"},
+			swarm.Options{ModeRole: swarm.ModeManager, ModeJoin: "10.0.0.5:2377", ModeManagerToken: "SWMTKN-manager"},
+		},
+	}
+
+	for _, c := range cases {
+		commandLine := &commandstest.FakeCommandLine{
+			LocalFlags: &commandstest.FakeFlagger{Data: c.flags},
+		}
+		swarmOptions := &swarm.Options{}
+
+		assert.NoError(t, setSwarmMode(swarmOptions, commandLine, api))
+		assert.Equal(t, c.expected, *swarmOptions)
+	}
+}
+
+func TestSetSwarmModeErrors(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:        "worker",
+				Driver:      &fakedriver.Driver{MockIP: "10.0.0.6"},
+				HostOptions: &host.Options{SwarmOptions: &swarm.Options{ModeRole: swarm.ModeWorker}},
+			},
+		},
+	}
+
+	for _, flags := range []map[string]interface{}{
+		{"swarm-mode-manager": true, "swarm-mode-worker": true},
+		{"swarm-mode-worker": true},
+		{"swarm-mode-join": "worker"},
+		{"swarm-mode-worker": true, "swarm-mode-join": "worker"},
+		{"swarm-mode-worker": true, "swarm-mode-join": "missing"},
+	} {
+		commandLine := &commandstest.FakeCommandLine{
+			LocalFlags: &commandstest.FakeFlagger{Data: flags},
+		}
+
+		assert.Error(t, setSwarmMode(&swarm.Options{}, commandLine, api), "%v", flags)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -44,6 +45,40 @@ type ShellConfig struct {
 	ComposePathsVar bool
 }
 
+// EnvResult is the result of the env command: the variables to set, or
+// the variables to unset with --unset.
+type EnvResult struct {
+	Set   map[string]string `json:"set,omitempty"`
+	Unset []string          `json:"unset,omitempty"`
+}
+
+func newEnvResult(shellCfg *ShellConfig, unset bool) EnvResult {
+	vars := map[string]string{
+		"DOCKER_TLS_VERIFY":   shellCfg.DockerTLSVerify,
+		"DOCKER_HOST":         shellCfg.DockerHost,
+		"DOCKER_CERT_PATH":    shellCfg.DockerCertPath,
+		"DOCKER_MACHINE_NAME": shellCfg.MachineName,
+	}
+	if shellCfg.ComposePathsVar {
+		vars["COMPOSE_CONVERT_WINDOWS_PATHS"] = "true"
+	}
+	if shellCfg.NoProxyVar != "" {
+		vars[shellCfg.NoProxyVar] = shellCfg.NoProxyValue
+	}
+
+	if !unset {
+		return EnvResult{Set: vars}
+	}
+
+	names := []string{}
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return EnvResult{Unset: names}
+}
+
 func cmdEnv(c CommandLine, api libmachine.API) error {
 	var (
 		err      error
@@ -66,6 +101,11 @@ func cmdEnv(c CommandLine, api libmachine.API) error {
 		}
 	}
 
+	if output.isJSON() {
+		output.printResult(newEnvResult(shellCfg, c.Bool("unset")), nil)
+		return nil
+	}
+
 	return executeTemplateStdout(shellCfg)
 }
 
@@ -84,6 +124,8 @@ func shellCfgSet(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 		return nil, err
 	}
 
+	checkCertsExpiry(c, host)
+
 	dockerHost, _, err := check.DefaultConnChecker.Check(host, c.Bool("swarm"))
 	if err != nil {
 		return nil, fmt.Errorf("Error checking TLS connection: %s", err)
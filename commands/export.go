@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/persist"
+)
+
+// ExportResult is the result of the export command.
+type ExportResult struct {
+	Name    string `json:"name"`
+	Archive string `json:"archive"`
+}
+
+func cmdExport(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	archivePath := c.String("output")
+	if archivePath == "" {
+		archivePath = target + ".tar.gz"
+	}
+
+	// The archive holds private keys.
+	f, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("Error creating archive: %s", err)
+	}
+
+	if err := persist.Export(api, target, f); err != nil {
+		f.Close()
+		os.Remove(archivePath)
+		return fmt.Errorf("Error exporting machine %q: %s", target, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	output.printResult(ExportResult{
+		Name:    target,
+		Archive: archivePath,
+	}, func() {
+		log.Infof("Machine %q exported to %s", target, archivePath)
+	})
+
+	return nil
+}
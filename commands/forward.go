@@ -0,0 +1,478 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/leoh0/machine/commands/mcndirs"
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/persist"
+	"github.com/leoh0/machine/libmachine/ssh"
+)
+
+const (
+	forwardTypePort  = "port-forward"
+	forwardTypeProxy = "proxy"
+
+	// forwardIDEnv tells a process started with --background its ID.
+	forwardIDEnv = "MACHINE_FORWARD_ID"
+)
+
+var (
+	errExpectedMachineAndForwards = errors.New("Error: Expected a machine name and at least one [bind_address:]port:[host:]hostport as arguments")
+	errExpectedForwardIDs         = errors.New("Error: Expected the IDs of port forwards or proxies, or --all")
+
+	forwardsWriter io.Writer = os.Stdout
+
+	// forwardStartTimeout is how long a process started with --background
+	// has to start listening.
+	forwardStartTimeout = 30 * time.Second
+)
+
+// ForwardResult describes a port forward or a proxy running in the
+// background.
+type ForwardResult struct {
+	ID       string    `json:"id"`
+	PID      int       `json:"pid"`
+	Machine  string    `json:"machine"`
+	Type     string    `json:"type"`
+	Forwards []string  `json:"forwards"`
+	Started  time.Time `json:"started"`
+}
+
+// portForward forwards the local address Bind to the address Remote, as
+// seen from the machine.
+type portForward struct {
+	Bind   string
+	Remote string
+}
+
+func (f portForward) String() string {
+	return fmt.Sprintf("%s -> %s", f.Bind, f.Remote)
+}
+
+// parsePortForward parses [bind_address:]port:[host:]hostport, like the -L
+// option of ssh. The local port is bound to 127.0.0.1 and host is localhost
+// by default.
+func parsePortForward(spec string) (portForward, error) {
+	parts := strings.Split(spec, ":")
+
+	var bind, port, host, hostPort string
+	switch len(parts) {
+	case 2:
+		bind, port, host, hostPort = "127.0.0.1", parts[0], "localhost", parts[1]
+	case 3:
+		bind, port, host, hostPort = "127.0.0.1", parts[0], parts[1], parts[2]
+	case 4:
+		bind, port, host, hostPort = parts[0], parts[1], parts[2], parts[3]
+	default:
+		return portForward{}, fmt.Errorf("Invalid port forward %q, expected [bind_address:]port:[host:]hostport", spec)
+	}
+
+	for _, p := range []string{port, hostPort} {
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return portForward{}, fmt.Errorf("Invalid port %q in the port forward %q", p, spec)
+		}
+	}
+
+	return portForward{
+		Bind:   net.JoinHostPort(bind, port),
+		Remote: net.JoinHostPort(host, hostPort),
+	}, nil
+}
+
+func cmdPortForward(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) < 2 {
+		return errExpectedMachineAndForwards
+	}
+	machineName := c.Args()[0]
+
+	forwards := []portForward{}
+	for _, spec := range c.Args()[1:] {
+		f, err := parsePortForward(spec)
+		if err != nil {
+			return err
+		}
+		forwards = append(forwards, f)
+	}
+
+	if runInBackground(c) {
+		return startInBackground()
+	}
+
+	client, err := forwardClient(api, machineName)
+	if err != nil {
+		return err
+	}
+
+	result := &ForwardResult{Machine: machineName, Type: forwardTypePort}
+	listeners := []net.Listener{}
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	for _, f := range forwards {
+		l, err := net.Listen("tcp", f.Bind)
+		if err != nil {
+			return fmt.Errorf("Error listening on %s: %s", f.Bind, err)
+		}
+		listeners = append(listeners, l)
+		result.Forwards = append(result.Forwards, f.String())
+		log.Infof("Forwarding %s", f)
+	}
+
+	return serveForwards(c.CommandContext(), result, len(forwards), func(ctx context.Context, i int) error {
+		return client.Forward(ctx, listeners[i], forwards[i].Remote)
+	})
+}
+
+func cmdProxy(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+	machineName := c.Args()[0]
+
+	if runInBackground(c) {
+		return startInBackground()
+	}
+
+	client, err := forwardClient(api, machineName)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", c.String("address"))
+	if err != nil {
+		return fmt.Errorf("Error listening on %s: %s", c.String("address"), err)
+	}
+	defer l.Close()
+
+	proxy := fmt.Sprintf("socks5://%s", l.Addr())
+	log.Infof("Running a SOCKS5 proxy to the network of %q on %s", machineName, proxy)
+
+	result := &ForwardResult{Machine: machineName, Type: forwardTypeProxy, Forwards: []string{proxy}}
+	return serveForwards(c.CommandContext(), result, 1, func(ctx context.Context, i int) error {
+		return client.ServeSOCKS(ctx, l)
+	})
+}
+
+func forwardClient(api libmachine.API, machineName string) (*ssh.NativeClient, error) {
+	h, err := api.Load(machineName)
+	if err != nil {
+		return nil, err
+	}
+
+	return nativeClientFor(h.Driver, "")
+}
+
+// serveForwards runs the n forwards concurrently until ctx is done or one of
+// them fails. In the background, result is recorded for the forwards
+// command while they run.
+func serveForwards(ctx context.Context, result *ForwardResult, n int, serveFn func(ctx context.Context, i int) error) error {
+	if id := os.Getenv(forwardIDEnv); id != "" {
+		lock, err := lockForward(id)
+		if err != nil {
+			return err
+		}
+		defer removeForward(id)
+		defer lock.Close()
+
+		result.ID = id
+		result.PID = os.Getpid()
+		result.Started = time.Now()
+		if err := saveForward(result); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := serveFn(ctx, i); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// runInBackground tells if the command must start itself in the background,
+// which it is not already in.
+func runInBackground(c CommandLine) bool {
+	return c.Bool("background") && os.Getenv(forwardIDEnv) == ""
+}
+
+// startInBackground starts the same command again in the background, and
+// waits for it to listen.
+func startInBackground() error {
+	id, err := newForwardID()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(forwardsDir(), 0700); err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	logPath := filepath.Join(forwardsDir(), id+".log")
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), forwardIDEnv+"="+id)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Error starting in the background: %s", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	timeout := time.After(forwardStartTimeout)
+	for {
+		if _, err := os.Stat(forwardPath(id)); err == nil {
+			fmt.Fprintln(forwardsWriter, id)
+			log.Infof("Running in the background, stop it with `docker-machine forwards stop %s`", id)
+			return nil
+		}
+
+		select {
+		case <-exited:
+			return fmt.Errorf("Error starting in the background, see %s", logPath)
+		case <-timeout:
+			stopProcess(cmd.Process)
+			return fmt.Errorf("Error starting in the background: not ready after %s, see %s", forwardStartTimeout, logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func newForwardID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func forwardsDir() string {
+	return filepath.Join(mcndirs.GetBaseDir(), "forwards")
+}
+
+func forwardPath(id string) string {
+	return filepath.Join(forwardsDir(), id+".json")
+}
+
+func forwardLockPath(id string) string {
+	return filepath.Join(forwardsDir(), id+".lock")
+}
+
+// lockForward takes the lock the process running a forward in the
+// background holds until it exits, which tells the other processes it is
+// still there.
+func lockForward(id string) (*os.File, error) {
+	if err := os.MkdirAll(forwardsDir(), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(forwardLockPath(id), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := persist.TryLockFile(f)
+	if err == nil && !locked {
+		err = fmt.Errorf("Error: %s is already running", id)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// forwardRunning tells if the process of the forward id is still running.
+// Its lock is checked rather than its PID, which may have been given to
+// another process since.
+func forwardRunning(id string) bool {
+	f, err := os.OpenFile(forwardLockPath(id), os.O_RDWR, 0600)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	locked, err := persist.TryLockFile(f)
+	return err == nil && !locked
+}
+
+func saveForward(result *ForwardResult) error {
+	data, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(forwardsDir(), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(forwardPath(result.ID), data, 0600)
+}
+
+func removeForward(id string) {
+	os.Remove(forwardPath(id))
+	os.Remove(filepath.Join(forwardsDir(), id+".log"))
+	os.Remove(forwardLockPath(id))
+}
+
+// loadForwards returns the port forwards and proxies running in the
+// background. The ones whose process is gone are forgotten.
+func loadForwards() ([]*ForwardResult, error) {
+	paths, err := filepath.Glob(filepath.Join(forwardsDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*ForwardResult{}
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		result := &ForwardResult{}
+		if err := json.Unmarshal(data, result); err != nil {
+			log.Debugf("Error reading %s: %s", p, err)
+			continue
+		}
+
+		if !forwardRunning(result.ID) {
+			removeForward(result.ID)
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func cmdForwardsLs(c CommandLine, api libmachine.API) error {
+	results, err := loadForwards()
+	if err != nil {
+		return err
+	}
+
+	if output.isJSON() {
+		output.printResult(results, nil)
+		return nil
+	}
+
+	if c.Bool("quiet") {
+		for _, result := range results {
+			fmt.Fprintln(forwardsWriter, result.ID)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(forwardsWriter, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tMACHINE\tTYPE\tPID\tFORWARDS")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", result.ID, result.Machine, result.Type, result.PID, strings.Join(result.Forwards, ", "))
+	}
+
+	return w.Flush()
+}
+
+func cmdForwardsStop(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) == 0 && !c.Bool("all") {
+		return errExpectedForwardIDs
+	}
+
+	results, err := loadForwards()
+	if err != nil {
+		return err
+	}
+
+	running := map[string]*ForwardResult{}
+	for _, result := range results {
+		running[result.ID] = result
+	}
+
+	ids := c.Args()
+	if c.Bool("all") {
+		ids = []string{}
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+	}
+
+	for _, id := range ids {
+		result, ok := running[id]
+		if !ok {
+			return fmt.Errorf("Error: No port forward or proxy %q is running in the background", id)
+		}
+
+		// The process may have exited since it was listed.
+		if !forwardRunning(id) {
+			removeForward(id)
+			continue
+		}
+
+		process, err := os.FindProcess(result.PID)
+		if err != nil {
+			return err
+		}
+		if err := stopProcess(process); err != nil {
+			return fmt.Errorf("Error stopping %s: %s", id, err)
+		}
+		removeForward(id)
+
+		log.Infof("Stopped %s of %q (%s)", result.Type, result.Machine, strings.Join(result.Forwards, ", "))
+	}
+
+	return nil
+}
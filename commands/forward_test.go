@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/leoh0/machine/commands/commandstest"
+	"github.com/leoh0/machine/commands/mcndirs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePortForward(t *testing.T) {
+	cases := []struct {
+		spec     string
+		expected portForward
+	}{
+		{"8080:80", portForward{Bind: "127.0.0.1:8080", Remote: "localhost:80"}},
+		{"5432:db:5432", portForward{Bind: "127.0.0.1:5432", Remote: "db:5432"}},
+		{"0.0.0.0:8080:10.0.0.5:80", portForward{Bind: "0.0.0.0:8080", Remote: "10.0.0.5:80"}},
+	}
+
+	for _, c := range cases {
+		f, err := parsePortForward(c.spec)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, f)
+	}
+
+	for _, spec := range []string{"8080", "http:80", "8080:0", "8080:70000", "a:b:c:d:e"} {
+		_, err := parsePortForward(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestPortForwardNeedsForwards(t *testing.T) {
+	err := cmdPortForward(&commandstest.FakeCommandLine{CliArgs: []string{"default"}}, nil)
+
+	assert.Equal(t, errExpectedMachineAndForwards, err)
+}
+
+func withForwardsDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "machine-forwards")
+	assert.NoError(t, err)
+
+	baseDir := mcndirs.BaseDir
+	mcndirs.BaseDir = dir
+	return func() {
+		mcndirs.BaseDir = baseDir
+		os.RemoveAll(dir)
+	}
+}
+
+func TestForwardsLs(t *testing.T) {
+	defer withForwardsDir(t)()
+
+	lock, err := lockForward("running")
+	assert.NoError(t, err)
+	defer lock.Close()
+
+	assert.NoError(t, saveForward(&ForwardResult{ID: "running", PID: os.Getpid(), Machine: "default", Type: forwardTypePort, Forwards: []string{"127.0.0.1:8080 -> localhost:80"}}))
+	// The process of "recycled" is gone, its PID is now another process.
+	assert.NoError(t, saveForward(&ForwardResult{ID: "recycled", PID: os.Getpid(), Machine: "default", Type: forwardTypeProxy}))
+
+	var out bytes.Buffer
+	forwardsWriter = &out
+	defer func() { forwardsWriter = os.Stdout }()
+
+	err = cmdForwardsLs(&commandstest.FakeCommandLine{}, nil)
+
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "127.0.0.1:8080 -> localhost:80")
+	assert.NotContains(t, out.String(), "recycled")
+
+	_, err = os.Stat(forwardPath("recycled"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestForwardsStopUnknownID(t *testing.T) {
+	defer withForwardsDir(t)()
+
+	err := cmdForwardsStop(&commandstest.FakeCommandLine{CliArgs: []string{"unknown"}}, nil)
+	assert.EqualError(t, err, `Error: No port forward or proxy "unknown" is running in the background`)
+
+	err = cmdForwardsStop(&commandstest.FakeCommandLine{}, nil)
+	assert.Equal(t, errExpectedForwardIDs, err)
+}
@@ -0,0 +1,20 @@
+// +build !windows
+
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detachProcess keeps cmd running once the terminal which started it is
+// closed.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// stopProcess interrupts p, which stops like on Ctrl-C.
+func stopProcess(p *os.Process) error {
+	return p.Signal(os.Interrupt)
+}
@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detachProcess keeps cmd running once the console which started it is
+// closed.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// stopProcess kills p, which cannot be interrupted on Windows.
+func stopProcess(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/leoh0/machine/commands/mcndirs"
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/persist"
+)
+
+var (
+	errExpectedOneArchive = errors.New("Error: Expected one archive as an argument")
+)
+
+func cmdImport(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		c.ShowHelp()
+		return errExpectedOneArchive
+	}
+
+	f, err := os.Open(c.Args().First())
+	if err != nil {
+		return fmt.Errorf("Error opening archive: %s", err)
+	}
+	defer f.Close()
+
+	h, err := persist.Import(api, mcndirs.GetMachineCertDir(), f)
+	if err != nil {
+		return fmt.Errorf("Error importing machine: %w", err)
+	}
+
+	log.Infof("Machine %q imported. Run '%s env %s' to use it.", h.Name, os.Args[0], h.Name)
+
+	return nil
+}
@@ -36,6 +36,11 @@ func cmdInspect(c CommandLine, api libmachine.API) error {
 		return err
 	}
 
+	if output.isJSON() {
+		output.printResult(host, nil)
+		return nil
+	}
+
 	tmplString := c.String("format")
 	if tmplString != "" {
 		var tmpl *template.Template
@@ -42,6 +42,7 @@ var (
 		"URL":           "URL",
 		"SwarmOptions":  "SWARM_OPTIONS",
 		"Swarm":         "SWARM",
+		"SwarmMode":     "SWARM_MODE",
 		"EngineOptions": "ENGINE_OPTIONS",
 		"Error":         "ERRORS",
 		"DockerVersion": "DOCKER",
@@ -59,12 +60,25 @@ type HostListItem struct {
 	URL           string
 	SwarmOptions  *swarm.Options
 	Swarm         string
+	SwarmMode     string
 	EngineOptions *engine.Options
 	Error         string
 	DockerVersion string
 	ResponseTime  time.Duration
 }
 
+// LsResult is the result of the ls command for one machine.
+type LsResult struct {
+	Name          string `json:"name"`
+	Active        bool   `json:"active"`
+	Driver        string `json:"driver"`
+	State         string `json:"state"`
+	URL           string `json:"url"`
+	SwarmMode     string `json:"swarmMode"`
+	DockerVersion string `json:"dockerVersion"`
+	Error         string `json:"error"`
+}
+
 // FilterOptions -
 type FilterOptions struct {
 	SwarmName  []string
@@ -87,6 +101,29 @@ func cmdLs(c CommandLine, api libmachine.API) error {
 
 	hostList = filterHosts(hostList, filters)
 
+	if !c.Bool("quiet") {
+		checkCertsExpiry(c, hostList...)
+	}
+
+	if output.isJSON() {
+		timeout := time.Duration(c.Int("timeout")) * time.Second
+		results := []LsResult{}
+		for _, item := range getHostListItems(hostList, hostInError, timeout) {
+			results = append(results, LsResult{
+				Name:          item.Name,
+				Active:        item.ActiveHost || item.ActiveSwarm,
+				Driver:        item.DriverName,
+				State:         item.State.String(),
+				URL:           item.URL,
+				SwarmMode:     item.SwarmMode,
+				DockerVersion: item.DockerVersion,
+				Error:         item.Error,
+			})
+		}
+		output.printResult(results, nil)
+		return nil
+	}
+
 	// Just print out the names if we're being quiet
 	if c.Bool("quiet") {
 		for _, host := range hostList {
@@ -141,6 +178,9 @@ func cmdLs(c CommandLine, api libmachine.API) error {
 				swarmColumn = fmt.Sprintf("%s (master)", swarmColumn)
 			}
 		}
+		if item.SwarmMode != "" {
+			swarmColumn = strings.TrimSpace(fmt.Sprintf("%s %s (swarm mode)", swarmColumn, item.SwarmMode))
+		}
 		item.Swarm = swarmColumn
 
 		if err := template.Execute(w, item); err != nil {
@@ -384,9 +424,11 @@ func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 
 	isMaster := false
 	swarmHost := ""
+	swarmMode := ""
 	if swarmOptions != nil {
 		isMaster = swarmOptions.Master
 		swarmHost = swarmOptions.Host
+		swarmMode = swarmOptions.ModeRole
 	}
 
 	activeHost := isActive(currentState, url)
@@ -408,6 +450,7 @@ func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 		State:         currentState,
 		URL:           url,
 		SwarmOptions:  swarmOptions,
+		SwarmMode:     swarmMode,
 		EngineOptions: engineOptions,
 		DockerVersion: dockerVersion,
 		Error:         hostError,
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"github.com/leoh0/machine/commands/mcndirs"
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/persist"
+)
+
+func cmdMigrateStore(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 0 {
+		return ErrTooManyArguments
+	}
+
+	from, err := persist.NewStore(c.String("from"), mcndirs.GetBaseDir(), mcndirs.GetMachineCertDir(), mcndirs.GetMachineCertDir())
+	if err != nil {
+		return err
+	}
+
+	migrated, err := persist.Migrate(from, api)
+	for _, hostName := range migrated {
+		log.Infof("Migrated %q", hostName)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infof("%d machine(s) migrated from the %s storage driver", len(migrated), c.String("from"))
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/leoh0/machine/libmachine"
 	"github.com/leoh0/machine/libmachine/log"
@@ -35,6 +36,18 @@ func cmdMount(c CommandLine, api libmachine.API) error {
 
 	hostInfoLoader := &storeHostInfoLoader{api}
 
+	if c.Bool("sync") {
+		if c.Bool("unmount") {
+			return errors.New("The --sync option does not mount anything, stop it with Ctrl-C instead")
+		}
+
+		interval := time.Duration(c.Int("sync-interval")) * time.Second
+		if interval <= 0 {
+			interval = time.Second
+		}
+		return syncMount(c.CommandContext(), src, dest, interval, hostInfoLoader)
+	}
+
 	cmd, err := getMountCmd(src, dest, c.Bool("unmount"), hostInfoLoader)
 	if err != nil {
 		return err
@@ -115,7 +128,7 @@ func getInfoForSshfsArg(hostAndPath string, hostInfoLoader HostInfoLoader) (h Ho
 	// Remote path
 	h, err = hostInfoLoader.load(hostName)
 	if err != nil {
-		return nil, "", "", nil, fmt.Errorf("Error loading host: %s", err)
+		return nil, "", "", nil, fmt.Errorf("Error loading host: %w", err)
 	}
 
 	args = []string{}
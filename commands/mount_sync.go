@@ -0,0 +1,295 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/ssh"
+)
+
+// syncedFile is what a sync remembers of a local file to notice its
+// changes.
+type syncedFile struct {
+	Dir     bool
+	Mode    os.FileMode
+	Size    int64
+	ModTime time.Time
+}
+
+// dirSync pushes the changes of a local directory to a directory of a
+// machine, as a replacement of SSHFS which needs no FUSE.
+type dirSync struct {
+	client *ssh.NativeClient
+	local  string
+	remote string
+	// files are the local files as of the last push, by slash separated
+	// path relative to local.
+	files map[string]syncedFile
+}
+
+func syncMount(ctx context.Context, src, dest string, interval time.Duration, hostInfoLoader HostInfoLoader) error {
+	h, user, remotePath, _, err := getInfoForSshfsArg(src, hostInfoLoader)
+	if err != nil {
+		return err
+	}
+
+	if dest == "" {
+		dest = remotePath
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("Error: %s is not a directory", dest)
+	}
+
+	client, err := nativeClientFor(h, user)
+	if err != nil {
+		return err
+	}
+
+	s := &dirSync{
+		client: client,
+		local:  dest,
+		remote: remotePath,
+	}
+
+	log.Infof("Syncing %s to %s:%s, press Ctrl-C to stop", dest, h.GetMachineName(), remotePath)
+
+	if err := s.pushAll(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// The changes not pushed are found again on the next tick.
+			if err := s.pushChanges(); err != nil {
+				log.Warnf("Error syncing %s, retrying: %s", dest, err)
+			}
+		}
+	}
+}
+
+// pushAll sends the local files which differ from the remote ones. Remote
+// files missing locally are kept.
+func (s *dirSync) pushAll() error {
+	files, err := scanDir(s.local)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.client.Output("mkdir -p " + ssh.ShellQuote(s.remote)); err != nil {
+		return fmt.Errorf("Error creating %s on the machine: %s", s.remote, err)
+	}
+
+	remoteSums, err := checksums(s.client, s.remote)
+	if err != nil {
+		return fmt.Errorf("Error computing the checksums of %s: %s", s.remote, err)
+	}
+
+	changed := []string{}
+	for _, rel := range sortedPaths(files) {
+		if files[rel].Dir {
+			changed = append(changed, rel)
+			continue
+		}
+
+		sum, err := fileChecksum(filepath.Join(s.local, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		if remoteSums[path.Join(s.remote, rel)] != sum {
+			changed = append(changed, rel)
+		}
+	}
+
+	if err := s.push(files, changed, nil); err != nil {
+		return err
+	}
+
+	s.files = files
+	return nil
+}
+
+// pushChanges sends the local files changed since the last push, and
+// removes the deleted ones from the machine.
+func (s *dirSync) pushChanges() error {
+	files, err := scanDir(s.local)
+	if err != nil {
+		return err
+	}
+
+	changed, removed := diffFiles(s.files, files)
+	if err := s.push(files, changed, removed); err != nil {
+		return err
+	}
+
+	s.files = files
+	return nil
+}
+
+func (s *dirSync) push(files map[string]syncedFile, changed, removed []string) error {
+	if len(removed) > 0 {
+		targets := []string{}
+		for _, rel := range removed {
+			log.Infof("Removing %s", rel)
+			targets = append(targets, ssh.ShellQuote(path.Join(s.remote, rel)))
+		}
+
+		if _, err := s.client.Output("rm -rf " + strings.Join(targets, " ")); err != nil {
+			return fmt.Errorf("Error removing files from the machine: %s", err)
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	w, err := s.client.CopyTo(s.remote)
+	if err != nil {
+		return err
+	}
+
+	pushErr := s.write(w, files, changed)
+	closeErr := w.Close()
+	if pushErr != nil {
+		return pushErr
+	}
+
+	return closeErr
+}
+
+// write sends the changed files. The files which change while being sent
+// are dropped from files, so that they are pushed again next time.
+func (s *dirSync) write(w ssh.CopyWriter, files map[string]syncedFile, changed []string) error {
+	for _, rel := range changed {
+		file := files[rel]
+		if file.Dir {
+			if err := w.Mkdir(rel, file.Mode); err != nil {
+				return err
+			}
+			continue
+		}
+
+		log.Infof("Pushing %s", rel)
+
+		f, err := os.Open(filepath.Join(s.local, filepath.FromSlash(rel)))
+		if err != nil {
+			// The file was removed since the scan, it will be next time.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		info, err := f.Stat()
+		if err != nil || info.Size() != file.Size {
+			f.Close()
+			log.Debugf("%s changed since the scan, pushing it next time", rel)
+			delete(files, rel)
+			continue
+		}
+
+		err = w.WriteFile(rel, file.Mode, file.Size, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanDir returns the directories and regular files under root.
+func scanDir(root string) (map[string]syncedFile, error) {
+	files := map[string]syncedFile{}
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		// Files removed during the scan are left out.
+		if os.IsNotExist(err) && p != root {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if p == root || !(info.IsDir() || info.Mode().IsRegular()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(rel)] = syncedFile{
+			Dir:     info.IsDir(),
+			Mode:    info.Mode().Perm(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// diffFiles returns the files which are new or modified in current, and
+// the ones removed or replaced since previous. The contents of removed
+// directories are left out since removing the directories is enough.
+func diffFiles(previous, current map[string]syncedFile) ([]string, []string) {
+	changed := []string{}
+	for _, rel := range sortedPaths(current) {
+		file := current[rel]
+		old, ok := previous[rel]
+		if !ok || old.Dir != file.Dir || old.Mode != file.Mode || (!file.Dir && (old.Size != file.Size || !old.ModTime.Equal(file.ModTime))) {
+			changed = append(changed, rel)
+		}
+	}
+
+	// A file replaced by a directory, or the other way around, is removed
+	// first.
+	removed := []string{}
+	for _, rel := range sortedPaths(previous) {
+		if file, ok := current[rel]; ok && file.Dir == previous[rel].Dir {
+			continue
+		}
+
+		parentRemoved := false
+		for _, r := range removed {
+			if strings.HasPrefix(rel, r+"/") {
+				parentRemoved = true
+				break
+			}
+		}
+		if !parentRemoved {
+			removed = append(removed, rel)
+		}
+	}
+
+	return changed, removed
+}
+
+func sortedPaths(files map[string]syncedFile) []string {
+	paths := []string{}
+	for rel := range files {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
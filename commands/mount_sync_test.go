@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "sub", "a.txt"), []byte("abc"), 0600))
+
+	files, err := scanDir(tmpDir)
+
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.True(t, files["sub"].Dir)
+	assert.Equal(t, int64(3), files["sub/a.txt"].Size)
+	assert.Equal(t, os.FileMode(0600), files["sub/a.txt"].Mode)
+}
+
+func TestDiffFiles(t *testing.T) {
+	now := time.Now()
+	previous := map[string]syncedFile{
+		"same.txt":     {Mode: 0644, Size: 1, ModTime: now},
+		"modified.txt": {Mode: 0644, Size: 1, ModTime: now},
+		"removed":      {Dir: true, Mode: 0755},
+		"removed/a":    {Mode: 0644, Size: 1, ModTime: now},
+		"replaced":     {Mode: 0644, Size: 1, ModTime: now},
+	}
+	current := map[string]syncedFile{
+		"same.txt":     {Mode: 0644, Size: 1, ModTime: now},
+		"modified.txt": {Mode: 0644, Size: 1, ModTime: now.Add(time.Second)},
+		"new.txt":      {Mode: 0644, Size: 1, ModTime: now},
+		"replaced":     {Dir: true, Mode: 0755},
+	}
+
+	changed, removed := diffFiles(previous, current)
+
+	assert.Equal(t, []string{"modified.txt", "new.txt", "replaced"}, changed)
+	assert.Equal(t, []string{"removed", "replaced"}, removed)
+}
+
+// recordingCopyWriter records the paths written to it.
+type recordingCopyWriter struct {
+	written []string
+}
+
+func (w *recordingCopyWriter) Mkdir(path string, mode os.FileMode) error {
+	w.written = append(w.written, path+"/")
+	return nil
+}
+
+func (w *recordingCopyWriter) WriteFile(path string, mode os.FileMode, size int64, r io.Reader) error {
+	w.written = append(w.written, path)
+	_, err := io.CopyN(ioutil.Discard, r, size)
+	return err
+}
+
+func TestDirSyncWriteSkipsChangedFiles(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "same.txt"), []byte("abc"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "grown.txt"), []byte("abcdef"), 0600))
+
+	files := map[string]syncedFile{
+		"same.txt":    {Mode: 0600, Size: 3},
+		"grown.txt":   {Mode: 0600, Size: 3},
+		"removed.txt": {Mode: 0600, Size: 3},
+	}
+	w := &recordingCopyWriter{}
+	s := &dirSync{local: tmpDir}
+
+	err = s.write(w, files, []string{"grown.txt", "removed.txt", "same.txt"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"same.txt"}, w.written)
+	// The grown file is pushed again next time, the removed one is removed.
+	assert.NotContains(t, files, "grown.txt")
+	assert.Contains(t, files, "removed.txt")
+}
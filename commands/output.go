@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/leoh0/machine/libmachine/crashreport"
+	"github.com/leoh0/machine/libmachine/mcnerror"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var (
+	output = newTextOutput(os.Stdout)
+
+	mcnerrorPkgPath = reflect.TypeOf(mcnerror.ErrHostDoesNotExist{}).PkgPath()
+
+	// mcnerrorValues names the mcnerror errors which are values rather
+	// than types.
+	mcnerrorValues = map[error]string{
+		mcnerror.ErrInvalidHostname: "ErrInvalidHostname",
+	}
+)
+
+// commandOutput prints what a command produces. In the default text mode
+// every command prints its own format. With --output json, the results are
+// collected and written as a single JSON document once the command returns.
+type commandOutput struct {
+	sync.Mutex
+	format string
+	w      io.Writer
+	result interface{}
+	items  []interface{}
+}
+
+// jsonDocument is written on stdout by every command in --output json mode.
+type jsonDocument struct {
+	Command string       `json:"command"`
+	Result  interface{}  `json:"result"`
+	Error   *ErrorResult `json:"error,omitempty"`
+}
+
+// ErrorResult describes an error in --output json mode. Type is the name of
+// the mcnerror type of the error, or of the mcnerror error it wraps, or
+// "Error" for other errors.
+type ErrorResult struct {
+	Type    string       `json:"type"`
+	Message string       `json:"message"`
+	Details interface{}  `json:"details,omitempty"`
+	Cause   *ErrorResult `json:"cause,omitempty"`
+}
+
+func newTextOutput(w io.Writer) *commandOutput {
+	return &commandOutput{
+		format: outputText,
+		w:      w,
+	}
+}
+
+func newCommandOutput(format string, w io.Writer) (*commandOutput, error) {
+	switch format {
+	case "", outputText:
+		return newTextOutput(w), nil
+	case outputJSON:
+		return &commandOutput{
+			format: outputJSON,
+			w:      w,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Error: Unknown output format %q, expected %s or %s", format, outputText, outputJSON)
+}
+
+func (o *commandOutput) isJSON() bool {
+	return o.format == outputJSON
+}
+
+// printResult records the result of a command in --output json mode, and
+// calls printText otherwise.
+func (o *commandOutput) printResult(result interface{}, printText func()) {
+	if !o.isJSON() {
+		printText()
+		return
+	}
+
+	o.Lock()
+	defer o.Unlock()
+	o.result = result
+}
+
+// appendResult is printResult for commands which act on several machines.
+// Their result is a list with one item per machine.
+func (o *commandOutput) appendResult(item interface{}, printText func()) {
+	if !o.isJSON() {
+		printText()
+		return
+	}
+
+	o.Lock()
+	defer o.Unlock()
+	o.items = append(o.items, item)
+}
+
+// flush writes the JSON document holding the result and the error of a
+// command.
+func (o *commandOutput) flush(command string, err error) error {
+	o.Lock()
+	defer o.Unlock()
+
+	doc := jsonDocument{
+		Command: command,
+		Result:  o.result,
+	}
+	if o.items != nil {
+		doc.Result = o.items
+	}
+	if err != nil {
+		doc.Error = newErrorResult(err)
+	}
+
+	data, marshalErr := json.MarshalIndent(doc, "", "    ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	_, writeErr := fmt.Fprintln(o.w, string(data))
+	return writeErr
+}
+
+func newErrorResult(err error) *ErrorResult {
+	if crashErr, ok := err.(crashreport.CrashError); ok {
+		err = crashErr.Cause
+	}
+
+	result := &ErrorResult{
+		Type:    "Error",
+		Message: err.Error(),
+	}
+
+	// The mcnerror errors are typed even when wrapped with some context.
+	for cause := err; cause != nil; cause = errors.Unwrap(cause) {
+		if name, ok := mcnerrorValues[cause]; ok {
+			result.Type = name
+			return result
+		}
+
+		t := reflect.TypeOf(cause)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.PkgPath() != mcnerrorPkgPath {
+			continue
+		}
+
+		result.Type = t.Name()
+		if preCreateErr, ok := cause.(mcnerror.ErrDuringPreCreate); ok {
+			result.Cause = newErrorResult(preCreateErr.Cause)
+		} else {
+			result.Details = cause
+		}
+		return result
+	}
+
+	return result
+}
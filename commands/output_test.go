@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/leoh0/machine/commands/commandstest"
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/crashreport"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/libmachinetest"
+	"github.com/leoh0/machine/libmachine/mcnerror"
+	"github.com/leoh0/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func captureJSONOutput() (*bytes.Buffer, func()) {
+	buf := &bytes.Buffer{}
+	original := output
+	output, _ = newCommandOutput(outputJSON, buf)
+	return buf, func() { output = original }
+}
+
+func TestNewCommandOutput(t *testing.T) {
+	out, err := newCommandOutput("", nil)
+	assert.NoError(t, err)
+	assert.False(t, out.isJSON())
+
+	out, err = newCommandOutput("json", nil)
+	assert.NoError(t, err)
+	assert.True(t, out.isJSON())
+
+	_, err = newCommandOutput("yaml", nil)
+	assert.EqualError(t, err, `Error: Unknown output format "yaml", expected text or json`)
+}
+
+func TestTextOutputPrintsText(t *testing.T) {
+	printed := false
+
+	newTextOutput(nil).printResult(StatusResult{}, func() { printed = true })
+
+	assert.True(t, printed)
+}
+
+func TestNewErrorResult(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected *ErrorResult
+	}{
+		{
+			errors.New("BUG"),
+			&ErrorResult{Type: "Error", Message: "BUG"},
+		},
+		{
+			mcnerror.ErrInvalidHostname,
+			&ErrorResult{Type: "ErrInvalidHostname", Message: mcnerror.ErrInvalidHostname.Error()},
+		},
+		{
+			mcnerror.ErrHostDoesNotExist{Name: "foo"},
+			&ErrorResult{
+				Type:    "ErrHostDoesNotExist",
+				Message: mcnerror.ErrHostDoesNotExist{Name: "foo"}.Error(),
+				Details: mcnerror.ErrHostDoesNotExist{Name: "foo"},
+			},
+		},
+		{
+			fmt.Errorf("Error creating machine: %w", mcnerror.ErrInvalidHostname),
+			&ErrorResult{Type: "ErrInvalidHostname", Message: "Error creating machine: " + mcnerror.ErrInvalidHostname.Error()},
+		},
+		{
+			fmt.Errorf("Error saving host to store: %w", mcnerror.ErrHostDoesNotExist{Name: "foo"}),
+			&ErrorResult{
+				Type:    "ErrHostDoesNotExist",
+				Message: "Error saving host to store: " + mcnerror.ErrHostDoesNotExist{Name: "foo"}.Error(),
+				Details: mcnerror.ErrHostDoesNotExist{Name: "foo"},
+			},
+		},
+		{
+			crashreport.CrashError{Cause: mcnerror.ErrDuringPreCreate{Cause: errors.New("no VT-x")}},
+			&ErrorResult{
+				Type:    "ErrDuringPreCreate",
+				Message: `Error with pre-create check: "no VT-x"`,
+				Cause:   &ErrorResult{Type: "Error", Message: "no VT-x"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, newErrorResult(tc.err))
+	}
+}
+
+func TestStatusJSONOutput(t *testing.T) {
+	buf, restore := captureJSONOutput()
+	defer restore()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "default",
+				Driver: &fakedriver.Driver{MockState: state.Running},
+			},
+		},
+	}
+
+	err := cmdStatus(&commandstest.FakeCommandLine{}, api)
+	assert.NoError(t, err)
+
+	assert.NoError(t, output.flush("status", err))
+	assert.Equal(t, `{
+    "command": "status",
+    "result": {
+        "name": "default",
+        "state": "Running"
+    }
+}
+`, buf.String())
+}
+
+func TestErrorJSONOutput(t *testing.T) {
+	buf, restore := captureJSONOutput()
+	defer restore()
+
+	err := cmdURL(&commandstest.FakeCommandLine{CliArgs: []string{"foo"}}, &libmachinetest.FakeAPI{})
+
+	assert.NoError(t, output.flush("url", err))
+	assert.Contains(t, buf.String(), `"result": null,
+    "error": {
+        "type": "ErrHostDoesNotExist",`)
+	assert.Contains(t, buf.String(), `"details": {
+            "Name": "foo"
+        }`)
+}
+
+func TestBulkActionJSONOutput(t *testing.T) {
+	buf, restore := captureJSONOutput()
+	defer restore()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "running",
+				Driver: &fakedriver.Driver{MockState: state.Running},
+			},
+			{
+				Name:   "stopped",
+				Driver: &fakedriver.Driver{MockState: state.Stopped},
+			},
+		},
+	}
+
+	err := cmdStop(&commandstest.FakeCommandLine{CliArgs: []string{"running", "stopped"}}, api)
+	assert.Error(t, err)
+
+	assert.NoError(t, output.flush("stop", nil))
+	assert.Equal(t, `{
+    "command": "stop",
+    "result": [
+        {
+            "name": "running",
+            "action": "stop",
+            "error": null
+        },
+        {
+            "name": "stopped",
+            "action": "stop",
+            "error": {
+                "type": "ErrHostAlreadyInState",
+                "message": "Machine \"stopped\" is already stopped.",
+                "details": {
+                    "Name": "stopped",
+                    "State": 4
+                }
+            }
+        }
+    ]
+}
+`, buf.String())
+}
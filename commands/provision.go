@@ -1,7 +1,173 @@
 package commands
 
-import "github.com/leoh0/machine/libmachine"
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/persist"
+	"github.com/leoh0/machine/libmachine/provision"
+)
+
+var provisionPlanWriter io.Writer = os.Stdout
+
+// ProvisionPlanResult is the result of provision --dry-run for one machine.
+type ProvisionPlanResult struct {
+	Machine      string              `json:"machine"`
+	Provisioner  string              `json:"provisioner"`
+	Packages     []string            `json:"packages"`
+	Certificates []string            `json:"certificates"`
+	Services     []string            `json:"services"`
+	Scripts      []string            `json:"scripts"`
+	Files        []ProvisionPlanFile `json:"files"`
+	Commands     []string            `json:"commands"`
+}
+
+// ProvisionPlanFile is a file provisioning would write on a machine. The
+// content of the certificates, generated at provisioning, is not known.
+type ProvisionPlanFile struct {
+	Path    string `json:"path"`
+	Mode    string `json:"mode"`
+	Content string `json:"content,omitempty"`
+}
 
 func cmdProvision(c CommandLine, api libmachine.API) error {
+	if c.Bool("dry-run") {
+		return cmdProvisionDryRun(c, api)
+	}
+
 	return runAction("provision", c, api)
 }
+
+// cmdProvisionDryRun prints what provisioning the machines would do,
+// without changing them.
+func cmdProvisionDryRun(c CommandLine, api libmachine.API) error {
+	hosts, err := provisionHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, h := range hosts {
+		result, err := dryRunProvision(h)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error planning the provisioning of %q: %s", h.Name, err))
+			continue
+		}
+
+		output.appendResult(result, func() {
+			printProvisionPlan(provisionPlanWriter, result)
+		})
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+func provisionHosts(c CommandLine, api libmachine.API) ([]*host.Host, error) {
+	if len(c.StringSlice("selector")) > 0 {
+		hosts, failed, err := selectHosts(c, api)
+		if err != nil {
+			return nil, err
+		}
+		if len(failed) > 0 {
+			return nil, consolidateErrs(actionErrs(failed))
+		}
+		return hosts, nil
+	}
+
+	names := c.Args()
+	if len(names) == 0 {
+		target, err := targetHost(c, api)
+		if err != nil {
+			return nil, err
+		}
+		names = []string{target}
+	}
+
+	hosts, hostsInError := persist.LoadHosts(api, names)
+	if len(hostsInError) > 0 {
+		errs := []error{}
+		for _, err := range hostsInError {
+			errs = append(errs, err)
+		}
+		return nil, consolidateErrs(errs)
+	}
+
+	if len(hosts) == 0 {
+		return nil, ErrHostLoad
+	}
+
+	return hosts, nil
+}
+
+func dryRunProvision(h *host.Host) (ProvisionPlanResult, error) {
+	result := ProvisionPlanResult{Machine: h.Name}
+
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return result, err
+	}
+
+	plan, err := provision.DryRunProvision(provisioner, *h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions, h.ProvisionScripts())
+	if err != nil {
+		return result, err
+	}
+
+	result.Provisioner = plan.Provisioner
+	result.Packages = plan.Packages
+	result.Certificates = plan.Certificates
+	result.Services = plan.Services
+	result.Scripts = plan.Scripts
+	result.Commands = plan.Commands
+	for _, u := range plan.Uploads {
+		result.Files = append(result.Files, ProvisionPlanFile{
+			Path:    u.Dst,
+			Mode:    fmt.Sprintf("%04o", u.Mode),
+			Content: string(u.Content),
+		})
+	}
+
+	return result, nil
+}
+
+func printProvisionPlan(w io.Writer, result ProvisionPlanResult) {
+	fmt.Fprintf(w, "Provisioning %s with %s would:\n", result.Machine, result.Provisioner)
+
+	printPlanList(w, "Install packages", result.Packages)
+	printPlanList(w, "Generate certificates", result.Certificates)
+	printPlanList(w, "Restart services", result.Services)
+	printPlanList(w, "Run scripts", result.Scripts)
+
+	fmt.Fprintln(w, "  Write files:")
+	for _, f := range result.Files {
+		fmt.Fprintf(w, "    %s (%s)\n", f.Path, f.Mode)
+		for _, line := range strings.Split(strings.TrimRight(f.Content, "\n"), "\n") {
+			if line != "" {
+				fmt.Fprintf(w, "      | %s\n", line)
+			}
+		}
+	}
+
+	printPlanList(w, "Run commands", result.Commands)
+}
+
+func printPlanList(w io.Writer, title string, items []string) {
+	fmt.Fprintf(w, "  %s:\n", title)
+	if len(items) == 0 {
+		fmt.Fprintln(w, "    none")
+	}
+	for _, item := range items {
+		lines := strings.Split(strings.TrimSpace(item), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimSpace(line)
+		}
+		fmt.Fprintf(w, "    %s\n", strings.Join(lines, "\n      "))
+	}
+}
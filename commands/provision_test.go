@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/leoh0/machine/commands/commandstest"
@@ -11,6 +12,8 @@ import (
 	"github.com/leoh0/machine/libmachine/host"
 	"github.com/leoh0/machine/libmachine/libmachinetest"
 	"github.com/leoh0/machine/libmachine/provision"
+	"github.com/leoh0/machine/libmachine/provision/provisiontest"
+	"github.com/leoh0/machine/libmachine/state"
 	"github.com/leoh0/machine/libmachine/swarm"
 	"github.com/stretchr/testify/assert"
 )
@@ -65,3 +68,52 @@ func TestCmdProvision(t *testing.T) {
 		assert.Equal(t, tc.expectedErr, cmdProvision(tc.commandLine, tc.api))
 	}
 }
+
+func TestCmdProvisionDryRun(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+
+	d := &fakedriver.Driver{MockName: "foo", MockState: state.Running, MockIP: "1.2.3.4"}
+	p := provision.NewUbuntuSystemdProvisioner(d).(*provision.UbuntuSystemdProvisioner)
+	sshCmder := provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+	sshCmder.Responses["docker --version"] = "Docker version 20.10.7, build f0df350\n"
+	p.SSHCommander = sshCmder
+	provision.SetDetector(&provision.FakeDetector{Provisioner: p})
+
+	buf := &bytes.Buffer{}
+	original := provisionPlanWriter
+	provisionPlanWriter = buf
+	defer func() { provisionPlanWriter = original }()
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"foo"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"dry-run": true,
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "foo",
+				Driver: d,
+				HostOptions: &host.Options{
+					EngineOptions: &engine.Options{},
+					AuthOptions:   &auth.Options{ServerCertPath: "/machines/foo/server.pem"},
+					SwarmOptions:  &swarm.Options{},
+				},
+			},
+		},
+	}
+
+	err := cmdProvision(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Empty(t, sshCmder.Uploads)
+	assert.Contains(t, buf.String(), "Provisioning foo with ubuntu(systemd) would:")
+	assert.Contains(t, buf.String(), "  Install packages:\n    curl\n")
+	assert.Contains(t, buf.String(), "  Restart services:\n    docker\n")
+	assert.Contains(t, buf.String(), "    /machines/foo/server.pem for [1.2.3.4 localhost]\n")
+	assert.Contains(t, buf.String(), "    /etc/systemd/system/docker.service.d/10-machine.conf (0644)\n")
+	assert.Contains(t, buf.String(), "    sudo systemctl -f restart docker\n")
+}
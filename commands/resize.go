@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/persist"
+)
+
+var ErrNoResizeOption = errors.New("Error: Expected at least one of --cpus, --memory or --disk")
+
+func cmdResize(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	opts := drivers.ResizeOptions{
+		CPU:      c.Int("cpus"),
+		Memory:   c.Int("memory"),
+		DiskSize: c.Int("disk"),
+	}
+	if opts.CPU < 0 || opts.Memory < 0 || opts.DiskSize < 0 {
+		return errors.New("Error: --cpus, --memory and --disk must be positive")
+	}
+	if opts == (drivers.ResizeOptions{}) {
+		return ErrNoResizeOption
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := persist.LockMachines(api, []string{target})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	resizer, err := drivers.ResizerFor(h.Driver)
+	if err != nil {
+		return fmt.Errorf("Error: machine %q uses the %s driver: %s", target, h.DriverName, err)
+	}
+
+	log.Infof("Resizing %q...", target)
+
+	if err := resizer.Resize(opts); err != nil {
+		// Errors of plugin drivers only keep their message over RPC.
+		if err.Error() == drivers.ErrHostIsNotStopped.Error() {
+			return fmt.Errorf("Error resizing %q: the %s driver can only resize a stopped machine, run 'docker-machine stop %s' first", target, h.DriverName, target)
+		}
+		return fmt.Errorf("Error resizing %q: %s", target, err)
+	}
+
+	if h.HostOptions != nil {
+		if opts.Memory != 0 {
+			h.HostOptions.Memory = opts.Memory
+		}
+		if opts.DiskSize != 0 {
+			h.HostOptions.Disk = opts.DiskSize
+		}
+	}
+
+	return api.Save(h)
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/leoh0/machine/commands/commandstest"
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeResizeDriver struct {
+	fakedriver.Driver
+	opts drivers.ResizeOptions
+	err  error
+}
+
+func (d *fakeResizeDriver) Resize(opts drivers.ResizeOptions) error {
+	d.opts = opts
+	return d.err
+}
+
+func TestCmdResize(t *testing.T) {
+	driver := &fakeResizeDriver{}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "default", DriverName: "virtualbox", Driver: driver, HostOptions: &host.Options{Memory: 1024, Disk: 20000}},
+		},
+	}
+
+	err := cmdResize(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"cpus":   2,
+				"memory": 4096,
+			},
+		},
+	}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, drivers.ResizeOptions{CPU: 2, Memory: 4096}, driver.opts)
+	assert.Equal(t, 4096, api.Hosts[0].HostOptions.Memory)
+	assert.Equal(t, 20000, api.Hosts[0].HostOptions.Disk)
+}
+
+func TestCmdResizeNoOption(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "default", DriverName: "virtualbox", Driver: &fakeResizeDriver{}, HostOptions: &host.Options{Memory: 1024, Disk: 20000}},
+		},
+	}
+
+	err := cmdResize(&commandstest.FakeCommandLine{}, api)
+
+	assert.Equal(t, ErrNoResizeOption, err)
+}
+
+func TestCmdResizeMustBeStopped(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "default", DriverName: "virtualbox", Driver: &fakeResizeDriver{err: drivers.ErrHostIsNotStopped}, HostOptions: &host.Options{Memory: 1024, Disk: 20000}},
+		},
+	}
+
+	err := cmdResize(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"disk": 40000,
+			},
+		},
+	}, api)
+
+	assert.EqualError(t, err, `Error resizing "default": the virtualbox driver can only resize a stopped machine, run 'docker-machine stop default' first`)
+	assert.Equal(t, 20000, api.Hosts[0].HostOptions.Disk)
+}
+
+func TestCmdResizeNotSupported(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "default", DriverName: "virtualbox", Driver: &fakedriver.Driver{}, HostOptions: &host.Options{Memory: 1024, Disk: 20000}},
+		},
+	}
+
+	err := cmdResize(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"cpus": 2,
+			},
+		},
+	}, api)
+
+	assert.EqualError(t, err, `Error: machine "default" uses the virtualbox driver: Driver does not support resizing`)
+}
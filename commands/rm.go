@@ -12,36 +12,59 @@ import (
 )
 
 func cmdRm(c CommandLine, api libmachine.API) error {
-	if len(c.Args()) == 0 {
+	hostNames := c.Args()
+	useSelector := len(c.StringSlice("selector")) > 0
+	failed := []machineActionResult{}
+
+	if useSelector {
+		hosts, loadFailed, err := selectHosts(c, api)
+		if err != nil {
+			return err
+		}
+		failed = loadFailed
+
+		hostNames = []string{}
+		for _, h := range hosts {
+			hostNames = append(hostNames, h.Name)
+		}
+
+		// Only machines which could not be loaded were found.
+		if len(hostNames) == 0 {
+			return summarizeActionResults("rm", failed)
+		}
+	}
+
+	if len(hostNames) == 0 {
 		c.ShowHelp()
 		return ErrNoMachineSpecified
 	}
 
-	log.Info(fmt.Sprintf("About to remove %s", strings.Join(c.Args(), ", ")))
+	log.Info(fmt.Sprintf("About to remove %s", strings.Join(hostNames, ", ")))
 	log.Warn("WARNING: This action will delete both local reference and remote instance.")
 
 	force := c.Bool("force")
 	confirm := c.Bool("y")
-	var errorOccurred []string
 
 	if !userConfirm(confirm, force) {
 		return nil
 	}
 
-	for _, hostName := range c.Args() {
-		err := removeRemoteMachine(hostName, api)
-		if err != nil {
-			errorOccurred = collectError(fmt.Sprintf("Error removing host %q: %s", hostName, err), force, errorOccurred)
+	results := runForeachMachine(c.CommandContext(), hostNames, c.Int("parallel"), func(i int) error {
+		return removeMachine(hostNames[i], api, force)
+	})
+
+	var errorOccurred []string
+	for _, result := range results {
+		if result.Err != nil {
+			errorOccurred = collectError(result.Err.Error(), force, errorOccurred)
 		}
+	}
 
-		if err == nil || force {
-			removeErr := removeLocalMachine(hostName, api)
-			if removeErr != nil {
-				errorOccurred = collectError(fmt.Sprintf("Can't remove \"%s\"", hostName), force, errorOccurred)
-			} else {
-				log.Infof("Successfully removed %s", hostName)
-			}
+	if useSelector {
+		if err := summarizeActionResults("rm", append(results, failed...)); err != nil && !force {
+			return err
 		}
+		return nil
 	}
 
 	if len(errorOccurred) > 0 && !force {
@@ -64,6 +87,26 @@ func userConfirm(confirm bool, force bool) bool {
 	return sure
 }
 
+// removeMachine removes the remote instance and the local reference of a
+// machine. When force is set the local reference is removed even if the
+// remote instance cannot be.
+func removeMachine(hostName string, api libmachine.API, force bool) error {
+	err := removeRemoteMachine(hostName, api)
+	if err != nil {
+		err = fmt.Errorf("Error removing host %q: %s", hostName, err)
+		if !force {
+			return err
+		}
+	}
+
+	if removeErr := removeLocalMachine(hostName, api); removeErr != nil {
+		return fmt.Errorf("Can't remove \"%s\"", hostName)
+	}
+
+	log.Infof("Successfully removed %s", hostName)
+	return err
+}
+
 func removeRemoteMachine(hostName string, api libmachine.API) error {
 	currentHost, loaderr := api.Load(hostName)
 	if loaderr != nil {
@@ -0,0 +1,452 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/ssh"
+)
+
+// scpProgressWriter receives the progress of the native copies.
+var scpProgressWriter io.Writer = os.Stderr
+
+// useNativeScp tells if the files are copied in Go rather than with the scp
+// or rsync binary: with --native-ssh, or when the binary is missing.
+func useNativeScp(delta bool) bool {
+	if ssh.DefaultClient() == ssh.Native {
+		return true
+	}
+
+	binary := "scp"
+	if delta {
+		binary = "rsync"
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		log.Debugf("%s binary not found, copying with the native Go implementation", binary)
+		return true
+	}
+
+	return false
+}
+
+// nativeCopy copies files over the native SSH client. A nil client stands
+// for the local host.
+type nativeCopy struct {
+	src       *ssh.NativeClient
+	srcPath   string
+	dest      *ssh.NativeClient
+	destPath  string
+	destIsDir bool
+	recursive bool
+	// destSums are the checksums of the files at the destination, by slash
+	// separated path, with --delta.
+	destSums map[string]string
+}
+
+func nativeScp(src, dest string, recursive, delta, quiet bool, hostInfoLoader HostInfoLoader) error {
+	srcHost, srcUser, srcPath, _, err := getInfoForScpArg(src, hostInfoLoader)
+	if err != nil {
+		return err
+	}
+
+	destHost, destUser, destPath, _, err := getInfoForScpArg(dest, hostInfoLoader)
+	if err != nil {
+		return err
+	}
+
+	c := &nativeCopy{
+		srcPath:   srcPath,
+		destPath:  destPath,
+		recursive: recursive,
+	}
+
+	if srcHost != nil {
+		if c.src, err = nativeClientFor(srcHost, srcUser); err != nil {
+			return err
+		}
+	}
+	if destHost != nil {
+		if c.dest, err = nativeClientFor(destHost, destUser); err != nil {
+			return err
+		}
+	}
+
+	c.destIsDir = isDir(c.dest, destPath)
+
+	if delta {
+		if c.destSums, err = checksums(c.dest, c.target(c.srcBase())); err != nil {
+			return fmt.Errorf("Error computing the checksums of %s: %s", dest, err)
+		}
+	}
+
+	if c.dest == nil {
+		return c.send(c.progress(&localCopyWriter{dest: destPath, destIsDir: c.destIsDir}, quiet))
+	}
+
+	w, err := c.dest.CopyTo(destPath)
+	if err != nil {
+		return err
+	}
+
+	sendErr := c.send(c.progress(w, quiet))
+	closeErr := w.Close()
+	if sendErr != nil {
+		return sendErr
+	}
+
+	return closeErr
+}
+
+func nativeClientFor(h HostInfo, user string) (*ssh.NativeClient, error) {
+	hostname, err := h.GetSSHHostname()
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := h.GetSSHPort()
+	if err != nil {
+		return nil, err
+	}
+
+	if user == "" {
+		user = h.GetSSHUsername()
+	}
+
+	auth := &ssh.Auth{}
+	if keyPath := h.GetSSHKeyPath(); keyPath != "" {
+		auth.Keys = []string{keyPath}
+		auth.KnownHosts = drivers.GetSSHKnownHostsFromDriver(h)
+	}
+
+	client, err := ssh.NewNativeClient(user, hostname, port, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.(*ssh.NativeClient), nil
+}
+
+func (c *nativeCopy) progress(w ssh.CopyWriter, quiet bool) ssh.CopyWriter {
+	if quiet {
+		return w
+	}
+
+	return &progressCopyWriter{CopyWriter: w, out: scpProgressWriter}
+}
+
+// srcBase returns the name of the copied file or directory, which the
+// paths given to the CopyWriter start with.
+func (c *nativeCopy) srcBase() string {
+	if c.src != nil {
+		return path.Base(path.Clean(c.srcPath))
+	}
+
+	if abs, err := filepath.Abs(c.srcPath); err == nil {
+		return filepath.Base(abs)
+	}
+	return filepath.Base(c.srcPath)
+}
+
+// target returns the slash separated destination path of rel.
+func (c *nativeCopy) target(rel string) string {
+	dest := c.destPath
+	if c.dest == nil {
+		dest = filepath.ToSlash(dest)
+	}
+
+	return ssh.CopyTarget(dest, c.destIsDir, rel)
+}
+
+// unchanged tells if the file rel is already at the destination with the
+// checksum sum.
+func (c *nativeCopy) unchanged(rel, sum string) bool {
+	if c.destSums == nil {
+		return false
+	}
+
+	destSum, ok := c.destSums[c.target(rel)]
+	return ok && destSum == sum
+}
+
+func (c *nativeCopy) send(w ssh.CopyWriter) error {
+	if c.src == nil {
+		return c.sendLocal(w)
+	}
+
+	return c.sendRemote(w)
+}
+
+func (c *nativeCopy) sendLocal(w ssh.CopyWriter) error {
+	root := filepath.Clean(c.srcPath)
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && !c.recursive {
+		return fmt.Errorf("%s is a directory, use --recursive to copy it", c.srcPath)
+	}
+
+	base := c.srcBase()
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = path.Join(base, filepath.ToSlash(rel))
+
+		if info.IsDir() {
+			return w.Mkdir(rel, info.Mode())
+		}
+		if !info.Mode().IsRegular() {
+			log.Debugf("Skipping %s, which is not a regular file", p)
+			return nil
+		}
+
+		if c.destSums != nil {
+			sum, err := fileChecksum(p)
+			if err != nil {
+				return err
+			}
+			if c.unchanged(rel, sum) {
+				log.Debugf("Skipping %s, which is unchanged", p)
+				return nil
+			}
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return w.WriteFile(rel, info.Mode(), info.Size(), f)
+	})
+}
+
+func (c *nativeCopy) sendRemote(w ssh.CopyWriter) error {
+	if c.destSums == nil {
+		return c.src.CopyFrom(c.srcPath, c.recursive, w)
+	}
+
+	root := path.Clean(c.srcPath)
+	if isDir(c.src, root) && !c.recursive {
+		return fmt.Errorf("%s is a directory, use --recursive to copy it", c.srcPath)
+	}
+
+	srcSums, err := checksums(c.src, root)
+	if err != nil {
+		return fmt.Errorf("Error computing the checksums of %s: %s", c.srcPath, err)
+	}
+
+	paths := []string{}
+	for p := range srcSums {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	base := c.srcBase()
+	for _, p := range paths {
+		rel := path.Join(base, strings.TrimPrefix(p, root))
+		if c.unchanged(rel, srcSums[p]) {
+			log.Debugf("Skipping %s, which is unchanged", p)
+			continue
+		}
+
+		if err := c.src.CopyFrom(p, false, &prefixCopyWriter{CopyWriter: w, prefix: path.Dir(rel)}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isDir(client *ssh.NativeClient, p string) bool {
+	if client == nil {
+		info, err := os.Stat(p)
+		return err == nil && info.IsDir()
+	}
+
+	info, err := client.Stat(p)
+	return err == nil && info.IsDir()
+}
+
+// checksums returns the SHA-256 checksums of the files under root, by slash
+// separated path, on the remote host or locally when client is nil.
+func checksums(client *ssh.NativeClient, root string) (map[string]string, error) {
+	sums := map[string]string{}
+
+	if client != nil {
+		output, err := client.Output(fmt.Sprintf("if [ -e %[1]s ]; then find %[1]s -type f -exec sha256sum {} +; fi", ssh.ShellQuote(root)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(output))
+		}
+
+		for _, line := range strings.Split(output, "\n") {
+			parts := strings.SplitN(line, "  ", 2)
+			if len(parts) == 2 && len(parts[0]) == sha256.Size*2 {
+				sums[path.Clean(parts[1])] = parts[0]
+			}
+		}
+
+		return sums, nil
+	}
+
+	err := filepath.Walk(filepath.FromSlash(root), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		sum, err := fileChecksum(p)
+		if err != nil {
+			return err
+		}
+		sums[filepath.ToSlash(p)] = sum
+		return nil
+	})
+
+	return sums, err
+}
+
+func fileChecksum(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// localCopyWriter writes the copied files to the local filesystem.
+type localCopyWriter struct {
+	dest      string
+	destIsDir bool
+}
+
+func (w *localCopyWriter) path(rel string) string {
+	return filepath.FromSlash(ssh.CopyTarget(filepath.ToSlash(w.dest), w.destIsDir, rel))
+}
+
+func (w *localCopyWriter) Mkdir(rel string, mode os.FileMode) error {
+	return os.MkdirAll(w.path(rel), mode.Perm())
+}
+
+func (w *localCopyWriter) WriteFile(rel string, mode os.FileMode, size int64, r io.Reader) error {
+	p := w.path(rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// prefixCopyWriter writes the files of a single file copy under prefix.
+type prefixCopyWriter struct {
+	ssh.CopyWriter
+	prefix string
+}
+
+func (w *prefixCopyWriter) Mkdir(rel string, mode os.FileMode) error {
+	return w.CopyWriter.Mkdir(path.Join(w.prefix, rel), mode)
+}
+
+func (w *prefixCopyWriter) WriteFile(rel string, mode os.FileMode, size int64, r io.Reader) error {
+	return w.CopyWriter.WriteFile(path.Join(w.prefix, rel), mode, size, r)
+}
+
+// progressCopyWriter prints the progress of each file copy.
+type progressCopyWriter struct {
+	ssh.CopyWriter
+	out io.Writer
+}
+
+func (w *progressCopyWriter) WriteFile(rel string, mode os.FileMode, size int64, r io.Reader) error {
+	progress := &copyProgress{Reader: r, out: w.out, name: rel, size: size}
+
+	err := w.CopyWriter.WriteFile(rel, mode, size, progress)
+	progress.print()
+	fmt.Fprintln(w.out)
+
+	return err
+}
+
+type copyProgress struct {
+	io.Reader
+	out     io.Writer
+	name    string
+	size    int64
+	done    int64
+	printed time.Time
+}
+
+func (p *copyProgress) Read(b []byte) (int, error) {
+	n, err := p.Reader.Read(b)
+	p.done += int64(n)
+
+	if time.Since(p.printed) > 100*time.Millisecond {
+		p.print()
+	}
+
+	return n, err
+}
+
+func (p *copyProgress) print() {
+	percent := int64(100)
+	if p.size > 0 {
+		percent = p.done * 100 / p.size
+	}
+
+	fmt.Fprintf(p.out, "\r%-40s %3d%% %10s", p.name, percent, humanSize(p.done))
+	p.printed = time.Now()
+}
+
+func humanSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d%s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
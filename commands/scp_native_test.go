@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNativeScpLocalDirectory(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	src := filepath.Join(tmpDir, "src")
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("b"), 0600))
+
+	err = nativeScp(src, filepath.Join(tmpDir, "dest"), false, false, true, nil)
+	assert.Error(t, err)
+
+	err = nativeScp(src, filepath.Join(tmpDir, "dest"), true, false, true, nil)
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(tmpDir, "dest", "sub", "b.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(content))
+
+	// dest exists now, the directory is copied into it.
+	err = nativeScp(src, filepath.Join(tmpDir, "dest"), true, false, true, nil)
+	assert.NoError(t, err)
+
+	content, err = ioutil.ReadFile(filepath.Join(tmpDir, "dest", "src", "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(content))
+}
+
+func TestNativeScpDeltaSendsChangedFilesOnly(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	src := filepath.Join(tmpDir, "src")
+	dest := filepath.Join(tmpDir, "dest")
+	assert.NoError(t, os.MkdirAll(src, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "changed.txt"), []byte("new"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "same.txt"), []byte("same"), 0644))
+	assert.NoError(t, os.MkdirAll(dest, 0755))
+	assert.NoError(t, nativeScp(src, dest, true, false, true, nil))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dest, "src", "changed.txt"), []byte("old"), 0644))
+
+	progress := &bytes.Buffer{}
+	scpProgressWriter = progress
+	defer func() { scpProgressWriter = os.Stderr }()
+
+	err = nativeScp(src, dest, true, true, false, nil)
+
+	assert.NoError(t, err)
+	assert.Contains(t, progress.String(), "src/changed.txt")
+	assert.NotContains(t, progress.String(), "src/same.txt")
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "src", "changed.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+}
+
+func TestHumanSize(t *testing.T) {
+	assert.Equal(t, "512B", humanSize(512))
+	assert.Equal(t, "1.5KB", humanSize(1536))
+	assert.Equal(t, "2.0MB", humanSize(2*1024*1024))
+}
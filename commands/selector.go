@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/persist"
+)
+
+const (
+	defaultParallelActions = 10
+)
+
+var (
+	ErrSelectorWithArgs  = errors.New("Error: Machine names and --selector cannot be used together")
+	ErrNoSelectedMachine = errors.New("Error: No machine matches the given selector")
+
+	summaryWriter io.Writer = os.Stdout
+)
+
+// machineActionResult is the outcome of an action run on a single machine.
+type machineActionResult struct {
+	Name string
+	Err  error
+}
+
+// ActionResult is the result of a bulk action, such as start or stop, for
+// one machine.
+type ActionResult struct {
+	Name   string       `json:"name"`
+	Action string       `json:"action"`
+	Error  *ErrorResult `json:"error"`
+}
+
+// appendActionResults adds the outcome of an action to the --output json
+// document. Actions print nothing on success in text mode.
+func appendActionResults(actionName string, results []machineActionResult) {
+	for _, result := range results {
+		actionResult := ActionResult{
+			Name:   result.Name,
+			Action: actionName,
+		}
+		if result.Err != nil {
+			actionResult.Error = newErrorResult(result.Err)
+		}
+		output.appendResult(actionResult, func() {})
+	}
+}
+
+func actionErrs(results []machineActionResult) []error {
+	errs := []error{}
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	return errs
+}
+
+// loadFailures turns the errors of the machines which could not be loaded
+// into failed results, in name order.
+func loadFailures(hostsInError map[string]error) []machineActionResult {
+	names := []string{}
+	for name := range hostsInError {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failed := []machineActionResult{}
+	for _, name := range names {
+		failed = append(failed, machineActionResult{
+			Name: name,
+			Err:  hostsInError[name],
+		})
+	}
+	return failed
+}
+
+// selectHosts loads every machine matching the --selector filters, which
+// use the same syntax as the 'ls --filter' flag. The machines which cannot
+// be loaded cannot be matched, they are returned as failed results.
+func selectHosts(c CommandLine, api libmachine.API) ([]*host.Host, []machineActionResult, error) {
+	if len(c.Args()) > 0 {
+		return nil, nil, ErrSelectorWithArgs
+	}
+
+	filters, err := parseFilters(c.StringSlice("selector"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hosts, hostsInError, err := persist.LoadAllHosts(api)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hosts = filterHosts(hosts, filters)
+	failed := loadFailures(hostsInError)
+	if len(hosts) == 0 && len(failed) == 0 {
+		return nil, nil, ErrNoSelectedMachine
+	}
+
+	return hosts, failed, nil
+}
+
+func runActionForSelector(actionName string, c CommandLine, api libmachine.API) error {
+	selected, failed, err := selectHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, h := range selected {
+		names = append(names, h.Name)
+	}
+
+	// The selected machines are loaded again once locked, in case they
+	// changed in the meantime.
+	unlock, err := persist.LockMachines(api, names)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hosts, hostsInError := persist.LoadHosts(api, names)
+	failed = append(failed, loadFailures(hostsInError)...)
+
+	results := runActionForeachMachine(c.CommandContext(), actionName, hosts, c.Int("parallel"))
+
+	// The machines whose action failed are left as they were stored, like
+	// runAction does.
+	for i, h := range hosts {
+		if results[i].Err != nil {
+			continue
+		}
+		if err := api.Save(h); err != nil {
+			results[i].Err = fmt.Errorf("Error saving host to store: %w", err)
+		}
+	}
+
+	return summarizeActionResults(actionName, append(results, failed...))
+}
+
+// summarizeActionResults prints a table with the outcome of an action for
+// each machine and returns an error if it failed on any of them.
+func summarizeActionResults(actionName string, results []machineActionResult) error {
+	failed := len(actionErrs(results))
+
+	if output.isJSON() {
+		appendActionResults(actionName, results)
+	} else {
+		w := tabwriter.NewWriter(summaryWriter, 5, 1, 3, ' ', 0)
+		fmt.Fprintln(w, "NAME\tRESULT\tERROR")
+
+		for _, result := range results {
+			if result.Err != nil {
+				fmt.Fprintf(w, "%s\tFailed\t%s\n", result.Name, strings.Replace(result.Err.Error(), "\n", " ", -1))
+			} else {
+				fmt.Fprintf(w, "%s\tOK\t\n", result.Name)
+			}
+		}
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Error: %s failed on %d out of %d machines", actionName, failed, len(results))
+	}
+
+	return nil
+}
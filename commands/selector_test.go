@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/leoh0/machine/commands/commandstest"
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/libmachinetest"
+	"github.com/leoh0/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func captureSummary() (*bytes.Buffer, func()) {
+	buf := &bytes.Buffer{}
+	original := summaryWriter
+	summaryWriter = buf
+	return buf, func() { summaryWriter = original }
+}
+
+func TestRunActionWithSelector(t *testing.T) {
+	buf, restore := captureSummary()
+	defer restore()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "vb1", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Running}},
+			{Name: "vb2", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Stopped}},
+			{Name: "aws", DriverName: "amazonec2", Driver: &fakedriver.Driver{MockState: state.Running}},
+		},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"selector": []string{"driver=virtualbox", "state=Running"},
+				"parallel": 1,
+			},
+		},
+	}
+
+	err := cmdStop(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, libmachinetest.State(api, "vb1"))
+	assert.Equal(t, state.Running, libmachinetest.State(api, "aws"))
+	assert.Contains(t, buf.String(), "vb1")
+	assert.NotContains(t, buf.String(), "aws")
+}
+
+func TestRunActionWithSelectorReportsFailures(t *testing.T) {
+	buf, restore := captureSummary()
+	defer restore()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "vb1", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Running}},
+			{Name: "vb2", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Stopped}},
+			{Name: "aws", DriverName: "amazonec2", Driver: &fakedriver.Driver{MockState: state.Running}},
+		},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"selector": []string{"driver=virtualbox"},
+			},
+		},
+	}
+
+	err := cmdStop(commandLine, api)
+
+	assert.EqualError(t, err, "Error: stop failed on 1 out of 2 machines")
+	assert.Equal(t, state.Stopped, libmachinetest.State(api, "vb1"))
+	assert.Regexp(t, `vb1\s+OK`, buf.String())
+	assert.Regexp(t, `vb2\s+Failed\s+Machine "vb2" is already stopped.`, buf.String())
+}
+
+func TestRunActionWithSelectorErrors(t *testing.T) {
+	testCases := []struct {
+		commandLine CommandLine
+		expectedErr error
+	}{
+		{
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: []string{"vb1"},
+				LocalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"selector": []string{"driver=virtualbox"},
+					},
+				},
+			},
+			expectedErr: ErrSelectorWithArgs,
+		},
+		{
+			commandLine: &commandstest.FakeCommandLine{
+				LocalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"selector": []string{"driver=google"},
+					},
+				},
+			},
+			expectedErr: ErrNoSelectedMachine,
+		},
+		{
+			commandLine: &commandstest.FakeCommandLine{
+				LocalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"selector": []string{"color=blue"},
+					},
+				},
+			},
+			expectedErr: errors.New("Unsupported filter key 'color'"),
+		},
+	}
+
+	for _, tc := range testCases {
+		api := &libmachinetest.FakeAPI{
+			Hosts: []*host.Host{
+				{Name: "vb1", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Running}},
+				{Name: "vb2", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Stopped}},
+				{Name: "aws", DriverName: "amazonec2", Driver: &fakedriver.Driver{MockState: state.Running}},
+			},
+		}
+
+		assert.Equal(t, tc.expectedErr, runAction("stop", tc.commandLine, api))
+	}
+}
+
+func TestCmdRmWithSelector(t *testing.T) {
+	buf, restore := captureSummary()
+	defer restore()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "vb1", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Running}},
+			{Name: "vb2", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Stopped}},
+			{Name: "aws", DriverName: "amazonec2", Driver: &fakedriver.Driver{MockState: state.Running}},
+		},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"selector": []string{"driver=virtualbox"},
+				"parallel": 2,
+				"y":        true,
+			},
+		},
+	}
+
+	err := cmdRm(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Regexp(t, `vb1\s+OK`, buf.String())
+	assert.Regexp(t, `vb2\s+OK`, buf.String())
+	assert.False(t, libmachinetest.Exists(api, "vb1"))
+	assert.False(t, libmachinetest.Exists(api, "vb2"))
+	assert.True(t, libmachinetest.Exists(api, "aws"))
+}
+
+// brokenHostAPI is a FakeAPI listing machines whose configuration cannot be
+// loaded.
+type brokenHostAPI struct {
+	*libmachinetest.FakeAPI
+	broken []string
+}
+
+func (api *brokenHostAPI) List() ([]string, error) {
+	names, err := api.FakeAPI.List()
+	return append(names, api.broken...), err
+}
+
+func (api *brokenHostAPI) Load(name string) (*host.Host, error) {
+	for _, broken := range api.broken {
+		if name == broken {
+			return nil, errors.New("invalid config")
+		}
+	}
+	return api.FakeAPI.Load(name)
+}
+
+func TestRunActionWithSelectorReportsLoadErrors(t *testing.T) {
+	buf, restore := captureSummary()
+	defer restore()
+
+	api := &brokenHostAPI{
+		FakeAPI: &libmachinetest.FakeAPI{
+			Hosts: []*host.Host{
+				{Name: "vb1", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Running}},
+			},
+		},
+		broken: []string{"broken"},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"selector": []string{"driver=virtualbox"},
+			},
+		},
+	}
+
+	err := cmdStop(commandLine, api)
+
+	assert.EqualError(t, err, "Error: stop failed on 1 out of 2 machines")
+	assert.Equal(t, state.Stopped, libmachinetest.State(api, "vb1"))
+	assert.Regexp(t, `vb1\s+OK`, buf.String())
+	assert.Regexp(t, `broken\s+Failed\s+invalid config`, buf.String())
+}
+
+// savingAPI is a FakeAPI recording the machines saved.
+type savingAPI struct {
+	*libmachinetest.FakeAPI
+	saved []string
+}
+
+func (api *savingAPI) Save(h *host.Host) error {
+	api.saved = append(api.saved, h.Name)
+	return nil
+}
+
+func TestRunActionWithSelectorSavesSucceededMachinesOnly(t *testing.T) {
+	_, restore := captureSummary()
+	defer restore()
+
+	api := &savingAPI{
+		FakeAPI: &libmachinetest.FakeAPI{
+			Hosts: []*host.Host{
+				{Name: "vb1", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Running}},
+				{Name: "vb2", DriverName: "virtualbox", Driver: &fakedriver.Driver{MockState: state.Stopped}},
+			},
+		},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"selector": []string{"driver=virtualbox"},
+			},
+		},
+	}
+
+	err := cmdStop(commandLine, api)
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"vb1"}, api.saved)
+}
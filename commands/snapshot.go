@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/log"
+)
+
+var (
+	ErrExpectedMachineAndSnapshot = errors.New("Error: Expected a machine name and a snapshot name as arguments")
+
+	snapshotWriter io.Writer = os.Stdout
+)
+
+// SnapshotResult is the result of the snapshot ls command for one
+// snapshot.
+type SnapshotResult struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Current     bool   `json:"current"`
+}
+
+// loadSnapshotter returns the driver of a machine as a Snapshotter.
+func loadSnapshotter(api libmachine.API, name string) (drivers.Snapshotter, error) {
+	h, err := api.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotter, err := drivers.SnapshotterFor(h.Driver)
+	if err != nil {
+		return nil, fmt.Errorf("Error: machine %q uses the %s driver: %s", name, h.DriverName, err)
+	}
+
+	return snapshotter, nil
+}
+
+// snapshotArgs returns the machine and snapshot names given to the
+// create, restore and rm subcommands.
+func snapshotArgs(c CommandLine) (string, string, error) {
+	if len(c.Args()) != 2 {
+		return "", "", ErrExpectedMachineAndSnapshot
+	}
+
+	return c.Args()[0], c.Args()[1], nil
+}
+
+func cmdSnapshotCreate(c CommandLine, api libmachine.API) error {
+	machineName, snapshotName, err := snapshotArgs(c)
+	if err != nil {
+		return err
+	}
+
+	snapshotter, err := loadSnapshotter(api, machineName)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Taking snapshot %q of %q...", snapshotName, machineName)
+
+	if err := snapshotter.CreateSnapshot(snapshotName, c.String("description")); err != nil {
+		return fmt.Errorf("Error taking snapshot %q: %s", snapshotName, err)
+	}
+
+	return nil
+}
+
+func cmdSnapshotLs(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	snapshotter, err := loadSnapshotter(api, target)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := snapshotter.ListSnapshots()
+	if err != nil {
+		return fmt.Errorf("Error listing snapshots: %s", err)
+	}
+
+	if output.isJSON() {
+		results := []SnapshotResult{}
+		for _, snapshot := range snapshots {
+			results = append(results, SnapshotResult{
+				ID:          snapshot.ID,
+				Name:        snapshot.Name,
+				Description: snapshot.Description,
+				Current:     snapshot.Current,
+			})
+		}
+		output.printResult(results, nil)
+		return nil
+	}
+
+	if c.Bool("quiet") {
+		for _, snapshot := range snapshots {
+			fmt.Fprintln(snapshotWriter, snapshot.Name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(snapshotWriter, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCURRENT\tDESCRIPTION")
+	for _, snapshot := range snapshots {
+		current := ""
+		if snapshot.Current {
+			current = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", snapshot.Name, current, snapshot.Description)
+	}
+
+	return w.Flush()
+}
+
+func cmdSnapshotRestore(c CommandLine, api libmachine.API) error {
+	machineName, snapshotName, err := snapshotArgs(c)
+	if err != nil {
+		return err
+	}
+
+	snapshotter, err := loadSnapshotter(api, machineName)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Restoring %q to snapshot %q...", machineName, snapshotName)
+
+	if err := snapshotter.RestoreSnapshot(snapshotName); err != nil {
+		return fmt.Errorf("Error restoring snapshot %q: %s", snapshotName, err)
+	}
+
+	return nil
+}
+
+func cmdSnapshotRm(c CommandLine, api libmachine.API) error {
+	machineName, snapshotName, err := snapshotArgs(c)
+	if err != nil {
+		return err
+	}
+
+	snapshotter, err := loadSnapshotter(api, machineName)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Removing snapshot %q of %q...", snapshotName, machineName)
+
+	if err := snapshotter.DeleteSnapshot(snapshotName); err != nil {
+		return fmt.Errorf("Error removing snapshot %q: %s", snapshotName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/leoh0/machine/commands/commandstest"
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSnapshotDriver struct {
+	fakedriver.Driver
+	snapshots []drivers.Snapshot
+	restored  string
+}
+
+func (d *fakeSnapshotDriver) CreateSnapshot(name, description string) error {
+	d.snapshots = append(d.snapshots, drivers.Snapshot{Name: name, Description: description})
+	return nil
+}
+
+func (d *fakeSnapshotDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	return d.snapshots, nil
+}
+
+func (d *fakeSnapshotDriver) RestoreSnapshot(name string) error {
+	d.restored = name
+	return nil
+}
+
+func (d *fakeSnapshotDriver) DeleteSnapshot(name string) error {
+	remaining := []drivers.Snapshot{}
+	for _, snapshot := range d.snapshots {
+		if snapshot.Name != name {
+			remaining = append(remaining, snapshot)
+		}
+	}
+	d.snapshots = remaining
+	return nil
+}
+
+func TestCmdSnapshotCreateAndRm(t *testing.T) {
+	driver := &fakeSnapshotDriver{}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "default", DriverName: "virtualbox", Driver: driver},
+		},
+	}
+
+	err := cmdSnapshotCreate(&commandstest.FakeCommandLine{
+		CliArgs: []string{"default", "base"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"description": "fresh install",
+			},
+		},
+	}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{{Name: "base", Description: "fresh install"}}, driver.snapshots)
+
+	err = cmdSnapshotRm(&commandstest.FakeCommandLine{
+		CliArgs: []string{"default", "base"},
+	}, api)
+
+	assert.NoError(t, err)
+	assert.Empty(t, driver.snapshots)
+}
+
+func TestCmdSnapshotLs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	original := snapshotWriter
+	snapshotWriter = buf
+	defer func() { snapshotWriter = original }()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "default",
+				DriverName: "virtualbox",
+				Driver: &fakeSnapshotDriver{
+					snapshots: []drivers.Snapshot{
+						{Name: "base", Description: "fresh install"},
+						{Name: "with-compose", Current: true},
+					},
+				},
+			},
+		},
+	}
+
+	err := cmdSnapshotLs(&commandstest.FakeCommandLine{}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME           CURRENT   DESCRIPTION\nbase                     fresh install\nwith-compose   *         \n", buf.String())
+}
+
+func TestCmdSnapshotLsJSONOutput(t *testing.T) {
+	buf, restore := captureJSONOutput()
+	defer restore()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "default",
+				DriverName: "virtualbox",
+				Driver: &fakeSnapshotDriver{
+					snapshots: []drivers.Snapshot{
+						{Name: "base", Description: "fresh install", Current: true},
+					},
+				},
+			},
+		},
+	}
+
+	err := cmdSnapshotLs(&commandstest.FakeCommandLine{}, api)
+	assert.NoError(t, err)
+
+	assert.NoError(t, output.flush("snapshot ls", err))
+	assert.Equal(t, `{
+    "command": "snapshot ls",
+    "result": [
+        {
+            "id": "",
+            "name": "base",
+            "description": "fresh install",
+            "current": true
+        }
+    ]
+}
+`, buf.String())
+}
+
+func TestCmdSnapshotRestore(t *testing.T) {
+	driver := &fakeSnapshotDriver{}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "default", DriverName: "virtualbox", Driver: driver},
+		},
+	}
+
+	err := cmdSnapshotRestore(&commandstest.FakeCommandLine{
+		CliArgs: []string{"default", "base"},
+	}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "base", driver.restored)
+}
+
+func TestCmdSnapshotExpectsTwoArgs(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "default", DriverName: "virtualbox", Driver: &fakeSnapshotDriver{}},
+		},
+	}
+
+	err := cmdSnapshotRestore(&commandstest.FakeCommandLine{
+		CliArgs: []string{"default"},
+	}, api)
+
+	assert.Equal(t, ErrExpectedMachineAndSnapshot, err)
+}
+
+func TestCmdSnapshotNotSupported(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "default", DriverName: "virtualbox", Driver: &fakedriver.Driver{}},
+		},
+	}
+
+	err := cmdSnapshotCreate(&commandstest.FakeCommandLine{
+		CliArgs: []string{"default", "base"},
+	}, api)
+
+	assert.EqualError(t, err, `Error: machine "default" uses the virtualbox driver: Driver does not support snapshots`)
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/leoh0/machine/libmachine"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/ssh"
+	"github.com/leoh0/machine/libmachine/state"
+)
+
+// SSHKeyscanResult is the result of the ssh-keyscan command.
+type SSHKeyscanResult struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func cmdSSHKeyscan(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	host, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	knownHosts := drivers.GetSSHKnownHostsFromDriver(host.Driver)
+	if knownHosts == nil {
+		return fmt.Errorf("Error: Machine %q has no SSH key of its own, its SSH host key is not pinned", host.Name)
+	}
+
+	currentState, err := host.Driver.GetState()
+	if err != nil {
+		return err
+	}
+
+	if currentState != state.Running {
+		return errStateInvalidForSSH{host.Name}
+	}
+
+	if c.Bool("reset") {
+		log.Infof("Forgetting the SSH host key of %q...", host.Name)
+		if err := knownHosts.Reset(); err != nil {
+			return fmt.Errorf("Error removing %s: %s", knownHosts.Path, err)
+		}
+	}
+
+	hostname, err := host.Driver.GetSSHHostname()
+	if err != nil {
+		return err
+	}
+
+	port, err := host.Driver.GetSSHPort()
+	if err != nil {
+		return err
+	}
+
+	key, err := knownHosts.Scan(hostname, port)
+	if err != nil {
+		return err
+	}
+
+	result := SSHKeyscanResult{
+		Name:        host.Name,
+		Type:        key.Type(),
+		Fingerprint: ssh.Fingerprint(key),
+	}
+
+	output.printResult(result, func() {
+		fmt.Printf("%s %s\n", result.Type, result.Fingerprint)
+	})
+
+	return nil
+}
@@ -109,3 +109,22 @@ func TestCmdSSH(t *testing.T) {
 		}
 	}
 }
+
+func TestCmdSSHKeyscan(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "default",
+				Driver: &fakedriver.Driver{
+					MockState: state.Running,
+				},
+			},
+		},
+	}
+
+	err := cmdSSHKeyscan(&commandstest.FakeCommandLine{CliArgs: []string{"default", "other"}}, api)
+	assert.Equal(t, ErrExpectedOneMachine, err)
+
+	err = cmdSSHKeyscan(&commandstest.FakeCommandLine{CliArgs: []string{"default"}}, api)
+	assert.EqualError(t, err, `Error: Machine "default" has no SSH key of its own, its SSH host key is not pinned`)
+}
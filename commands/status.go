@@ -7,6 +7,12 @@ import (
 	"github.com/leoh0/machine/libmachine/log"
 )
 
+// StatusResult is the result of the status command.
+type StatusResult struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
 func cmdStatus(c CommandLine, api libmachine.API) error {
 	if len(c.Args()) > 1 {
 		return ErrExpectedOneMachine
@@ -27,7 +33,12 @@ func cmdStatus(c CommandLine, api libmachine.API) error {
 		return fmt.Errorf("error getting state for host %s: %s", host.Name, err)
 	}
 
-	log.Info(currentState)
+	output.printResult(StatusResult{
+		Name:  host.Name,
+		State: currentState.String(),
+	}, func() {
+		log.Info(currentState)
+	})
 
 	return nil
 }
@@ -6,6 +6,12 @@ import (
 	"github.com/leoh0/machine/libmachine"
 )
 
+// URLResult is the result of the url command.
+type URLResult struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
 func cmdURL(c CommandLine, api libmachine.API) error {
 	if len(c.Args()) > 1 {
 		return ErrExpectedOneMachine
@@ -26,7 +32,12 @@ func cmdURL(c CommandLine, api libmachine.API) error {
 		return err
 	}
 
-	fmt.Println(url)
+	output.printResult(URLResult{
+		Name: host.Name,
+		URL:  url,
+	}, func() {
+		fmt.Println(url)
+	})
 
 	return nil
 }
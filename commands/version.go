@@ -10,13 +10,23 @@ import (
 	"github.com/leoh0/machine/libmachine/mcndockerclient"
 )
 
+// VersionResult is the result of the version command. Name and
+// DockerVersion are only set when a machine is given.
+type VersionResult struct {
+	Version       string `json:"version,omitempty"`
+	Name          string `json:"name,omitempty"`
+	DockerVersion string `json:"dockerVersion,omitempty"`
+}
+
 func cmdVersion(c CommandLine, api libmachine.API) error {
 	return printVersion(c, api, os.Stdout)
 }
 
 func printVersion(c CommandLine, api libmachine.API, out io.Writer) error {
 	if len(c.Args()) == 0 {
-		c.ShowVersion()
+		output.printResult(VersionResult{
+			Version: c.Application().Version,
+		}, c.ShowVersion)
 		return nil
 	}
 
@@ -34,7 +44,12 @@ func printVersion(c CommandLine, api libmachine.API, out io.Writer) error {
 		return err
 	}
 
-	fmt.Fprintln(out, version)
+	output.printResult(VersionResult{
+		Name:          host.Name,
+		DockerVersion: version,
+	}, func() {
+		fmt.Fprintln(out, version)
+	})
 
 	return nil
 }
@@ -573,13 +573,13 @@ func (d *Driver) securityGroupIds() (ids []string) {
 }
 
 func (d *Driver) Base64UserData() (userdata string, err error) {
-	if d.UserDataFile != "" {
-		buf, ioerr := ioutil.ReadFile(d.UserDataFile)
-		if ioerr != nil {
-			log.Warnf("failed to read user data file %q: %s", d.UserDataFile, ioerr)
-			err = errorReadingUserData
-			return
-		}
+	buf, ioerr := drivers.ReadUserData(d.UserDataFile, d.ResolveStorePath(drivers.CloudInitFile))
+	if ioerr != nil {
+		log.Warnf("failed to read user data file %q: %s", d.UserDataFile, ioerr)
+		err = errorReadingUserData
+		return
+	}
+	if len(buf) > 0 {
 		userdata = base64.StdEncoding.EncodeToString(buf)
 	}
 	return
@@ -0,0 +1,129 @@
+package amazonec2
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/log"
+)
+
+// instanceTypes are the general purpose instance types Resize picks from
+// when given a number of CPUs or an amount of memory, smallest first.
+var instanceTypes = []struct {
+	name   string
+	cpu    int
+	memory int
+}{
+	{"t2.nano", 1, 512},
+	{"t2.micro", 1, 1024},
+	{"t2.small", 1, 2048},
+	{"t2.medium", 2, 4096},
+	{"t2.large", 2, 8192},
+	{"t2.xlarge", 4, 16384},
+	{"t2.2xlarge", 8, 32768},
+	{"m5.4xlarge", 16, 65536},
+	{"m5.8xlarge", 32, 131072},
+	{"m5.12xlarge", 48, 196608},
+	{"m5.16xlarge", 64, 262144},
+	{"m5.24xlarge", 96, 393216},
+}
+
+// resizedInstanceType returns the smallest instance type with at least the
+// given CPUs and memory. Zero values are taken from the current instance
+// type when it is a known one.
+func resizedInstanceType(current string, cpu, memory int) (string, error) {
+	for _, t := range instanceTypes {
+		if t.name == current {
+			if cpu == 0 {
+				cpu = t.cpu
+			}
+			if memory == 0 {
+				memory = t.memory
+			}
+		}
+	}
+
+	for _, t := range instanceTypes {
+		if t.cpu >= cpu && t.memory >= memory {
+			return t.name, nil
+		}
+	}
+
+	return "", fmt.Errorf("No instance type has %d CPUs and %dMB of memory", cpu, memory)
+}
+
+// Resize changes the instance type of a stopped instance to match the CPUs
+// and memory, and grows its root EBS volume. Only the volume is grown, the
+// partition and filesystem on it keep their size until they are grown on
+// the machine, which cloud-init does at boot on the default Ubuntu AMI.
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if err := drivers.MustBeStopped(d); err != nil {
+		return err
+	}
+
+	instanceType := d.InstanceType
+	if opts.CPU != 0 || opts.Memory != 0 {
+		var err error
+		if instanceType, err = resizedInstanceType(d.InstanceType, opts.CPU, opts.Memory); err != nil {
+			return err
+		}
+	}
+
+	rootSize := d.RootSize
+	if opts.DiskSize != 0 {
+		// The root volume size is in GiB.
+		rootSize = int64((opts.DiskSize + 1023) / 1024)
+		if rootSize < d.RootSize {
+			return fmt.Errorf("The root volume can only grow, it is already %dGB", d.RootSize)
+		}
+	}
+
+	if instanceType != d.InstanceType {
+		log.Infof("Changing instance type from %s to %s...", d.InstanceType, instanceType)
+		if _, err := d.getClient().ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+			InstanceId: &d.InstanceId,
+			InstanceType: &ec2.AttributeValue{
+				Value: aws.String(instanceType),
+			},
+		}); err != nil {
+			return err
+		}
+		d.InstanceType = instanceType
+	}
+
+	if rootSize != d.RootSize {
+		volumeID, err := d.rootVolumeID()
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Growing root volume %s to %dGB...", volumeID, rootSize)
+		if _, err := d.getClient().ModifyVolume(&ec2.ModifyVolumeInput{
+			VolumeId: &volumeID,
+			Size:     aws.Int64(rootSize),
+		}); err != nil {
+			return err
+		}
+		d.RootSize = rootSize
+		log.Infof("The filesystem of %s is grown to the new volume size when the instance boots.", d.MachineName)
+	}
+
+	return nil
+}
+
+func (d *Driver) rootVolumeID() (string, error) {
+	inst, err := d.getInstance()
+	if err != nil {
+		return "", err
+	}
+
+	for _, bdm := range inst.BlockDeviceMappings {
+		if inst.RootDeviceName != nil && bdm.DeviceName != nil && *bdm.DeviceName == *inst.RootDeviceName && bdm.Ebs != nil && bdm.Ebs.VolumeId != nil {
+			return *bdm.Ebs.VolumeId, nil
+		}
+	}
+
+	return "", fmt.Errorf("No EBS root volume found for instance %s", d.InstanceId)
+}
@@ -0,0 +1,108 @@
+package amazonec2
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEC2WithInstance struct {
+	*fakeEC2
+	state string
+	calls []string
+}
+
+func (f *fakeEC2WithInstance) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{
+			{
+				Instances: []*ec2.Instance{
+					{
+						State:          &ec2.InstanceState{Name: aws.String(f.state)},
+						RootDeviceName: aws.String("/dev/sda1"),
+						BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{
+							{
+								DeviceName: aws.String("/dev/sda1"),
+								Ebs:        &ec2.EbsInstanceBlockDevice{VolumeId: aws.String("vol-1234")},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (f *fakeEC2WithInstance) ModifyInstanceAttribute(input *ec2.ModifyInstanceAttributeInput) (*ec2.ModifyInstanceAttributeOutput, error) {
+	f.calls = append(f.calls, "ModifyInstanceAttribute "+*input.InstanceType.Value)
+	return &ec2.ModifyInstanceAttributeOutput{}, nil
+}
+
+func (f *fakeEC2WithInstance) ModifyVolume(input *ec2.ModifyVolumeInput) (*ec2.ModifyVolumeOutput, error) {
+	f.calls = append(f.calls, "ModifyVolume "+*input.VolumeId)
+	return &ec2.ModifyVolumeOutput{}, nil
+}
+
+func TestResizedInstanceType(t *testing.T) {
+	instanceType, err := resizedInstanceType("t2.micro", 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "t2.medium", instanceType)
+
+	instanceType, err = resizedInstanceType("t2.micro", 0, 8000)
+	assert.NoError(t, err)
+	assert.Equal(t, "t2.large", instanceType)
+
+	instanceType, err = resizedInstanceType("c5.large", 0, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, "t2.micro", instanceType)
+
+	_, err = resizedInstanceType("t2.micro", 256, 0)
+	assert.EqualError(t, err, "No instance type has 256 CPUs and 1024MB of memory")
+}
+
+func TestResizeRunningInstance(t *testing.T) {
+	client := &fakeEC2WithInstance{state: ec2.InstanceStateNameRunning}
+	driver := NewCustomTestDriver(client)
+
+	err := driver.Resize(drivers.ResizeOptions{CPU: 2, DiskSize: 32 * 1024})
+
+	assert.Equal(t, drivers.ErrHostIsNotStopped, err)
+	assert.Empty(t, client.calls)
+	assert.Equal(t, "t2.micro", driver.InstanceType)
+}
+
+func TestResizeStoppedInstance(t *testing.T) {
+	client := &fakeEC2WithInstance{state: ec2.InstanceStateNameStopped}
+	driver := NewCustomTestDriver(client)
+
+	err := driver.Resize(drivers.ResizeOptions{CPU: 2, DiskSize: 32 * 1024})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ModifyInstanceAttribute t2.medium",
+		"ModifyVolume vol-1234",
+	}, client.calls)
+	assert.Equal(t, "t2.medium", driver.InstanceType)
+	assert.Equal(t, int64(32), driver.RootSize)
+}
+
+func TestResizeNothingToChange(t *testing.T) {
+	client := &fakeEC2WithInstance{state: ec2.InstanceStateNameStopped}
+	driver := NewCustomTestDriver(client)
+
+	err := driver.Resize(drivers.ResizeOptions{CPU: 1, DiskSize: 16 * 1024})
+
+	assert.NoError(t, err)
+	assert.Empty(t, client.calls)
+}
+
+func TestResizeCannotShrinkRootVolume(t *testing.T) {
+	driver := NewCustomTestDriver(&fakeEC2WithInstance{state: ec2.InstanceStateNameStopped})
+
+	err := driver.Resize(drivers.ResizeOptions{DiskSize: 1024})
+
+	assert.EqualError(t, err, "The root volume can only grow, it is already 16GB")
+}
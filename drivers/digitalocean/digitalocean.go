@@ -207,13 +207,9 @@ func (d *Driver) PreCreateCheck() error {
 }
 
 func (d *Driver) Create() error {
-	var userdata string
-	if d.UserDataFile != "" {
-		buf, err := ioutil.ReadFile(d.UserDataFile)
-		if err != nil {
-			return err
-		}
-		userdata = string(buf)
+	userdata, err := drivers.ReadUserData(d.UserDataFile, d.ResolveStorePath(drivers.CloudInitFile))
+	if err != nil {
+		return err
 	}
 
 	log.Infof("Creating SSH key...")
@@ -237,7 +233,7 @@ func (d *Driver) Create() error {
 		IPv6:              d.IPv6,
 		PrivateNetworking: d.PrivateNetworking,
 		Backups:           d.Backups,
-		UserData:          userdata,
+		UserData:          string(userdata),
 		SSHKeys:           []godo.DropletCreateSSHKey{{ID: d.SSHKeyID}},
 		Monitoring:        d.Monitoring,
 		Tags:              d.getTags(),
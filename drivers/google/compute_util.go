@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/leoh0/machine/drivers/driverutil"
+	"github.com/leoh0/machine/libmachine/drivers"
 	"github.com/leoh0/machine/libmachine/log"
 	raw "google.golang.org/api/compute/v1"
 
@@ -274,6 +275,22 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 		},
 	}
 
+	userData, err := drivers.ReadUserData(d.UserDataFile, d.ResolveStorePath(drivers.CloudInitFile))
+	if err != nil {
+		return err
+	}
+	if len(userData) > 0 {
+		value := string(userData)
+		instance.Metadata = &raw.Metadata{
+			Items: []*raw.MetadataItems{
+				{
+					Key:   "user-data",
+					Value: &value,
+				},
+			},
+		}
+	}
+
 	if strings.Contains(c.subnetwork, "/subnetworks/") {
 		instance.NetworkInterfaces[0].Subnetwork = c.subnetwork
 	} else if c.subnetwork != "" {
@@ -375,14 +392,22 @@ func (c *ComputeUtil) uploadSSHKey(instance *raw.Instance, sshKeyPath string) er
 
 	metaDataValue := fmt.Sprintf("%s:%s %s\n", c.userName, strings.TrimSpace(string(sshKey)), c.userName)
 
+	// The other items, like the user-data, are kept.
+	items := []*raw.MetadataItems{
+		{
+			Key:   "sshKeys",
+			Value: &metaDataValue,
+		},
+	}
+	for _, item := range instance.Metadata.Items {
+		if item.Key != "sshKeys" {
+			items = append(items, item)
+		}
+	}
+
 	op, err := c.service.Instances.SetMetadata(c.project, c.zone, c.instanceName, &raw.Metadata{
 		Fingerprint: instance.Metadata.Fingerprint,
-		Items: []*raw.MetadataItems{
-			{
-				Key:   "sshKeys",
-				Value: &metaDataValue,
-			},
-		},
+		Items:       items,
 	}).Do()
 
 	return c.waitForRegionalOp(op.Name)
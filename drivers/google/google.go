@@ -33,6 +33,7 @@ type Driver struct {
 	Tags              string
 	UseExisting       bool
 	OpenPorts         []string
+	UserDataFile      string
 }
 
 const (
@@ -152,6 +153,11 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:  "google-open-port",
 			Usage: "Make the specified port number accessible from the Internet, e.g, 8080/tcp",
 		},
+		mcnflag.StringFlag{
+			Name:   "google-userdata",
+			Usage:  "Path to file with cloud-init user-data",
+			EnvVar: "GOOGLE_USERDATA",
+		},
 	}
 }
 
@@ -218,6 +224,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 		d.Scopes = flags.String("google-scopes")
 		d.Tags = flags.String("google-tags")
 		d.OpenPorts = flags.StringSlice("google-open-port")
+		d.UserDataFile = flags.String("google-userdata")
 	}
 	d.SSHUser = flags.String("google-username")
 	d.SSHPort = 22
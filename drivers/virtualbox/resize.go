@@ -0,0 +1,99 @@
+package virtualbox
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/log"
+)
+
+// Resize changes the CPUs, memory and disk size of a stopped VM. The disk
+// can only grow. VirtualBox cannot resize the VMDK disk made at creation,
+// so it is first converted to a VDI disk.
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if err := drivers.MustBeStopped(d); err != nil {
+		return err
+	}
+
+	if opts.DiskSize != 0 && opts.DiskSize < d.DiskSize {
+		return fmt.Errorf("The disk can only grow, it is already %dMB", d.DiskSize)
+	}
+
+	if opts.CPU != 0 || opts.Memory != 0 {
+		modifyFlags := []string{"modifyvm", d.MachineName}
+
+		cpus := opts.CPU
+		if cpus > 32 {
+			cpus = 32
+		}
+		if cpus != 0 {
+			modifyFlags = append(modifyFlags, "--cpus", strconv.Itoa(cpus))
+		}
+		if opts.Memory != 0 {
+			modifyFlags = append(modifyFlags, "--memory", strconv.Itoa(opts.Memory))
+		}
+
+		if err := d.vbm(modifyFlags...); err != nil {
+			return err
+		}
+
+		if cpus != 0 {
+			d.CPU = cpus
+		}
+		if opts.Memory != 0 {
+			d.Memory = opts.Memory
+		}
+	}
+
+	if opts.DiskSize != 0 && opts.DiskSize != d.DiskSize {
+		if err := d.growDisk(opts.DiskSize); err != nil {
+			return err
+		}
+		d.DiskSize = opts.DiskSize
+	}
+
+	return nil
+}
+
+func (d *Driver) growDisk(size int) error {
+	vdiPath := d.ResolveStorePath("disk.vdi")
+
+	if diskPath := d.diskPath(); diskPath != vdiPath {
+		log.Infof("Converting %s to VDI...", diskPath)
+
+		if err := d.vbm("clonemedium", "disk", diskPath, vdiPath, "--format", "VDI"); err != nil {
+			return err
+		}
+
+		if err := d.vbm("storageattach", d.MachineName,
+			"--storagectl", "SATA",
+			"--port", "1",
+			"--device", "0",
+			"--type", "hdd",
+			"--medium", vdiPath); err != nil {
+			return err
+		}
+
+		if err := d.vbm("closemedium", "disk", diskPath, "--delete"); err != nil {
+			return err
+		}
+	}
+
+	return d.vbm("modifymedium", "disk", vdiPath, "--resize", strconv.Itoa(size))
+}
+
+// diskPath returns the disk of the VM, which is the VMDK disk made at
+// creation unless it has been converted by Resize.
+func (d *Driver) diskPath() string {
+	if vdiPath := d.ResolveStorePath("disk.vdi"); fileExists(vdiPath) {
+		return vdiPath
+	}
+	return d.ResolveStorePath("disk.vmdk")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,98 @@
+package virtualbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResizeCPUAndMemory(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="poweroff"`, nil},
+		{"vbm modifyvm default --cpus 4 --memory 4096", "", nil},
+	})
+
+	err := driver.Resize(drivers.ResizeOptions{CPU: 4, Memory: 4096})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, driver.CPU)
+	assert.Equal(t, 4096, driver.Memory)
+}
+
+func TestResizeCapsCPU(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="poweroff"`, nil},
+		{"vbm modifyvm default --cpus 32", "", nil},
+	})
+
+	err := driver.Resize(drivers.ResizeOptions{CPU: 64})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 32, driver.CPU)
+}
+
+func TestResizeConvertsAndGrowsDisk(t *testing.T) {
+	driver := newTestDriver("default")
+	vmdkPath := filepath.Join("machines", "default", "disk.vmdk")
+	vdiPath := filepath.Join("machines", "default", "disk.vdi")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="poweroff"`, nil},
+		{"vbm clonemedium disk " + vmdkPath + " " + vdiPath + " --format VDI", "", nil},
+		{"vbm storageattach default --storagectl SATA --port 1 --device 0 --type hdd --medium " + vdiPath, "", nil},
+		{"vbm closemedium disk " + vmdkPath + " --delete", "", nil},
+		{"vbm modifymedium disk " + vdiPath + " --resize 40000", "", nil},
+	})
+
+	err := driver.Resize(drivers.ResizeOptions{DiskSize: 40000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 40000, driver.DiskSize)
+}
+
+func TestResizeGrowsConvertedDisk(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "virtualbox")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	driver := NewDriver("default", storePath)
+	vdiPath := driver.ResolveStorePath("disk.vdi")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(vdiPath), 0700))
+	assert.NoError(t, ioutil.WriteFile(vdiPath, []byte{}, 0600))
+
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="poweroff"`, nil},
+		{"vbm modifymedium disk " + vdiPath + " --resize 40000", "", nil},
+	})
+
+	err = driver.Resize(drivers.ResizeOptions{DiskSize: 40000})
+
+	assert.NoError(t, err)
+}
+
+func TestResizeCannotShrinkDisk(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="poweroff"`, nil},
+	})
+
+	err := driver.Resize(drivers.ResizeOptions{DiskSize: 1000})
+
+	assert.EqualError(t, err, "The disk can only grow, it is already 20000MB")
+}
+
+func TestResizeMustBeStopped(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="running"`, nil},
+	})
+
+	err := driver.Resize(drivers.ResizeOptions{CPU: 2})
+
+	assert.Equal(t, drivers.ErrHostIsNotStopped, err)
+}
@@ -0,0 +1,101 @@
+package virtualbox
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/state"
+)
+
+const noSnapshots = "does not have any snapshots"
+
+var reSnapshotLine = regexp.MustCompile(`^([\w-]+)="(.*)"$`)
+
+// CreateSnapshot takes a snapshot of the VM. A running VM is paused while
+// the snapshot is taken.
+func (d *Driver) CreateSnapshot(name, description string) error {
+	args := []string{"snapshot", d.MachineName, "take", name}
+	if description != "" {
+		args = append(args, "--description", description)
+	}
+
+	return d.vbm(args...)
+}
+
+// ListSnapshots returns the snapshots of the VM, parents before children.
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	stdout, err := d.vbmOut("snapshot", d.MachineName, "list", "--machinereadable")
+	if strings.Contains(stdout, noSnapshots) {
+		return []drivers.Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSnapshots(stdout)
+}
+
+// parseSnapshots reads the output of `VBoxManage snapshot list
+// --machinereadable`. Every snapshot is described by a set of keys sharing
+// the same suffix, e.g. SnapshotName-1 and SnapshotUUID-1.
+func parseSnapshots(stdout string) ([]drivers.Snapshot, error) {
+	snapshots := []drivers.Snapshot{}
+	bySuffix := map[string]int{}
+	currentID := ""
+
+	snapshot := func(suffix string) *drivers.Snapshot {
+		i, ok := bySuffix[suffix]
+		if !ok {
+			i = len(snapshots)
+			bySuffix[suffix] = i
+			snapshots = append(snapshots, drivers.Snapshot{})
+		}
+		return &snapshots[i]
+	}
+
+	err := parseKeyValues(stdout, reSnapshotLine, func(key, val string) error {
+		switch {
+		case key == "CurrentSnapshotUUID":
+			currentID = val
+		case strings.HasPrefix(key, "SnapshotName"):
+			snapshot(strings.TrimPrefix(key, "SnapshotName")).Name = val
+		case strings.HasPrefix(key, "SnapshotUUID"):
+			snapshot(strings.TrimPrefix(key, "SnapshotUUID")).ID = val
+		case strings.HasPrefix(key, "SnapshotDescription"):
+			snapshot(strings.TrimPrefix(key, "SnapshotDescription")).Description = val
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range snapshots {
+		snapshots[i].Current = currentID != "" && snapshots[i].ID == currentID
+	}
+
+	return snapshots, nil
+}
+
+// RestoreSnapshot reverts the VM to a snapshot. VirtualBox only restores
+// snapshots of VMs which are not running.
+func (d *Driver) RestoreSnapshot(name string) error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s == state.Running || s == state.Paused {
+		return fmt.Errorf("The machine must be stopped to restore a snapshot, it is %s", strings.ToLower(s.String()))
+	}
+
+	return d.vbm("snapshot", d.MachineName, "restore", name)
+}
+
+// DeleteSnapshot removes a snapshot and merges its changes into its
+// children.
+func (d *Driver) DeleteSnapshot(name string) error {
+	return d.vbm("snapshot", d.MachineName, "delete", name)
+}
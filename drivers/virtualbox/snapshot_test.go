@@ -0,0 +1,128 @@
+package virtualbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+const stdOutSnapshots = `SnapshotName="base"
+SnapshotUUID="2d8b7a4c-6a42-4b8c-b86a-0e0f0f0b2a11"
+SnapshotDescription="fresh install"
+SnapshotName-1="with-compose"
+SnapshotUUID-1="9b5c4e02-1d3f-4a71-9f7e-5a9e35b7c0d2"
+SnapshotName-1-1="before-upgrade"
+SnapshotUUID-1-1="f4a8e1c6-3b2d-49d5-8e6f-7c1b2a3d4e5f"
+SnapshotDescription-1-1="a=b"
+CurrentSnapshotName="with-compose"
+CurrentSnapshotUUID="9b5c4e02-1d3f-4a71-9f7e-5a9e35b7c0d2"
+CurrentSnapshotNode="SnapshotName-1"
+`
+
+func TestDriverIsSnapshotter(t *testing.T) {
+	_, err := drivers.SnapshotterFor(newTestDriver("default"))
+
+	assert.NoError(t, err)
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	driver := newTestDriver("default")
+	driver.VBoxManager = &VBoxManagerMock{
+		args: "snapshot default take base --description fresh install",
+	}
+
+	err := driver.CreateSnapshot("base", "fresh install")
+
+	assert.NoError(t, err)
+}
+
+func TestCreateSnapshotWithoutDescription(t *testing.T) {
+	driver := newTestDriver("default")
+	driver.VBoxManager = &VBoxManagerMock{
+		args: "snapshot default take base",
+	}
+
+	err := driver.CreateSnapshot("base", "")
+
+	assert.NoError(t, err)
+}
+
+func TestListSnapshots(t *testing.T) {
+	driver := newTestDriver("default")
+	driver.VBoxManager = &VBoxManagerMock{
+		args:   "snapshot default list --machinereadable",
+		stdOut: stdOutSnapshots,
+	}
+
+	snapshots, err := driver.ListSnapshots()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{
+		{ID: "2d8b7a4c-6a42-4b8c-b86a-0e0f0f0b2a11", Name: "base", Description: "fresh install"},
+		{ID: "9b5c4e02-1d3f-4a71-9f7e-5a9e35b7c0d2", Name: "with-compose", Current: true},
+		{ID: "f4a8e1c6-3b2d-49d5-8e6f-7c1b2a3d4e5f", Name: "before-upgrade", Description: "a=b"},
+	}, snapshots)
+}
+
+func TestListNoSnapshots(t *testing.T) {
+	driver := newTestDriver("default")
+	driver.VBoxManager = &VBoxManagerMock{
+		args:   "snapshot default list --machinereadable",
+		stdOut: "This machine does not have any snapshots\n",
+		err:    errors.New("exit status 1"),
+	}
+
+	snapshots, err := driver.ListSnapshots()
+
+	assert.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
+
+func TestListSnapshotsError(t *testing.T) {
+	driver := newTestDriver("default")
+	driver.VBoxManager = &VBoxManagerMock{
+		args: "snapshot default list --machinereadable",
+		err:  errors.New("BUG"),
+	}
+
+	snapshots, err := driver.ListSnapshots()
+
+	assert.EqualError(t, err, "BUG")
+	assert.Nil(t, snapshots)
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="poweroff"`, nil},
+		{"vbm snapshot default restore base", "", nil},
+	})
+
+	err := driver.RestoreSnapshot("base")
+
+	assert.NoError(t, err)
+}
+
+func TestRestoreSnapshotRunning(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="running"`, nil},
+	})
+
+	err := driver.RestoreSnapshot("base")
+
+	assert.EqualError(t, err, "The machine must be stopped to restore a snapshot, it is running")
+}
+
+func TestDeleteSnapshot(t *testing.T) {
+	driver := newTestDriver("default")
+	driver.VBoxManager = &VBoxManagerMock{
+		args: "snapshot default delete base",
+	}
+
+	err := driver.DeleteSnapshot("base")
+
+	assert.NoError(t, err)
+}
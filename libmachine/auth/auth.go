@@ -12,6 +12,16 @@ type Options struct {
 	ServerKeyRemotePath  string
 	ClientCertPath       string
 	ServerCertSANs       []string
+	// TLSKeyType and SSHKeyType are the types of the keys generated for
+	// the certificates and for SSH, RSA when empty.
+	TLSKeyType string
+	SSHKeyType string
+	// CertValidityDays is how long the generated certificates are valid,
+	// cert.DefaultValidityDays when zero.
+	CertValidityDays int
+	// SignerPath is the helper binary which signs the certificates in
+	// place of the CA key, see cert.ExternalSigner.
+	SignerPath string
 	// StorePath is left in for historical reasons, but not really meant to
 	// be used directly.
 	StorePath string
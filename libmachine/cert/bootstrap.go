@@ -1,8 +1,10 @@
 package cert
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 
 	"github.com/leoh0/machine/libmachine/auth"
@@ -21,13 +23,51 @@ func createCACert(authOptions *auth.Options, caOrg string, bits int) error {
 		return errors.New("certificate authority key already exists")
 	}
 
-	if err := GenerateCACertificate(caCertPath, caPrivateKeyPath, caOrg, bits); err != nil {
+	caOptions := &Options{
+		CertFile:     caCertPath,
+		KeyFile:      caPrivateKeyPath,
+		Org:          caOrg,
+		Bits:         bits,
+		KeyType:      authOptions.TLSKeyType,
+		ValidityDays: authOptions.CertValidityDays,
+	}
+
+	if err := GenerateCACertificateWithOptions(caOptions); err != nil {
 		return fmt.Errorf("generating CA certificate failed: %s", err)
 	}
 
+	// The CA which was imported, if any, is replaced.
+	os.Remove(importedCAMarker(caCertPath))
+
 	return nil
 }
 
+// fetchCACert writes the certificate of the CA of an external signer, which
+// holds the CA key. It tells whether the CA changed, which is also the case
+// when the CA on disk was generated before the signer was used.
+func fetchCACert(caCertPath string, signer Signer) (bool, error) {
+	caCert, err := signer.CACertificate()
+	if err != nil {
+		return false, fmt.Errorf("fetching CA certificate failed: %s", err)
+	}
+
+	current, err := ioutil.ReadFile(caCertPath)
+	if err == nil && bytes.Equal(current, caCert) {
+		return false, nil
+	}
+
+	log.Infof("Fetching CA certificate: %s", caCertPath)
+
+	if err := ioutil.WriteFile(caCertPath, caCert, 0644); err != nil {
+		return false, err
+	}
+
+	// The CA which was imported, if any, is replaced.
+	os.Remove(importedCAMarker(caCertPath))
+
+	return true, nil
+}
+
 func createCert(authOptions *auth.Options, org string, bits int) error {
 	certDir := authOptions.CertDir
 	caCertPath := authOptions.CaCertPath
@@ -54,17 +94,19 @@ func createCert(authOptions *auth.Options, org string, bits int) error {
 
 	// Used to generate the client certificate.
 	certOptions := &Options{
-		Hosts:       []string{""},
-		CertFile:    clientCertPath,
-		KeyFile:     clientKeyPath,
-		CAFile:      caCertPath,
-		CAKeyFile:   caPrivateKeyPath,
-		Org:         org,
-		Bits:        bits,
-		SwarmMaster: false,
+		Hosts:        []string{""},
+		CertFile:     clientCertPath,
+		KeyFile:      clientKeyPath,
+		CAFile:       caCertPath,
+		CAKeyFile:    caPrivateKeyPath,
+		Org:          org,
+		Bits:         bits,
+		SwarmMaster:  false,
+		KeyType:      authOptions.TLSKeyType,
+		ValidityDays: authOptions.CertValidityDays,
 	}
 
-	if err := GenerateCert(certOptions); err != nil {
+	if err := GenerateCertWithSigner(certOptions, SignerFor(authOptions)); err != nil {
 		return fmt.Errorf("failure generating client certificate: %s", err)
 	}
 
@@ -96,7 +138,14 @@ func BootstrapCertificates(authOptions *auth.Options) error {
 		}
 	}
 
-	if _, err := os.Stat(caCertPath); os.IsNotExist(err) {
+	caChanged := false
+	if signer := SignerFor(authOptions); signer != nil {
+		changed, err := fetchCACert(caCertPath, signer)
+		if err != nil {
+			return err
+		}
+		caChanged = changed
+	} else if _, err := os.Stat(caCertPath); os.IsNotExist(err) {
 		if err := createCACert(authOptions, caOrg, bits); err != nil {
 			return err
 		}
@@ -106,6 +155,11 @@ func BootstrapCertificates(authOptions *auth.Options) error {
 			return err
 		}
 		if !current {
+			// An imported CA is never replaced by a generated one.
+			if imported, err := isImportedCA(caCertPath); err != nil || imported {
+				return fmt.Errorf("CA certificate %s is outdated, import a new one with `docker-machine certs import-ca`", caCertPath)
+			}
+
 			log.Info("CA certificate is outdated and needs to be regenerated")
 			os.Remove(caPrivateKeyPath)
 			if err := createCACert(authOptions, caOrg, bits); err != nil {
@@ -118,6 +172,15 @@ func BootstrapCertificates(authOptions *auth.Options) error {
 		if err := createCert(authOptions, org, bits); err != nil {
 			return err
 		}
+	} else if caChanged {
+		// The client must authenticate with a certificate of the CA the
+		// servers now trust.
+		log.Info("The CA of the signer changed, the client certificate needs to be regenerated")
+		log.Warn("The machines created before need `docker-machine regenerate-certs` to be issued certificates of the new CA.")
+		os.Remove(clientKeyPath)
+		if err := createCert(authOptions, org, bits); err != nil {
+			return err
+		}
 	} else {
 		current, err := CheckCertificateDate(clientCertPath)
 		if err != nil {
@@ -1,12 +1,16 @@
 package cert
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
 	"io/ioutil"
 	"math/big"
 	"net"
@@ -19,6 +23,12 @@ import (
 	"github.com/leoh0/machine/libmachine/log"
 )
 
+// Types of the keys of the TLS certificates.
+const (
+	KeyTypeRSA   = "rsa"
+	KeyTypeECDSA = "ecdsa"
+)
+
 var defaultGenerator = NewX509CertGenerator()
 
 type Options struct {
@@ -26,11 +36,23 @@ type Options struct {
 	CertFile, KeyFile, CAFile, CAKeyFile, Org string
 	Bits                                      int
 	SwarmMaster                               bool
+
+	// KeyType is the type of the generated key, KeyTypeRSA of Bits bits
+	// when empty, or KeyTypeECDSA for a P-256 key.
+	KeyType string
+
+	// ValidityDays is how long the certificate is valid,
+	// DefaultValidityDays when zero.
+	ValidityDays int
 }
 
 type Generator interface {
 	GenerateCACertificate(certFile, keyFile, org string, bits int) error
 	GenerateCert(opts *Options) error
+	// GenerateCertWithSigner is GenerateCert with the certificate issued
+	// by signer, the signing backend, rather than by the CA key of the
+	// options. A nil signer is the CASigner of the options.
+	GenerateCertWithSigner(opts *Options, signer Signer) error
 	ReadTLSConfig(addr string, authOptions *auth.Options) (*tls.Config, error)
 	ValidateCertificate(addr string, authOptions *auth.Options) (bool, error)
 }
@@ -41,14 +63,86 @@ func NewX509CertGenerator() Generator {
 	return &X509CertGenerator{}
 }
 
+// OptionsCAGenerator is implemented by the Generators which generate their
+// CA certificate with the key type and validity of the options.
+type OptionsCAGenerator interface {
+	GenerateCACertificateWithOptions(opts *Options) error
+}
+
 func GenerateCACertificate(certFile, keyFile, org string, bits int) error {
 	return defaultGenerator.GenerateCACertificate(certFile, keyFile, org, bits)
 }
 
+// GenerateCACertificateWithOptions generates a certificate authority like
+// GenerateCACertificate, with the key type and validity of opts. A
+// Generator set with SetCertGenerator which is not an OptionsCAGenerator
+// only generates the default ones.
+func GenerateCACertificateWithOptions(opts *Options) error {
+	if g, ok := defaultGenerator.(OptionsCAGenerator); ok {
+		return g.GenerateCACertificateWithOptions(opts)
+	}
+
+	if (opts.KeyType != "" && opts.KeyType != KeyTypeRSA) || opts.ValidityDays != 0 {
+		return fmt.Errorf("Error: The certificate generator does not support the key type and validity options")
+	}
+
+	return defaultGenerator.GenerateCACertificate(opts.CertFile, opts.KeyFile, opts.Org, opts.Bits)
+}
+
+// ValidateKeyType returns an error if keys of keyType cannot be generated.
+// The empty type is RSA.
+func ValidateKeyType(keyType string) error {
+	switch keyType {
+	case "", KeyTypeRSA, KeyTypeECDSA:
+		return nil
+	}
+	return fmt.Errorf("Unknown TLS key type %q, expected %s or %s", keyType, KeyTypeRSA, KeyTypeECDSA)
+}
+
+// generateKey generates the private key of a certificate.
+func generateKey(keyType string, bits int) (crypto.Signer, error) {
+	switch keyType {
+	case "", KeyTypeRSA:
+		return rsa.GenerateKey(rand.Reader, bits)
+	case KeyTypeECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	return nil, ValidateKeyType(keyType)
+}
+
+// writeKey writes a private key in PEM format, only readable by the user.
+func writeKey(keyFile string, priv crypto.Signer) error {
+	var block *pem.Block
+	switch key := priv.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	default:
+		return fmt.Errorf("Unsupported private key type %T", priv)
+	}
+
+	keyOut, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer keyOut.Close()
+
+	return pem.Encode(keyOut, block)
+}
+
 func GenerateCert(opts *Options) error {
 	return defaultGenerator.GenerateCert(opts)
 }
 
+func GenerateCertWithSigner(opts *Options, signer Signer) error {
+	return defaultGenerator.GenerateCertWithSigner(opts, signer)
+}
+
 func ValidateCertificate(addr string, authOptions *auth.Options) (bool, error) {
 	return defaultGenerator.ValidateCertificate(addr, authOptions)
 }
@@ -82,12 +176,16 @@ func (xcg *X509CertGenerator) getTLSConfig(caCert, cert, key []byte, allowInsecu
 	return &tlsConfig, nil
 }
 
-func (xcg *X509CertGenerator) newCertificate(org string) (*x509.Certificate, error) {
+func newCertificate(org string, validityDays int) (*x509.Certificate, error) {
+	if validityDays <= 0 {
+		validityDays = DefaultValidityDays
+	}
+
 	now := time.Now()
 	// need to set notBefore slightly in the past to account for time
 	// skew in the VMs otherwise the certs sometimes are not yet valid
 	notBefore := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute()-5, 0, 0, time.Local)
-	notAfter := notBefore.Add(time.Hour * 24 * 1080)
+	notAfter := notBefore.Add(time.Hour * 24 * time.Duration(validityDays))
 
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
@@ -113,7 +211,19 @@ func (xcg *X509CertGenerator) newCertificate(org string) (*x509.Certificate, err
 // and bit size and stores the resulting certificate and key file
 // in the arguments.
 func (xcg *X509CertGenerator) GenerateCACertificate(certFile, keyFile, org string, bits int) error {
-	template, err := xcg.newCertificate(org)
+	return xcg.GenerateCACertificateWithOptions(&Options{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		Org:      org,
+		Bits:     bits,
+	})
+}
+
+// GenerateCACertificateWithOptions generates a new certificate authority
+// from the org, key type and bit size of the options and stores the
+// resulting certificate and key in their CertFile and KeyFile.
+func (xcg *X509CertGenerator) GenerateCACertificateWithOptions(opts *Options) error {
+	template, err := newCertificate(opts.Org, opts.ValidityDays)
 	if err != nil {
 		return err
 	}
@@ -123,17 +233,17 @@ func (xcg *X509CertGenerator) GenerateCACertificate(certFile, keyFile, org strin
 	template.KeyUsage |= x509.KeyUsageKeyEncipherment
 	template.KeyUsage |= x509.KeyUsageKeyAgreement
 
-	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	priv, err := generateKey(opts.KeyType, opts.Bits)
 	if err != nil {
 		return err
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
 	if err != nil {
 		return err
 	}
 
-	certOut, err := os.Create(certFile)
+	certOut, err := os.Create(opts.CertFile)
 	if err != nil {
 		return err
 	}
@@ -141,85 +251,62 @@ func (xcg *X509CertGenerator) GenerateCACertificate(certFile, keyFile, org strin
 	pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
 	certOut.Close()
 
-	keyOut, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
-
-	}
-
-	pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
-	keyOut.Close()
-
-	return nil
+	return writeKey(opts.KeyFile, priv)
 }
 
-// GenerateCert generates a new certificate signed using the provided
-// certificate authority files and stores the result in the certificate
-// file and key provided.  The provided host names are set to the
-// appropriate certificate fields.
+// GenerateCert generates a new key and a certificate for it, issued by the
+// CA of the options, and stores them in the certificate file and key
+// provided. The provided host names are set to the appropriate certificate
+// fields.
 func (xcg *X509CertGenerator) GenerateCert(opts *Options) error {
-	template, err := xcg.newCertificate(opts.Org)
-	if err != nil {
-		return err
-	}
-	// client
-	if len(opts.Hosts) == 1 && opts.Hosts[0] == "" {
-		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
-		template.KeyUsage = x509.KeyUsageDigitalSignature
-	} else { // server
-		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
-		if opts.SwarmMaster {
-			// Extend the Swarm master's server certificate
-			// permissions to also be able to connect to downstream
-			// nodes as a client.
-			template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
-		}
-		for _, h := range opts.Hosts {
-			if ip := net.ParseIP(h); ip != nil {
-				template.IPAddresses = append(template.IPAddresses, ip)
-			} else {
-				template.DNSNames = append(template.DNSNames, h)
-			}
-		}
-	}
+	return xcg.GenerateCertWithSigner(opts, nil)
+}
 
-	tlsCert, err := tls.LoadX509KeyPair(opts.CAFile, opts.CAKeyFile)
-	if err != nil {
-		return err
+// GenerateCertWithSigner generates a certificate like GenerateCert, issued
+// by signer. The signer only sees a certificate request, never the key.
+func (xcg *X509CertGenerator) GenerateCertWithSigner(opts *Options, signer Signer) error {
+	if signer == nil {
+		signer = &CASigner{CAFile: opts.CAFile, CAKeyFile: opts.CAKeyFile}
 	}
 
-	priv, err := rsa.GenerateKey(rand.Reader, opts.Bits)
+	priv, err := generateKey(opts.KeyType, opts.Bits)
 	if err != nil {
 		return err
 	}
 
-	x509Cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			Organization: []string{opts.Org},
+		},
+	}, priv)
 	if err != nil {
 		return err
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, template, x509Cert, &priv.PublicKey, tlsCert.PrivateKey)
-	if err != nil {
-		return err
+	req := &SignRequest{
+		CSR:          string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})),
+		Usage:        UsageServer,
+		Hosts:        opts.Hosts,
+		Org:          opts.Org,
+		SwarmMaster:  opts.SwarmMaster,
+		ValidityDays: opts.ValidityDays,
+	}
+	// client
+	if len(opts.Hosts) == 1 && opts.Hosts[0] == "" {
+		req.Usage = UsageClient
+		req.Hosts = nil
 	}
 
-	certOut, err := os.Create(opts.CertFile)
+	chain, err := signer.Sign(req)
 	if err != nil {
 		return err
 	}
 
-	pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
-	certOut.Close()
-
-	keyOut, err := os.OpenFile(opts.KeyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
+	if err := ioutil.WriteFile(opts.CertFile, chain, 0644); err != nil {
 		return err
 	}
 
-	pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
-	keyOut.Close()
-
-	return nil
+	return writeKey(opts.KeyFile, priv)
 }
 
 // ReadTLSConfig reads the tls config for a machine.
@@ -269,24 +356,11 @@ func (xcg *X509CertGenerator) ValidateCertificate(addr string, authOptions *auth
 }
 
 func CheckCertificateDate(certPath string) (bool, error) {
-	log.Debugf("Reading certificate data from %s", certPath)
-	certBytes, err := ioutil.ReadFile(certPath)
-	if err != nil {
-		return false, err
-	}
-
-	log.Debug("Decoding PEM data...")
-	pemBlock, _ := pem.Decode(certBytes)
-	if pemBlock == nil {
-		return false, errors.New("Failed to decode PEM data")
-	}
-
-	log.Debug("Parsing certificate...")
-	cert, err := x509.ParseCertificate(pemBlock.Bytes)
+	notAfter, err := CertificateExpiry(certPath)
 	if err != nil {
 		return false, err
 	}
-	if time.Now().After(cert.NotAfter) {
+	if time.Now().After(notAfter) {
 		return false, nil
 	}
 
@@ -1,6 +1,8 @@
 package cert
 
 import (
+	"crypto/ecdsa"
+	"crypto/tls"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -31,6 +33,35 @@ func TestGenerateCACertificate(t *testing.T) {
 	}
 }
 
+// defaultCAGenerator is a Generator which only generates default CA
+// certificates.
+type defaultCAGenerator struct {
+	Generator
+	generated bool
+}
+
+func (g *defaultCAGenerator) GenerateCACertificate(certFile, keyFile, org string, bits int) error {
+	g.generated = true
+	return nil
+}
+
+func TestGenerateCACertificateWithOptionsFallback(t *testing.T) {
+	g := &defaultCAGenerator{}
+	SetCertGenerator(g)
+	defer SetCertGenerator(NewX509CertGenerator())
+
+	if err := GenerateCACertificateWithOptions(&Options{Org: "test-org", Bits: 2048}); err != nil {
+		t.Fatal(err)
+	}
+	if !g.generated {
+		t.Fatal("expected the CA certificate to be generated by the generator")
+	}
+
+	if err := GenerateCACertificateWithOptions(&Options{Org: "test-org", KeyType: KeyTypeECDSA}); err == nil {
+		t.Fatal("expected an error for a key type the generator does not support")
+	}
+}
+
 func TestGenerateCert(t *testing.T) {
 	tmpDir, err := ioutil.TempDir("", "machine-test-")
 	if err != nil {
@@ -45,7 +76,13 @@ func TestGenerateCert(t *testing.T) {
 	keyPath := filepath.Join(tmpDir, "cert-key.pem")
 	testOrg := "test-org"
 	bits := 2048
-	if err := GenerateCACertificate(caCertPath, caKeyPath, testOrg, bits); err != nil {
+	caOpts := &Options{
+		CertFile: caCertPath,
+		KeyFile:  caKeyPath,
+		Org:      testOrg,
+		Bits:     bits,
+	}
+	if err := GenerateCACertificateWithOptions(caOpts); err != nil {
 		t.Fatal(err)
 	}
 
@@ -79,3 +116,66 @@ func TestGenerateCert(t *testing.T) {
 		t.Fatalf("key not created at %s", keyPath)
 	}
 }
+
+func TestGenerateECDSACert(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "key.pem")
+	certPath := filepath.Join(tmpDir, "cert.pem")
+	keyPath := filepath.Join(tmpDir, "cert-key.pem")
+	caOpts := &Options{
+		CertFile: caCertPath,
+		KeyFile:  caKeyPath,
+		Org:      "test-org",
+		KeyType:  KeyTypeECDSA,
+	}
+	if err := GenerateCACertificateWithOptions(caOpts); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{
+		Hosts:     []string{"127.0.0.1"},
+		CertFile:  certPath,
+		CAKeyFile: caKeyPath,
+		CAFile:    caCertPath,
+		KeyFile:   keyPath,
+		Org:       "test-org",
+		KeyType:   KeyTypeECDSA,
+	}
+	if err := GenerateCert(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := keyPair.PrivateKey.(*ecdsa.PrivateKey); !ok {
+		t.Fatalf("expected an ECDSA key, got %T", keyPair.PrivateKey)
+	}
+}
+
+func TestGenerateCertOfUnknownKeyType(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	caOpts := &Options{
+		CertFile: filepath.Join(tmpDir, "ca.pem"),
+		KeyFile:  filepath.Join(tmpDir, "key.pem"),
+		Org:      "test-org",
+		KeyType:  "dsa",
+	}
+	if err := GenerateCACertificateWithOptions(caOpts); err == nil {
+		t.Fatal("expected an error for an unknown key type")
+	}
+}
@@ -0,0 +1,182 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/leoh0/machine/libmachine/mcnutils"
+)
+
+// intermediateCerts returns the DER certificates of a CA chain which are
+// not self-signed, that is all of them but the root.
+func intermediateCerts(chain [][]byte) ([][]byte, error) {
+	intermediates := [][]byte{}
+	for _, der := range chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+			intermediates = append(intermediates, der)
+		}
+	}
+
+	return intermediates, nil
+}
+
+// importedCAMarker is the file next to the CA certificate at caCertPath
+// telling it was imported, so that it is never replaced by a generated one.
+func importedCAMarker(caCertPath string) string {
+	return caCertPath + ".imported"
+}
+
+// isImportedCA tells if the CA at caCertPath was imported with ImportCA.
+// The intermediate CAs imported before the marker existed are told by
+// their issuer.
+func isImportedCA(caCertPath string) (bool, error) {
+	if _, err := os.Stat(importedCAMarker(caCertPath)); err == nil {
+		return true, nil
+	}
+
+	return isIntermediateCA(caCertPath)
+}
+
+// isIntermediateCA tells if the CA bundle in caFile signs with an
+// intermediate CA rather than a self-signed one.
+func isIntermediateCA(caFile string) (bool, error) {
+	certs, err := readCertificates(caFile)
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Equal(certs[0].RawSubject, certs[0].RawIssuer), nil
+}
+
+// readCertificates parses all the certificates of a PEM bundle, in order.
+func readCertificates(certFile string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("No certificate found in %s", certFile)
+	}
+
+	return certs, nil
+}
+
+// ValidateCA checks that the PEM bundle in caFile can be used with the key
+// in caKeyFile to sign certificates. The bundle starts with the signing CA,
+// a root or an intermediate CA, followed by the CAs which issued it, in
+// order.
+func ValidateCA(caFile, caKeyFile string) error {
+	certs, err := readCertificates(caFile)
+	if err != nil {
+		return err
+	}
+
+	signer := certs[0]
+	if !signer.IsCA {
+		return fmt.Errorf("The certificate %q of %s is not a CA", signer.Subject.CommonName, caFile)
+	}
+	if signer.KeyUsage != 0 && signer.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("The certificate %q of %s cannot sign certificates", signer.Subject.CommonName, caFile)
+	}
+
+	for i, cert := range certs {
+		if time.Now().After(cert.NotAfter) {
+			return fmt.Errorf("The certificate %q of %s expired on %s", cert.Subject.CommonName, caFile, cert.NotAfter.Format("2006-01-02"))
+		}
+		if i+1 < len(certs) {
+			if err := cert.CheckSignatureFrom(certs[i+1]); err != nil {
+				return fmt.Errorf("The certificate %q of %s is not issued by the next one in the bundle: %s", cert.Subject.CommonName, caFile, err)
+			}
+		}
+	}
+
+	if _, err := tls.LoadX509KeyPair(caFile, caKeyFile); err != nil {
+		return fmt.Errorf("The key %s does not match the CA %s: %s", caKeyFile, caFile, err)
+	}
+
+	return nil
+}
+
+// ImportCA validates the CA bundle and key given by the user and copies them
+// to caCertPath and caKeyPath, where the certificates are generated from.
+func ImportCA(bundleFile, keyFile, caCertPath, caKeyPath string) error {
+	if err := ValidateCA(bundleFile, keyFile); err != nil {
+		return err
+	}
+
+	if bundleFile == caCertPath || keyFile == caKeyPath {
+		return errors.New("The CA to import is already in place")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(caCertPath), 0700); err != nil {
+		return err
+	}
+
+	if err := mcnutils.CopyFile(bundleFile, caCertPath); err != nil {
+		return fmt.Errorf("Error copying %s: %s", bundleFile, err)
+	}
+
+	if err := copyPrivateFile(keyFile, caKeyPath); err != nil {
+		return fmt.Errorf("Error copying %s: %s", keyFile, err)
+	}
+
+	return ioutil.WriteFile(importedCAMarker(caCertPath), nil, 0600)
+}
+
+// copyPrivateFile copies src to dst, which is only ever readable by the
+// user, even while it is written.
+func copyPrivateFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	// TempFile creates the file with mode 0600.
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
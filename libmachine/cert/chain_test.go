@@ -0,0 +1,227 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeIntermediateCA generates a root CA in dir and an intermediate CA
+// issued by it, and returns the bundle of the intermediate followed by the
+// root, the key of the intermediate and the root.
+func writeIntermediateCA(t *testing.T, dir string) (string, string, string) {
+	rootCertPath := filepath.Join(dir, "root.pem")
+	rootKeyPath := filepath.Join(dir, "root-key.pem")
+	if err := GenerateCACertificateWithOptions(&Options{CertFile: rootCertPath, KeyFile: rootKeyPath, Org: "root", Bits: 2048}); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := tls.LoadX509KeyPair(rootCertPath, rootKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCert, err := x509.ParseCertificate(root.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	priv, err := generateKey(KeyTypeECDSA, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "intermediate"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, rootCert, priv.Public(), root.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundlePath := filepath.Join(dir, "bundle.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Certificate[0]})...)
+	if err := ioutil.WriteFile(bundlePath, bundle, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath := filepath.Join(dir, "intermediate-key.pem")
+	if err := writeKey(keyPath, priv); err != nil {
+		t.Fatal(err)
+	}
+
+	return bundlePath, keyPath, rootCertPath
+}
+
+func TestGenerateCertFromIntermediateCA(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	bundlePath, keyPath, rootCertPath := writeIntermediateCA(t, tmpDir)
+	if err := ValidateCA(bundlePath, keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(tmpDir, "server.pem")
+	opts := &Options{
+		Hosts:     []string{"127.0.0.1"},
+		CertFile:  certPath,
+		KeyFile:   filepath.Join(tmpDir, "server-key.pem"),
+		CAFile:    bundlePath,
+		CAKeyFile: keyPath,
+		Org:       "test-org",
+		Bits:      2048,
+	}
+	if err := GenerateCert(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := readCertificates(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 {
+		t.Fatalf("expected the server certificate and the intermediate CA, got %d certificates", len(chain))
+	}
+	if chain[1].Subject.CommonName != "intermediate" {
+		t.Fatalf("expected the intermediate CA after the server certificate, got %q", chain[1].Subject.CommonName)
+	}
+
+	roots, err := readCertificates(rootCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootPool := x509.NewCertPool()
+	rootPool.AddCert(roots[0])
+	intermediatePool := x509.NewCertPool()
+	intermediatePool.AddCert(chain[1])
+	if _, err := chain[0].Verify(x509.VerifyOptions{Roots: rootPool, Intermediates: intermediatePool}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateCARejectsUnorderedBundle(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	bundlePath, keyPath, rootCertPath := writeIntermediateCA(t, tmpDir)
+
+	intermediate, err := ioutil.ReadFile(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ioutil.ReadFile(rootCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unordered := filepath.Join(tmpDir, "unordered.pem")
+	if err := ioutil.WriteFile(unordered, append(root, intermediate...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateCA(unordered, keyPath); err == nil {
+		t.Fatal("expected an error for a bundle out of order")
+	}
+}
+
+func TestImportCA(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	bundlePath, keyPath, _ := writeIntermediateCA(t, tmpDir)
+	caCertPath := filepath.Join(tmpDir, "certs", "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "certs", "ca-key.pem")
+
+	if err := ImportCA(bundlePath, keyPath, caCertPath, caKeyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	intermediate, err := isIntermediateCA(caCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !intermediate {
+		t.Fatal("expected the imported CA to be the intermediate CA")
+	}
+
+	fi, err := os.Stat(caKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected the CA key to be only readable by the user, got %s", fi.Mode())
+	}
+
+	if err := ImportCA(keyPath, keyPath, caCertPath, caKeyPath); err == nil {
+		t.Fatal("expected an error when importing a key as a CA")
+	}
+}
+
+func TestImportRootCA(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	rootCertPath := filepath.Join(tmpDir, "root.pem")
+	rootKeyPath := filepath.Join(tmpDir, "root-key.pem")
+	if err := GenerateCACertificateWithOptions(&Options{CertFile: rootCertPath, KeyFile: rootKeyPath, Org: "root", Bits: 2048}); err != nil {
+		t.Fatal(err)
+	}
+
+	if imported, err := isImportedCA(rootCertPath); err != nil || imported {
+		t.Fatalf("expected a generated CA not to be imported, got %v, %v", imported, err)
+	}
+
+	caCertPath := filepath.Join(tmpDir, "certs", "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "certs", "ca-key.pem")
+	if err := os.MkdirAll(filepath.Dir(caKeyPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(caKeyPath, []byte("previous key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ImportCA(rootCertPath, rootKeyPath, caCertPath, caKeyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if imported, err := isImportedCA(caCertPath); err != nil || !imported {
+		t.Fatalf("expected the root CA to be imported, got %v, %v", imported, err)
+	}
+
+	fi, err := os.Stat(caKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected the CA key to be only readable by the user, got %s", fi.Mode())
+	}
+}
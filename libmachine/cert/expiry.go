@@ -0,0 +1,63 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math"
+	"time"
+
+	"github.com/leoh0/machine/libmachine/log"
+)
+
+const (
+	// DefaultValidityDays is how long the generated certificates are valid
+	// unless Options.ValidityDays says otherwise.
+	DefaultValidityDays = 1080
+
+	// ExpiryWarningDays is how long before their expiry the certificates
+	// are reported as expiring.
+	ExpiryWarningDays = 30
+)
+
+// CertificateExpiry returns the date after which the PEM certificate at
+// certPath is no longer valid.
+func CertificateExpiry(certPath string) (time.Time, error) {
+	log.Debugf("Reading certificate data from %s", certPath)
+	certBytes, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	log.Debug("Decoding PEM data...")
+	pemBlock, _ := pem.Decode(certBytes)
+	if pemBlock == nil {
+		return time.Time{}, errors.New("Failed to decode PEM data")
+	}
+
+	log.Debug("Parsing certificate...")
+	cert, err := x509.ParseCertificate(pemBlock.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
+
+// DaysLeft returns the number of whole days until expiry, negative once
+// it is past.
+func DaysLeft(expiry time.Time) int {
+	return int(math.Floor(time.Until(expiry).Hours() / 24))
+}
+
+// ExpiresWithin tells if the certificate at certPath expires in less than
+// the given number of days.
+func ExpiresWithin(certPath string, days int) (bool, error) {
+	expiry, err := CertificateExpiry(certPath)
+	if err != nil {
+		return false, err
+	}
+
+	return DaysLeft(expiry) < days, nil
+}
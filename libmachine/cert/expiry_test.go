@@ -0,0 +1,69 @@
+package cert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertificateExpiry(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	caOpts := &Options{
+		CertFile:     caCertPath,
+		KeyFile:      filepath.Join(tmpDir, "key.pem"),
+		Org:          "test-org",
+		Bits:         2048,
+		ValidityDays: 10,
+	}
+	if err := GenerateCACertificateWithOptions(caOpts); err != nil {
+		t.Fatal(err)
+	}
+
+	expiry, err := CertificateExpiry(caCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if daysLeft := DaysLeft(expiry); daysLeft != 9 {
+		t.Fatalf("expected 9 days left, got %d", daysLeft)
+	}
+
+	expiring, err := ExpiresWithin(caCertPath, ExpiryWarningDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiring {
+		t.Fatal("expected the certificate to expire within the warning period")
+	}
+
+	expiring, err = ExpiresWithin(caCertPath, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expiring {
+		t.Fatal("expected the certificate not to expire within 5 days")
+	}
+}
+
+func TestCertificateExpiryOfMissingFile(t *testing.T) {
+	if _, err := CertificateExpiry(filepath.Join(os.TempDir(), "does-not-exist.pem")); err == nil {
+		t.Fatal("expected an error for a missing certificate")
+	}
+}
+
+func TestDaysLeft(t *testing.T) {
+	if daysLeft := DaysLeft(time.Now().Add(36 * time.Hour)); daysLeft != 1 {
+		t.Fatalf("expected 1 day left, got %d", daysLeft)
+	}
+	if daysLeft := DaysLeft(time.Now().Add(-12 * time.Hour)); daysLeft != -1 {
+		t.Fatalf("expected -1 day left, got %d", daysLeft)
+	}
+}
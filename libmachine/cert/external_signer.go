@@ -0,0 +1,95 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/leoh0/machine/libmachine/log"
+)
+
+// Actions of the external signers.
+const (
+	signerActionSign = "sign"
+	signerActionCA   = "ca"
+)
+
+// signerRequest is written as JSON on the standard input of an external
+// signer. Action is "sign", to issue the certificate of Request, or "ca",
+// to get the certificate of the CA.
+type signerRequest struct {
+	Action  string       `json:"action"`
+	Request *SignRequest `json:"request,omitempty"`
+}
+
+// signerResponse is read as JSON from the standard output of an external
+// signer. Certificate holds PEM certificates, Error is set on failure.
+type signerResponse struct {
+	Certificate string `json:"certificate"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ExternalSigner delegates the signature of the certificates to a helper
+// binary, which keeps the key of the CA out of the machine store. The
+// binary is run for every request, reads one JSON request on its standard
+// input and writes one JSON response on its standard output. What it
+// writes on its standard error is logged.
+type ExternalSigner struct {
+	Path string
+	Args []string
+}
+
+func NewExternalSigner(path string) *ExternalSigner {
+	return &ExternalSigner{
+		Path: path,
+	}
+}
+
+func (s *ExternalSigner) Sign(req *SignRequest) ([]byte, error) {
+	return s.call(&signerRequest{Action: signerActionSign, Request: req})
+}
+
+func (s *ExternalSigner) CACertificate() ([]byte, error) {
+	return s.call(&signerRequest{Action: signerActionCA})
+}
+
+func (s *ExternalSigner) call(req *signerRequest) ([]byte, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(s.Path, s.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	log.Debugf("Running the signer %s for a %q request", s.Path, req.Action)
+	runErr := cmd.Run()
+	if stderr.Len() > 0 {
+		log.Debugf("Signer %s: %s", s.Path, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp signerResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("Error running the signer %s: %s", s.Path, runErr)
+		}
+		return nil, fmt.Errorf("Error reading the response of the signer %s: %s", s.Path, err)
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("Error from the signer %s: %s", s.Path, resp.Error)
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("Error running the signer %s: %s", s.Path, runErr)
+	}
+	if resp.Certificate == "" {
+		return nil, fmt.Errorf("The signer %s returned no certificate", s.Path)
+	}
+
+	return []byte(resp.Certificate), nil
+}
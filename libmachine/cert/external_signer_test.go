@@ -0,0 +1,199 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSignerHelperProcess is the external signer run by the tests below. It
+// signs with the CA given in its environment.
+func TestSignerHelperProcess(t *testing.T) {
+	caFile := os.Getenv("MACHINE_TEST_SIGNER_CA")
+	if caFile == "" {
+		return
+	}
+
+	var req signerRequest
+	var resp signerResponse
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		resp.Error = err.Error()
+	}
+
+	signer := &CASigner{CAFile: caFile, CAKeyFile: os.Getenv("MACHINE_TEST_SIGNER_CA_KEY")}
+
+	var cert []byte
+	var err error
+	switch req.Action {
+	case signerActionSign:
+		cert, err = signer.Sign(req.Request)
+	case signerActionCA:
+		cert, err = signer.CACertificate()
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	resp.Certificate = string(cert)
+
+	json.NewEncoder(os.Stdout).Encode(resp)
+	os.Exit(0)
+}
+
+func newTestExternalSigner(caFile, caKeyFile string) *ExternalSigner {
+	os.Setenv("MACHINE_TEST_SIGNER_CA", caFile)
+	os.Setenv("MACHINE_TEST_SIGNER_CA_KEY", caKeyFile)
+
+	return &ExternalSigner{
+		Path: os.Args[0],
+		Args: []string{"-test.run=TestSignerHelperProcess"},
+	}
+}
+
+func TestExternalSigner(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+	defer os.Unsetenv("MACHINE_TEST_SIGNER_CA")
+	defer os.Unsetenv("MACHINE_TEST_SIGNER_CA_KEY")
+
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "ca-key.pem")
+	if err := GenerateCACertificateWithOptions(&Options{CertFile: caCertPath, KeyFile: caKeyPath, Org: "test-org", Bits: 2048}); err != nil {
+		t.Fatal(err)
+	}
+
+	signer := newTestExternalSigner(caCertPath, caKeyPath)
+
+	caCert, err := signer.CACertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(caCert) != string(expected) {
+		t.Fatal("expected the certificate of the CA")
+	}
+
+	certPath := filepath.Join(tmpDir, "cert.pem")
+	keyPath := filepath.Join(tmpDir, "cert-key.pem")
+	opts := &Options{
+		Hosts:    []string{"127.0.0.1"},
+		CertFile: certPath,
+		KeyFile:  keyPath,
+		Org:      "test-org",
+		Bits:     2048,
+	}
+	if err := GenerateCertWithSigner(opts, signer); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	certs, err := readCertificates(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas, err := readCertificates(caCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cas[0])
+	if _, err := certs[0].Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchCACertReplacesLocalCA(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+	defer os.Unsetenv("MACHINE_TEST_SIGNER_CA")
+	defer os.Unsetenv("MACHINE_TEST_SIGNER_CA_KEY")
+
+	signerCACertPath := filepath.Join(tmpDir, "signer-ca.pem")
+	signerCAKeyPath := filepath.Join(tmpDir, "signer-ca-key.pem")
+	if err := GenerateCACertificateWithOptions(&Options{CertFile: signerCACertPath, KeyFile: signerCAKeyPath, Org: "test-org", Bits: 2048}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The CA generated before the signer was used.
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	if err := GenerateCACertificateWithOptions(&Options{CertFile: caCertPath, KeyFile: filepath.Join(tmpDir, "ca-key.pem"), Org: "test-org", Bits: 2048}); err != nil {
+		t.Fatal(err)
+	}
+
+	signer := newTestExternalSigner(signerCACertPath, signerCAKeyPath)
+
+	changed, err := fetchCACert(caCertPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected the local CA to be replaced by the CA of the signer")
+	}
+
+	caCert, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile(signerCACertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(caCert) != string(expected) {
+		t.Fatal("expected the certificate of the CA of the signer")
+	}
+
+	changed, err = fetchCACert(caCertPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Fatal("expected the CA of the signer to be kept")
+	}
+}
+
+func TestExternalSignerError(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+	defer os.Unsetenv("MACHINE_TEST_SIGNER_CA")
+	defer os.Unsetenv("MACHINE_TEST_SIGNER_CA_KEY")
+
+	signer := newTestExternalSigner(filepath.Join(tmpDir, "missing-ca.pem"), filepath.Join(tmpDir, "missing-ca-key.pem"))
+
+	_, err = signer.Sign(&SignRequest{Usage: UsageClient})
+	if err == nil {
+		t.Fatal("expected an error from the signer")
+	}
+	if !strings.HasPrefix(err.Error(), "Error from the signer") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestExternalSignerNotFound(t *testing.T) {
+	signer := NewExternalSigner(filepath.Join(os.TempDir(), "does-not-exist"))
+
+	if _, err := signer.CACertificate(); err == nil {
+		t.Fatal("expected an error for a missing signer")
+	}
+}
@@ -0,0 +1,136 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	"github.com/leoh0/machine/libmachine/auth"
+)
+
+// Usages of the certificates.
+const (
+	UsageServer = "server"
+	UsageClient = "client"
+)
+
+// SignRequest asks a Signer for a certificate.
+type SignRequest struct {
+	// CSR is the PEM certificate request, holding the public key.
+	CSR string `json:"csr"`
+	// Usage is UsageServer or UsageClient.
+	Usage string `json:"usage"`
+	// Hosts are the IP addresses and DNS names of a server.
+	Hosts        []string `json:"hosts,omitempty"`
+	Org          string   `json:"org"`
+	SwarmMaster  bool     `json:"swarmMaster,omitempty"`
+	ValidityDays int      `json:"validityDays,omitempty"`
+}
+
+// Signer issues the server and client certificates from a certificate
+// authority.
+type Signer interface {
+	// Sign returns the PEM certificate issued for the request, followed by
+	// the intermediate CAs up to the root.
+	Sign(req *SignRequest) ([]byte, error)
+
+	// CACertificate returns the PEM certificates of the CA which the
+	// clients and servers trust.
+	CACertificate() ([]byte, error)
+}
+
+// SignerFor returns the external signer of the auth options, or nil when
+// the certificates are signed in process with the CA key.
+func SignerFor(authOptions *auth.Options) Signer {
+	if authOptions == nil || authOptions.SignerPath == "" {
+		return nil
+	}
+
+	return NewExternalSigner(authOptions.SignerPath)
+}
+
+// CASigner signs the certificates in process with the key of a CA. CAFile
+// can be a bundle starting with an intermediate CA.
+type CASigner struct {
+	CAFile    string
+	CAKeyFile string
+}
+
+func (s *CASigner) Sign(req *SignRequest) ([]byte, error) {
+	block, _ := pem.Decode([]byte(req.CSR))
+	if block == nil {
+		return nil, errors.New("Failed to decode the certificate request")
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+
+	template, err := newCertificate(req.Org, req.ValidityDays)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Usage {
+	case UsageClient:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+	case UsageServer:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		if req.SwarmMaster {
+			// Extend the Swarm master's server certificate
+			// permissions to also be able to connect to downstream
+			// nodes as a client.
+			template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
+		}
+		for _, h := range req.Hosts {
+			if ip := net.ParseIP(h); ip != nil {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			} else {
+				template.DNSNames = append(template.DNSNames, h)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Unknown certificate usage %q", req.Usage)
+	}
+
+	tlsCert, err := tls.LoadX509KeyPair(s.CAFile, s.CAKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	x509Cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, x509Cert, csr.PublicKey, tlsCert.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	intermediates, err := intermediateCerts(tlsCert.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
+	for _, intermediate := range intermediates {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate})...)
+	}
+
+	return chain, nil
+}
+
+func (s *CASigner) CACertificate() ([]byte, error) {
+	return ioutil.ReadFile(s.CAFile)
+}
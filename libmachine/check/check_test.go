@@ -28,6 +28,10 @@ func (fcg FakeCertGenerator) GenerateCert(opts *cert.Options) error {
 	return nil
 }
 
+func (fcg FakeCertGenerator) GenerateCertWithSigner(opts *cert.Options, signer cert.Signer) error {
+	return nil
+}
+
 func (fcg FakeCertGenerator) ValidateCertificate(addr string, authOptions *auth.Options) (bool, error) {
 	return fcg.fakeValidateCertificate.IsValid, fcg.fakeValidateCertificate.Err
 }
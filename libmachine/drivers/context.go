@@ -0,0 +1,20 @@
+package drivers
+
+import "context"
+
+// ContextDriver is implemented by drivers whose calls can be abandoned once
+// a context is done, such as the client side of driver plugins.
+type ContextDriver interface {
+	// WithContext returns a copy of the driver whose calls return the
+	// error of ctx as soon as it is done.
+	WithContext(ctx context.Context) Driver
+}
+
+// WithContext binds d to ctx if it is a ContextDriver. Other drivers are
+// returned as is, and their calls run to completion.
+func WithContext(ctx context.Context, d Driver) Driver {
+	if contextDriver, ok := d.(ContextDriver); ok {
+		return contextDriver.WithContext(ctx)
+	}
+	return d
+}
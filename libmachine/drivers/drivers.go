@@ -72,7 +72,10 @@ type Driver interface {
 	Stop() error
 }
 
-var ErrHostIsNotRunning = errors.New("Host is not running")
+var (
+	ErrHostIsNotRunning = errors.New("Host is not running")
+	ErrHostIsNotStopped = errors.New("Host is not stopped")
+)
 
 type DriverOptions interface {
 	String(key string) string
@@ -107,3 +110,17 @@ func MustBeRunning(d Driver) error {
 
 	return nil
 }
+
+// MustBeStopped will return an error if the machine is not in a stopped state.
+func MustBeStopped(d Driver) error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s != state.Stopped {
+		return ErrHostIsNotStopped
+	}
+
+	return nil
+}
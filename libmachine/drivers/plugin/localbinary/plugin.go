@@ -207,12 +207,19 @@ func (lbp *Plugin) execServer() error {
 	stdOutCh := lbp.AttachStream(outScanner)
 	stdErrCh := lbp.AttachStream(errScanner)
 
+	fields := log.Fields{"machine": lbp.MachineName}
+	if executor, ok := lbp.Executor.(*Executor); ok {
+		fields["driver"] = executor.DriverName
+	}
+	stdOutLog := log.WithFields(fields).WithField("stream", "stdout")
+	stdErrLog := log.WithFields(fields).WithField("stream", "stderr")
+
 	for {
 		select {
 		case out := <-stdOutCh:
-			log.Infof(pluginOut, lbp.MachineName, out)
+			stdOutLog.Infof(pluginOut, lbp.MachineName, out)
 		case err := <-stdErrCh:
-			log.Debugf(pluginErr, lbp.MachineName, err)
+			stdErrLog.Debugf(pluginErr, lbp.MachineName, err)
 		case <-lbp.stopCh:
 			if err := lbp.Executor.Close(); err != nil {
 				return fmt.Errorf("Error closing local plugin binary: %s", err)
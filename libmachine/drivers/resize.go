@@ -0,0 +1,33 @@
+package drivers
+
+import "errors"
+
+var ErrResizeNotSupported = errors.New("Driver does not support resizing")
+
+// ResizeOptions are the new resources of a machine. Zero values leave the
+// matching resource unchanged.
+type ResizeOptions struct {
+	CPU int
+	// Memory is in MB
+	Memory int
+	// DiskSize is in MB
+	DiskSize int
+}
+
+// Resizer is implemented by drivers able to change the resources of their
+// machines after creation.
+type Resizer interface {
+	// Resize changes the resources of the machine. Drivers which can only
+	// resize a stopped machine return ErrHostIsNotStopped otherwise.
+	Resize(opts ResizeOptions) error
+}
+
+// ResizerFor returns the Resizer of a driver, or ErrResizeNotSupported if
+// the driver has no support for resizing.
+func ResizerFor(d Driver) (Resizer, error) {
+	resizer, ok := d.(Resizer)
+	if !ok {
+		return nil, ErrResizeNotSupported
+	}
+	return resizer, nil
+}
@@ -1,8 +1,10 @@
 package rpcdriver
 
 import (
+	"context"
 	"fmt"
 	"net/rpc"
+	"strings"
 	"sync"
 	"time"
 
@@ -41,6 +43,7 @@ type RPCClientDriver struct {
 	plugin          localbinary.DriverPlugin
 	heartbeatDoneCh chan bool
 	Client          *InternalClient
+	ctx             context.Context
 }
 
 type RPCCall struct {
@@ -83,13 +86,33 @@ const (
 	RestartMethod            = `.Restart`
 	KillMethod               = `.Kill`
 	UpgradeMethod            = `.Upgrade`
+	CreateSnapshotMethod     = `.CreateSnapshot`
+	ListSnapshotsMethod      = `.ListSnapshots`
+	RestoreSnapshotMethod    = `.RestoreSnapshot`
+	DeleteSnapshotMethod     = `.DeleteSnapshot`
+	ResizeMethod             = `.Resize`
 )
 
 func (ic *InternalClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	return ic.CallContext(context.Background(), serviceMethod, args, reply)
+}
+
+// CallContext is Call which stops waiting for the reply once ctx is done.
+// The plugin server still runs the call to completion, unless it is closed.
+func (ic *InternalClient) CallContext(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
 	if serviceMethod != HeartbeatMethod {
 		log.Debugf("(%s) Calling %+v", ic.MachineName, serviceMethod)
 	}
-	return ic.RPCClient.Call(ic.rpcServiceName+serviceMethod, args, reply)
+
+	call := ic.RPCClient.Go(ic.rpcServiceName+serviceMethod, args, reply, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		log.Debugf("(%s) Abandoning call to %+v: %s", ic.MachineName, serviceMethod, ctx.Err())
+		return ctx.Err()
+	}
 }
 
 func (ic *InternalClient) switchToV0() {
@@ -223,12 +246,28 @@ func (c *RPCClientDriver) close() error {
 	return c.plugin.Close()
 }
 
+// WithContext returns a copy of the driver whose calls are abandoned once
+// ctx is done.
+func (c *RPCClientDriver) WithContext(ctx context.Context) drivers.Driver {
+	withContext := *c
+	withContext.ctx = ctx
+	return &withContext
+}
+
+func (c *RPCClientDriver) call(serviceMethod string, args interface{}, reply interface{}) error {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return c.Client.CallContext(ctx, serviceMethod, args, reply)
+}
+
 // Helper method to make requests which take no arguments and return simply a
 // string, e.g. "GetIP".
 func (c *RPCClientDriver) rpcStringCall(method string) (string, error) {
 	var info string
 
-	if err := c.Client.Call(method, struct{}{}, &info); err != nil {
+	if err := c.call(method, struct{}{}, &info); err != nil {
 		return "", err
 	}
 
@@ -238,7 +277,7 @@ func (c *RPCClientDriver) rpcStringCall(method string) (string, error) {
 func (c *RPCClientDriver) GetCreateFlags() []mcnflag.Flag {
 	var flags []mcnflag.Flag
 
-	if err := c.Client.Call(GetCreateFlagsMethod, struct{}{}, &flags); err != nil {
+	if err := c.call(GetCreateFlagsMethod, struct{}{}, &flags); err != nil {
 		log.Warnf("Error attempting call to get create flags: %s", err)
 	}
 
@@ -246,13 +285,13 @@ func (c *RPCClientDriver) GetCreateFlags() []mcnflag.Flag {
 }
 
 func (c *RPCClientDriver) SetConfigRaw(data []byte) error {
-	return c.Client.Call(SetConfigRawMethod, data, nil)
+	return c.call(SetConfigRawMethod, data, nil)
 }
 
 func (c *RPCClientDriver) GetConfigRaw() ([]byte, error) {
 	var data []byte
 
-	if err := c.Client.Call(GetConfigRawMethod, struct{}{}, &data); err != nil {
+	if err := c.call(GetConfigRawMethod, struct{}{}, &data); err != nil {
 		return nil, err
 	}
 
@@ -270,7 +309,7 @@ func (c *RPCClientDriver) DriverName() string {
 }
 
 func (c *RPCClientDriver) SetConfigFromFlags(flags drivers.DriverOptions) error {
-	return c.Client.Call(SetConfigFromFlagsMethod, &flags, nil)
+	return c.call(SetConfigFromFlagsMethod, &flags, nil)
 }
 
 func (c *RPCClientDriver) GetURL() (string, error) {
@@ -308,7 +347,7 @@ func (c *RPCClientDriver) GetSSHKeyPath() string {
 func (c *RPCClientDriver) GetSSHPort() (int, error) {
 	var port int
 
-	if err := c.Client.Call(GetSSHPortMethod, struct{}{}, &port); err != nil {
+	if err := c.call(GetSSHPortMethod, struct{}{}, &port); err != nil {
 		return 0, err
 	}
 
@@ -327,7 +366,7 @@ func (c *RPCClientDriver) GetSSHUsername() string {
 func (c *RPCClientDriver) GetState() (state.State, error) {
 	var s state.State
 
-	if err := c.Client.Call(GetStateMethod, struct{}{}, &s); err != nil {
+	if err := c.call(GetStateMethod, struct{}{}, &s); err != nil {
 		return state.Error, err
 	}
 
@@ -335,33 +374,77 @@ func (c *RPCClientDriver) GetState() (state.State, error) {
 }
 
 func (c *RPCClientDriver) PreCreateCheck() error {
-	return c.Client.Call(PreCreateCheckMethod, struct{}{}, nil)
+	return c.call(PreCreateCheckMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Create() error {
-	return c.Client.Call(CreateMethod, struct{}{}, nil)
+	return c.call(CreateMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Remove() error {
-	return c.Client.Call(RemoveMethod, struct{}{}, nil)
+	return c.call(RemoveMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Start() error {
-	return c.Client.Call(StartMethod, struct{}{}, nil)
+	return c.call(StartMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Stop() error {
-	return c.Client.Call(StopMethod, struct{}{}, nil)
+	return c.call(StopMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Restart() error {
-	return c.Client.Call(RestartMethod, struct{}{}, nil)
+	return c.call(RestartMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Kill() error {
-	return c.Client.Call(KillMethod, struct{}{}, nil)
+	return c.call(KillMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Upgrade() error {
-	return c.Client.Call(UpgradeMethod, struct{}{}, nil)
+	return c.call(UpgradeMethod, struct{}{}, nil)
+}
+
+// optionalMethodErr turns the error of a call to a method of an optional
+// driver interface back into notSupported when the plugin does not
+// implement the interface, including plugins built before it existed.
+func optionalMethodErr(err, notSupported error) error {
+	if err == nil {
+		return nil
+	}
+	if err.Error() == notSupported.Error() || strings.HasPrefix(err.Error(), "rpc: can't find method") {
+		return notSupported
+	}
+	return err
+}
+
+func (c *RPCClientDriver) CreateSnapshot(name, description string) error {
+	args := &SnapshotArgs{
+		Name:        name,
+		Description: description,
+	}
+
+	return optionalMethodErr(c.call(CreateSnapshotMethod, args, nil), drivers.ErrSnapshotsNotSupported)
+}
+
+func (c *RPCClientDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	var snapshots []drivers.Snapshot
+
+	if err := c.call(ListSnapshotsMethod, struct{}{}, &snapshots); err != nil {
+		return nil, optionalMethodErr(err, drivers.ErrSnapshotsNotSupported)
+	}
+
+	return snapshots, nil
+}
+
+func (c *RPCClientDriver) RestoreSnapshot(name string) error {
+	return optionalMethodErr(c.call(RestoreSnapshotMethod, name, nil), drivers.ErrSnapshotsNotSupported)
+}
+
+func (c *RPCClientDriver) DeleteSnapshot(name string) error {
+	return optionalMethodErr(c.call(DeleteSnapshotMethod, name, nil), drivers.ErrSnapshotsNotSupported)
+}
+
+func (c *RPCClientDriver) Resize(opts drivers.ResizeOptions) error {
+	return optionalMethodErr(c.call(ResizeMethod, &opts, nil), drivers.ErrResizeNotSupported)
 }
@@ -0,0 +1,72 @@
+package rpcdriver
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type blockingDriver struct {
+	*fakedriver.Driver
+	unblock chan struct{}
+}
+
+func (d *blockingDriver) Start() error {
+	<-d.unblock
+	return nil
+}
+
+func newTestRPCClientDriver(t *testing.T, d drivers.Driver) *RPCClientDriver {
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName(RPCServiceNameV1, NewRPCServerDriver(d)))
+
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	return &RPCClientDriver{
+		Client: NewInternalClient(rpc.NewClient(clientConn)),
+	}
+}
+
+func TestRPCClientDriverCalls(t *testing.T) {
+	c := newTestRPCClientDriver(t, &fakedriver.Driver{MockState: state.Running})
+
+	s, err := c.GetState()
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+}
+
+func TestRPCClientDriverWithContextCancelled(t *testing.T) {
+	d := &blockingDriver{
+		Driver:  &fakedriver.Driver{},
+		unblock: make(chan struct{}),
+	}
+	defer close(d.unblock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := drivers.WithContext(ctx, newTestRPCClientDriver(t, d))
+
+	errCh := make(chan error)
+	go func() { errCh <- c.Start() }()
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-errCh)
+}
+
+func TestWithContextKeepsSerialDriver(t *testing.T) {
+	c := newTestRPCClientDriver(t, &fakedriver.Driver{})
+
+	d := drivers.WithContext(context.Background(), drivers.NewSerialDriver(c))
+
+	serialDriver, ok := d.(*drivers.SerialDriver)
+	assert.True(t, ok)
+	assert.IsType(t, &RPCClientDriver{}, serialDriver.Driver)
+	assert.NotSame(t, c, serialDriver.Driver)
+}
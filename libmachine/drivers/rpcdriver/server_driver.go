@@ -80,6 +80,12 @@ func (r RPCFlags) Bool(key string) bool {
 	return val
 }
 
+// SnapshotArgs are the arguments of the CreateSnapshot call.
+type SnapshotArgs struct {
+	Name        string
+	Description string
+}
+
 type RPCServerDriver struct {
 	ActualDriver drivers.Driver
 	CloseCh      chan bool
@@ -225,3 +231,45 @@ func (r *RPCServerDriver) Heartbeat(_ *struct{}, _ *struct{}) error {
 	r.HeartbeatCh <- true
 	return nil
 }
+
+func (r *RPCServerDriver) CreateSnapshot(args *SnapshotArgs, _ *struct{}) error {
+	snapshotter, err := drivers.SnapshotterFor(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	return snapshotter.CreateSnapshot(args.Name, args.Description)
+}
+
+func (r *RPCServerDriver) ListSnapshots(_ *struct{}, reply *[]drivers.Snapshot) error {
+	snapshotter, err := drivers.SnapshotterFor(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	snapshots, err := snapshotter.ListSnapshots()
+	*reply = snapshots
+	return err
+}
+
+func (r *RPCServerDriver) RestoreSnapshot(name *string, _ *struct{}) error {
+	snapshotter, err := drivers.SnapshotterFor(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	return snapshotter.RestoreSnapshot(*name)
+}
+
+func (r *RPCServerDriver) DeleteSnapshot(name *string, _ *struct{}) error {
+	snapshotter, err := drivers.SnapshotterFor(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	return snapshotter.DeleteSnapshot(*name)
+}
+
+func (r *RPCServerDriver) Resize(opts *drivers.ResizeOptions, _ *struct{}) error {
+	resizer, err := drivers.ResizerFor(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	return resizer.Resize(*opts)
+}
@@ -2,9 +2,11 @@ package rpcdriver
 
 import (
 	"errors"
+	"net/rpc"
 	"testing"
 
 	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/drivers"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -73,3 +75,32 @@ func TestRPCServerDriverCreate(t *testing.T) {
 		assert.Equal(t, tc.expectedErr, tc.serverDriver.Create(nil, nil))
 	}
 }
+
+func TestRPCServerDriverSnapshotsNotSupported(t *testing.T) {
+	serverDriver := &RPCServerDriver{
+		ActualDriver: &fakedriver.Driver{},
+	}
+
+	var snapshots []drivers.Snapshot
+	name := "snap"
+
+	assert.Equal(t, drivers.ErrSnapshotsNotSupported, serverDriver.CreateSnapshot(&SnapshotArgs{Name: name}, nil))
+	assert.Equal(t, drivers.ErrSnapshotsNotSupported, serverDriver.ListSnapshots(nil, &snapshots))
+	assert.Equal(t, drivers.ErrSnapshotsNotSupported, serverDriver.RestoreSnapshot(&name, nil))
+	assert.Equal(t, drivers.ErrSnapshotsNotSupported, serverDriver.DeleteSnapshot(&name, nil))
+}
+
+func TestRPCServerDriverResizeNotSupported(t *testing.T) {
+	serverDriver := &RPCServerDriver{
+		ActualDriver: &fakedriver.Driver{},
+	}
+
+	assert.Equal(t, drivers.ErrResizeNotSupported, serverDriver.Resize(&drivers.ResizeOptions{CPU: 2}, nil))
+}
+
+func TestOptionalMethodErr(t *testing.T) {
+	assert.NoError(t, optionalMethodErr(nil, drivers.ErrSnapshotsNotSupported))
+	assert.Equal(t, drivers.ErrSnapshotsNotSupported, optionalMethodErr(rpc.ServerError(drivers.ErrSnapshotsNotSupported.Error()), drivers.ErrSnapshotsNotSupported))
+	assert.Equal(t, drivers.ErrResizeNotSupported, optionalMethodErr(rpc.ServerError("rpc: can't find method RPCServerDriver.Resize"), drivers.ErrResizeNotSupported))
+	assert.Equal(t, rpc.ServerError("Snapshot not found"), optionalMethodErr(rpc.ServerError("Snapshot not found"), drivers.ErrSnapshotsNotSupported))
+}
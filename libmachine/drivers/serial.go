@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"sync"
 
 	"encoding/json"
@@ -167,6 +168,69 @@ func (d *SerialDriver) Stop() error {
 	return d.Driver.Stop()
 }
 
+// CreateSnapshot saves the current state of the machine under the given name
+func (d *SerialDriver) CreateSnapshot(name, description string) error {
+	snapshotter, err := SnapshotterFor(d.Driver)
+	if err != nil {
+		return err
+	}
+	d.Lock()
+	defer d.Unlock()
+	return snapshotter.CreateSnapshot(name, description)
+}
+
+// ListSnapshots returns the snapshots of the machine
+func (d *SerialDriver) ListSnapshots() ([]Snapshot, error) {
+	snapshotter, err := SnapshotterFor(d.Driver)
+	if err != nil {
+		return nil, err
+	}
+	d.Lock()
+	defer d.Unlock()
+	return snapshotter.ListSnapshots()
+}
+
+// RestoreSnapshot reverts the machine to the given snapshot
+func (d *SerialDriver) RestoreSnapshot(name string) error {
+	snapshotter, err := SnapshotterFor(d.Driver)
+	if err != nil {
+		return err
+	}
+	d.Lock()
+	defer d.Unlock()
+	return snapshotter.RestoreSnapshot(name)
+}
+
+// DeleteSnapshot removes the given snapshot
+func (d *SerialDriver) DeleteSnapshot(name string) error {
+	snapshotter, err := SnapshotterFor(d.Driver)
+	if err != nil {
+		return err
+	}
+	d.Lock()
+	defer d.Unlock()
+	return snapshotter.DeleteSnapshot(name)
+}
+
+// Resize changes the resources of the machine
+func (d *SerialDriver) Resize(opts ResizeOptions) error {
+	resizer, err := ResizerFor(d.Driver)
+	if err != nil {
+		return err
+	}
+	d.Lock()
+	defer d.Unlock()
+	return resizer.Resize(opts)
+}
+
 func (d *SerialDriver) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.Driver)
 }
+
+// WithContext binds the inner driver to ctx, keeping the same lock.
+func (d *SerialDriver) WithContext(ctx context.Context) Driver {
+	return &SerialDriver{
+		Driver: WithContext(ctx, d.Driver),
+		Locker: d.Locker,
+	}
+}
@@ -294,3 +294,82 @@ func TestSerialDriverStop(t *testing.T) {
 
 	assert.Equal(t, []string{"Lock", "Stop", "Unlock"}, callRecorder.calls)
 }
+
+type MockSnapshotDriver struct {
+	MockDriver
+}
+
+func (d *MockSnapshotDriver) CreateSnapshot(name, description string) error {
+	d.calls.record("CreateSnapshot")
+	return nil
+}
+
+func (d *MockSnapshotDriver) ListSnapshots() ([]Snapshot, error) {
+	d.calls.record("ListSnapshots")
+	return []Snapshot{{Name: "snap"}}, nil
+}
+
+func (d *MockSnapshotDriver) RestoreSnapshot(name string) error {
+	d.calls.record("RestoreSnapshot")
+	return nil
+}
+
+func (d *MockSnapshotDriver) DeleteSnapshot(name string) error {
+	d.calls.record("DeleteSnapshot")
+	return nil
+}
+
+func TestSerialDriverSnapshots(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockSnapshotDriver{MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+	snapshotter, err := SnapshotterFor(driver)
+	assert.NoError(t, err)
+
+	snapshotter.CreateSnapshot("snap", "")
+	snapshots, err := snapshotter.ListSnapshots()
+	snapshotter.RestoreSnapshot("snap")
+	snapshotter.DeleteSnapshot("snap")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Snapshot{{Name: "snap"}}, snapshots)
+	assert.Equal(t, []string{
+		"Lock", "CreateSnapshot", "Unlock",
+		"Lock", "ListSnapshots", "Unlock",
+		"Lock", "RestoreSnapshot", "Unlock",
+		"Lock", "DeleteSnapshot", "Unlock",
+	}, callRecorder.calls)
+}
+
+func TestSerialDriverSnapshotsNotSupported(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockDriver{calls: callRecorder}, &MockLocker{calls: callRecorder})
+	err := driver.(Snapshotter).CreateSnapshot("snap", "")
+
+	assert.Equal(t, ErrSnapshotsNotSupported, err)
+	assert.Empty(t, callRecorder.calls)
+}
+
+type MockResizeDriver struct {
+	MockDriver
+	opts ResizeOptions
+}
+
+func (d *MockResizeDriver) Resize(opts ResizeOptions) error {
+	d.calls.record("Resize")
+	d.opts = opts
+	return nil
+}
+
+func TestSerialDriverResize(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	mockDriver := &MockResizeDriver{MockDriver: MockDriver{calls: callRecorder}}
+	driver := newSerialDriverWithLock(mockDriver, &MockLocker{calls: callRecorder})
+	err := driver.(Resizer).Resize(ResizeOptions{CPU: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, ResizeOptions{CPU: 2}, mockDriver.opts)
+	assert.Equal(t, []string{"Lock", "Resize", "Unlock"}, callRecorder.calls)
+}
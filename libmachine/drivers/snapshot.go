@@ -0,0 +1,42 @@
+package drivers
+
+import "errors"
+
+var ErrSnapshotsNotSupported = errors.New("Driver does not support snapshots")
+
+// Snapshot describes a saved state of a machine.
+type Snapshot struct {
+	ID          string
+	Name        string
+	Description string
+	// Current is true for the snapshot the machine was last restored from
+	// or saved to.
+	Current bool
+}
+
+// Snapshotter is implemented by drivers able to save and restore the state
+// of their machines.
+type Snapshotter interface {
+	// CreateSnapshot saves the current state of the machine under the given
+	// name
+	CreateSnapshot(name, description string) error
+
+	// ListSnapshots returns the snapshots of the machine
+	ListSnapshots() ([]Snapshot, error)
+
+	// RestoreSnapshot reverts the machine to the given snapshot
+	RestoreSnapshot(name string) error
+
+	// DeleteSnapshot removes the given snapshot
+	DeleteSnapshot(name string) error
+}
+
+// SnapshotterFor returns the Snapshotter of a driver, or
+// ErrSnapshotsNotSupported if the driver has no support for snapshots.
+func SnapshotterFor(d Driver) (Snapshotter, error) {
+	snapshotter, ok := d.(Snapshotter)
+	if !ok {
+		return nil, ErrSnapshotsNotSupported
+	}
+	return snapshotter, nil
+}
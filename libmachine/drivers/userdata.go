@@ -0,0 +1,104 @@
+package drivers
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// CloudInitFile is the cloud-init user-data generated in the directory of a
+// machine provisioned with cloud-init.
+const CloudInitFile = "cloud-init.yml"
+
+// userDataTypes are the MIME types of the cloud-init user-data formats, by
+// the first characters of the content.
+var userDataTypes = []struct {
+	prefix      string
+	contentType string
+}{
+	{"#!", "text/x-shellscript"},
+	{"#cloud-config", "text/cloud-config"},
+	{"#cloud-boothook", "text/cloud-boothook"},
+	{"#include", "text/x-include-url"},
+	{"#part-handler", "text/part-handler"},
+	{"#upstart-job", "text/upstart-job"},
+}
+
+// ReadUserData returns the user-data to create an instance with, from the
+// file given by the user and the one generated at generatedFile, which are
+// both optional. When there are both, they are combined in a multipart MIME
+// document, which cloud-init processes part by part.
+func ReadUserData(userDataFile, generatedFile string) ([]byte, error) {
+	parts := [][]byte{}
+
+	if userDataFile != "" {
+		content, err := ioutil.ReadFile(userDataFile)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, content)
+	}
+
+	generated, err := ioutil.ReadFile(generatedFile)
+	if err == nil {
+		parts = append(parts, generated)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	switch len(parts) {
+	case 0:
+		return nil, nil
+	case 1:
+		return parts[0], nil
+	}
+
+	return multipartUserData(parts)
+}
+
+func multipartUserData(parts [][]byte) ([]byte, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	for _, content := range parts {
+		contentType := userDataType(content)
+		if contentType == "" {
+			return nil, errors.New("Error combining user-data: only cloud-config, scripts, boothooks, includes, part handlers and upstart jobs can be combined")
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", contentType+`; charset="utf-8"`)
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	var userData bytes.Buffer
+	userData.WriteString("MIME-Version: 1.0\r\n")
+	userData.WriteString(`Content-Type: multipart/mixed; boundary="` + w.Boundary() + "\"\r\n\r\n")
+	userData.Write(body.Bytes())
+
+	return userData.Bytes(), nil
+}
+
+func userDataType(content []byte) string {
+	for _, t := range userDataTypes {
+		if strings.HasPrefix(string(content), t.prefix) {
+			return t.contentType
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,96 @@
+package drivers
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeUserData(t *testing.T, dir, name, content string) string {
+	p := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(p, []byte(content), 0600))
+	return p
+}
+
+func TestReadUserData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "userdata")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	missing := filepath.Join(dir, "missing.yml")
+	user := writeUserData(t, dir, "user.sh", "#!/bin/sh\necho hello\n")
+	generated := writeUserData(t, dir, CloudInitFile, "#cloud-config\nhostname: foo\n")
+
+	userData, err := ReadUserData("", missing)
+	assert.NoError(t, err)
+	assert.Empty(t, userData)
+
+	userData, err = ReadUserData(user, missing)
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\necho hello\n", string(userData))
+
+	userData, err = ReadUserData("", generated)
+	assert.NoError(t, err)
+	assert.Equal(t, "#cloud-config\nhostname: foo\n", string(userData))
+
+	_, err = ReadUserData(missing, generated)
+	assert.Error(t, err)
+}
+
+func TestReadUserDataCombinesParts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "userdata")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	user := writeUserData(t, dir, "user.sh", "#!/bin/sh\necho hello\n")
+	generated := writeUserData(t, dir, CloudInitFile, "#cloud-config\nhostname: foo\n")
+
+	userData, err := ReadUserData(user, generated)
+	assert.NoError(t, err)
+
+	header, body := splitHeader(string(userData))
+	assert.Contains(t, header, "MIME-Version: 1.0")
+	mediaType, params, err := mime.ParseMediaType(strings.TrimPrefix(strings.Split(header, "\r\n")[1], "Content-Type: "))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	r := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	expected := []struct {
+		contentType string
+		content     string
+	}{
+		{`text/x-shellscript; charset="utf-8"`, "#!/bin/sh\necho hello\n"},
+		{`text/cloud-config; charset="utf-8"`, "#cloud-config\nhostname: foo\n"},
+	}
+	for _, e := range expected {
+		part, err := r.NextPart()
+		assert.NoError(t, err)
+		assert.Equal(t, e.contentType, part.Header.Get("Content-Type"))
+		content, err := ioutil.ReadAll(part)
+		assert.NoError(t, err)
+		assert.Equal(t, e.content, string(content))
+	}
+}
+
+func TestReadUserDataRejectsUnknownFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "userdata")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	user := writeUserData(t, dir, "user.txt", "hello")
+	generated := writeUserData(t, dir, CloudInitFile, "#cloud-config\nhostname: foo\n")
+
+	_, err = ReadUserData(user, generated)
+	assert.Error(t, err)
+}
+
+func splitHeader(userData string) (string, string) {
+	parts := strings.SplitN(userData, "\r\n\r\n", 2)
+	return parts[0], parts[1]
+}
@@ -1,7 +1,10 @@
 package drivers
 
 import (
+	"bytes"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/leoh0/machine/libmachine/log"
 	"github.com/leoh0/machine/libmachine/mcnutils"
@@ -24,7 +27,8 @@ func GetSSHClientFromDriver(d Driver) (ssh.Client, error) {
 		auth = &ssh.Auth{}
 	} else {
 		auth = &ssh.Auth{
-			Keys: []string{d.GetSSHKeyPath()},
+			Keys:       []string{d.GetSSHKeyPath()},
+			KnownHosts: GetSSHKnownHostsFromDriver(d),
 		}
 	}
 
@@ -33,6 +37,25 @@ func GetSSHClientFromDriver(d Driver) (ssh.Client, error) {
 
 }
 
+// SSHKeyHolder is the part of a Driver which tells the SSH key of a machine.
+type SSHKeyHolder interface {
+	GetMachineName() string
+	GetSSHKeyPath() string
+}
+
+// GetSSHKnownHostsFromDriver returns where the SSH host key of the machine
+// is pinned, next to its SSH key in the store directory of the machine. It
+// is nil for machines without their own SSH key, whose host keys are not
+// checked.
+func GetSSHKnownHostsFromDriver(d SSHKeyHolder) *ssh.KnownHosts {
+	keyPath := d.GetSSHKeyPath()
+	if keyPath == "" {
+		return nil
+	}
+
+	return ssh.NewKnownHosts(filepath.Dir(keyPath), d.GetMachineName())
+}
+
 func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
 	client, err := GetSSHClientFromDriver(d)
 	if err != nil {
@@ -53,6 +76,23 @@ output  : %s`, command, err, output)
 	return output, nil
 }
 
+// UploadFileFromDriver copies content to the file dst of the machine over
+// scp, as the SSH user. A relative dst is in the home directory of the user.
+func UploadFileFromDriver(d Driver, content []byte, dst string, mode os.FileMode) error {
+	client, err := GetSSHClientFromDriver(d)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("About to upload %d bytes to %s", len(content), dst)
+
+	if err := client.Upload(bytes.NewReader(content), int64(len(content)), dst, mode); err != nil {
+		return fmt.Errorf("Error uploading %s: %s", dst, err)
+	}
+
+	return nil
+}
+
 func sshAvailableFunc(d Driver) func() bool {
 	return func() bool {
 		log.Debug("Getting to WaitForSSH function...")
@@ -1,5 +1,12 @@
 package engine
 
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
 const (
 	DefaultPort = 2376
 )
@@ -18,4 +25,60 @@ type Options struct {
 	TLSVerify        bool `json:"TlsVerify"`
 	RegistryMirror   []string
 	InstallURL       string
+	// DaemonJSON writes the engine settings to the daemon.json of the
+	// machine, merged with the existing ones, instead of passing them as
+	// flags to the daemon.
+	DaemonJSON          bool
+	LogDriver           string
+	LogOpts             []string
+	CgroupDriver        string
+	DefaultAddressPools []AddressPool
+}
+
+// AddressPool is a range the daemon allocates the subnets of its networks
+// from.
+type AddressPool struct {
+	Base string
+	Size int
+}
+
+func (p AddressPool) String() string {
+	return fmt.Sprintf("base=%s,size=%d", p.Base, p.Size)
+}
+
+// ParseAddressPool parses base=<cidr>,size=<prefix length>, like the
+// --default-address-pool flag of the daemon.
+func ParseAddressPool(s string) (AddressPool, error) {
+	pool := AddressPool{}
+	for _, field := range strings.Split(s, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return pool, fmt.Errorf("Invalid address pool %q, expected base=<cidr>,size=<prefix length>", s)
+		}
+
+		switch kv[0] {
+		case "base":
+			pool.Base = kv[1]
+		case "size":
+			size, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return pool, fmt.Errorf("Invalid size in the address pool %q: %s", s, err)
+			}
+			pool.Size = size
+		default:
+			return pool, fmt.Errorf("Invalid address pool %q, unknown field %q", s, kv[0])
+		}
+	}
+
+	_, base, err := net.ParseCIDR(pool.Base)
+	if err != nil {
+		return pool, fmt.Errorf("Invalid base in the address pool %q: %s", s, err)
+	}
+
+	ones, bits := base.Mask.Size()
+	if pool.Size < ones || pool.Size > bits {
+		return pool, fmt.Errorf("Invalid size in the address pool %q, expected between %d and %d", s, ones, bits)
+	}
+
+	return pool, nil
 }
@@ -0,0 +1,26 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddressPool(t *testing.T) {
+	pool, err := ParseAddressPool("base=10.10.0.0/16,size=24")
+	assert.NoError(t, err)
+	assert.Equal(t, AddressPool{Base: "10.10.0.0/16", Size: 24}, pool)
+	assert.Equal(t, "base=10.10.0.0/16,size=24", pool.String())
+
+	for _, invalid := range []string{
+		"10.10.0.0/16",
+		"base=10.10.0.0/16,size=abc",
+		"base=10.10.0.0,size=24",
+		"base=10.10.0.0/16,size=8",
+		"base=10.10.0.0/16,size=33",
+		"base=10.10.0.0/16,size=24,gateway=10.10.0.1",
+	} {
+		_, err := ParseAddressPool(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -1,6 +1,7 @@
 package host
 
 import (
+	"context"
 	"regexp"
 
 	"github.com/leoh0/machine/libmachine/auth"
@@ -53,6 +54,13 @@ type Options struct {
 	EngineOptions *engine.Options
 	SwarmOptions  *swarm.Options
 	AuthOptions   *auth.Options
+	// CloudInit provisions the machine with a cloud-init user-data passed
+	// to the driver at creation, instead of over SSH.
+	CloudInit bool
+	// PreProvisionScript and PostProvisionScript are the paths of local
+	// scripts run on the machine each time it is provisioned.
+	PreProvisionScript  string
+	PostProvisionScript string
 }
 
 type Metadata struct {
@@ -84,7 +92,9 @@ func (creator *StandardSSHClientCreator) CreateSSHClient(d drivers.Driver) (ssh.
 		return &ssh.ExternalClient{}, err
 	}
 
-	auth := &ssh.Auth{}
+	auth := &ssh.Auth{
+		KnownHosts: drivers.GetSSHKnownHostsFromDriver(d),
+	}
 	if d.GetSSHKeyPath() != "" {
 		auth.Keys = []string{d.GetSSHKeyPath()}
 	}
@@ -92,44 +102,63 @@ func (creator *StandardSSHClientCreator) CreateSSHClient(d drivers.Driver) (ssh.
 	return ssh.NewClient(d.GetSSHUsername(), addr, port, auth)
 }
 
-func (h *Host) runActionForState(action func() error, desiredState state.State) error {
-	if drivers.MachineInState(h.Driver, desiredState)() {
+func (h *Host) runActionForState(ctx context.Context, action func(d drivers.Driver) error, desiredState state.State) error {
+	d := drivers.WithContext(ctx, h.Driver)
+
+	if drivers.MachineInState(d, desiredState)() {
 		return mcnerror.ErrHostAlreadyInState{
 			Name:  h.Name,
 			State: desiredState,
 		}
 	}
 
-	if err := action(); err != nil {
+	if err := action(d); err != nil {
 		return err
 	}
 
-	return mcnutils.WaitFor(drivers.MachineInState(h.Driver, desiredState))
+	return mcnutils.WaitForContext(ctx, drivers.MachineInState(d, desiredState))
 }
 
 func (h *Host) WaitForDocker() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	return h.WaitForDockerContext(context.Background())
+}
+
+// WaitForDockerContext is WaitForDocker which gives up as soon as the
+// context is done.
+func (h *Host) WaitForDockerContext(ctx context.Context) error {
+	provisioner, err := provision.DetectProvisioner(drivers.WithContext(ctx, h.Driver))
 	if err != nil {
 		return err
 	}
 
-	return provision.WaitForDocker(provisioner, engine.DefaultPort)
+	return provision.WaitForDockerContext(ctx, provisioner, engine.DefaultPort)
 }
 
 func (h *Host) Start() error {
+	return h.StartContext(context.Background())
+}
+
+// StartContext starts the machine and waits for Docker to be up, unless
+// the context is done first.
+func (h *Host) StartContext(ctx context.Context) error {
 	log.Infof("Starting %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Start, state.Running); err != nil {
+	if err := h.runActionForState(ctx, drivers.Driver.Start, state.Running); err != nil {
 		return err
 	}
 
 	log.Infof("Machine %q was started.", h.Name)
 
-	return h.WaitForDocker()
+	return h.WaitForDockerContext(ctx)
 }
 
 func (h *Host) Stop() error {
+	return h.StopContext(context.Background())
+}
+
+// StopContext stops the machine, unless the context is done first.
+func (h *Host) StopContext(ctx context.Context) error {
 	log.Infof("Stopping %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Stop, state.Stopped); err != nil {
+	if err := h.runActionForState(ctx, drivers.Driver.Stop, state.Stopped); err != nil {
 		return err
 	}
 
@@ -138,8 +167,13 @@ func (h *Host) Stop() error {
 }
 
 func (h *Host) Kill() error {
+	return h.KillContext(context.Background())
+}
+
+// KillContext kills the machine, unless the context is done first.
+func (h *Host) KillContext(ctx context.Context) error {
 	log.Infof("Killing %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Kill, state.Stopped); err != nil {
+	if err := h.runActionForState(ctx, drivers.Driver.Kill, state.Stopped); err != nil {
 		return err
 	}
 
@@ -148,21 +182,28 @@ func (h *Host) Kill() error {
 }
 
 func (h *Host) Restart() error {
+	return h.RestartContext(context.Background())
+}
+
+// RestartContext restarts the machine and waits for Docker to be up,
+// unless the context is done first.
+func (h *Host) RestartContext(ctx context.Context) error {
 	log.Infof("Restarting %q...", h.Name)
-	if drivers.MachineInState(h.Driver, state.Stopped)() {
-		if err := h.Start(); err != nil {
+	d := drivers.WithContext(ctx, h.Driver)
+	if drivers.MachineInState(d, state.Stopped)() {
+		if err := h.StartContext(ctx); err != nil {
 			return err
 		}
-	} else if drivers.MachineInState(h.Driver, state.Running)() {
-		if err := h.Driver.Restart(); err != nil {
+	} else if drivers.MachineInState(d, state.Running)() {
+		if err := d.Restart(); err != nil {
 			return err
 		}
-		if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
+		if err := mcnutils.WaitForContext(ctx, drivers.MachineInState(d, state.Running)); err != nil {
 			return err
 		}
 	}
 
-	return h.WaitForDocker()
+	return h.WaitForDockerContext(ctx)
 }
 
 func (h *Host) DockerVersion() (string, error) {
@@ -265,7 +306,46 @@ func (h *Host) ConfigureAuth() error {
 	// and modularity of the provisioners should be).
 	//
 	// Call provision to re-provision the certs properly.
-	return provisioner.Provision(swarm.Options{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	return provisioner.Provision(swarm.Options{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions, provision.Scripts{})
+}
+
+// RotateCertsIfExpiring regenerates the server certificate of the host when
+// it expires in less than the given number of days, without provisioning
+// the machine again. It tells whether the certificate was regenerated.
+func (h *Host) RotateCertsIfExpiring(days int) (bool, error) {
+	authOptions := h.AuthOptions()
+	if days <= 0 || authOptions == nil {
+		return false, nil
+	}
+
+	expiring, err := cert.ExpiresWithin(authOptions.ServerCertPath, days)
+	if err != nil {
+		return false, err
+	}
+	if !expiring {
+		return false, nil
+	}
+
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return false, err
+	}
+
+	swarmOptions := swarm.Options{}
+	if h.HostOptions.SwarmOptions != nil {
+		swarmOptions = *h.HostOptions.SwarmOptions
+	}
+	engineOptions := engine.Options{}
+	if h.HostOptions.EngineOptions != nil {
+		engineOptions = *h.HostOptions.EngineOptions
+	}
+
+	log.Infof("The server certificate of %q expires in less than %d days, regenerating it...", h.Name, days)
+	if err := provision.RegenerateServerCert(provisioner, swarmOptions, *authOptions, engineOptions); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
 func (h *Host) ConfigureAllAuth() error {
@@ -276,11 +356,31 @@ func (h *Host) ConfigureAllAuth() error {
 	return h.ConfigureAuth()
 }
 
+// ProvisionScripts returns the scripts to provision the host with.
+func (h *Host) ProvisionScripts() provision.Scripts {
+	if h.HostOptions == nil {
+		return provision.Scripts{}
+	}
+
+	return provision.Scripts{
+		PreProvision:  h.HostOptions.PreProvisionScript,
+		PostProvision: h.HostOptions.PostProvisionScript,
+	}
+}
+
 func (h *Host) Provision() error {
 	provisioner, err := provision.DetectProvisioner(h.Driver)
 	if err != nil {
 		return err
 	}
 
-	return provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions, h.ProvisionScripts()); err != nil {
+		return err
+	}
+
+	if err := provision.ConfigureSwarmMode(provisioner, h.HostOptions.SwarmOptions); err != nil {
+		return err
+	}
+
+	return provision.RunPostProvisionScript(provisioner, h.ProvisionScripts())
 }
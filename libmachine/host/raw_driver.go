@@ -0,0 +1,37 @@
+package host
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// RawDriverField returns a top level string field of a raw driver
+// configuration, or an empty string if it is not set.
+func RawDriverField(rawDriver []byte, field string) string {
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(rawDriver, &m); err != nil {
+		return ""
+	}
+
+	value, _ := m[field].(string)
+	return value
+}
+
+// RewriteRawDriver sets top level fields of a raw driver configuration,
+// leaving the fields it does not know about untouched.
+func RewriteRawDriver(rawDriver []byte, fields map[string]interface{}) ([]byte, error) {
+	m := make(map[string]interface{})
+
+	// Numbers are kept as is so that large integers survive the round trip.
+	d := json.NewDecoder(bytes.NewReader(rawDriver))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	for k, v := range fields {
+		m[k] = v
+	}
+
+	return json.Marshal(m)
+}
@@ -1,7 +1,9 @@
 package libmachine
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
 
 	"io"
@@ -30,6 +32,7 @@ type API interface {
 	io.Closer
 	NewHost(driverName string, rawDriver []byte) (*host.Host, error)
 	Create(h *host.Host) error
+	CreateContext(ctx context.Context, h *host.Host) error
 	persist.Store
 	GetMachinesDir() string
 }
@@ -39,20 +42,32 @@ type Client struct {
 	IsDebug        bool
 	SSHClientType  ssh.ClientType
 	GithubAPIToken string
-	*persist.Filestore
+	persist.MachineStore
 	clientDriverFactory rpcdriver.RPCClientDriverFactory
 }
 
 func NewClient(storePath, certsDir string) *Client {
+	return NewClientWithStore(persist.NewFilestore(storePath, certsDir, certsDir), certsDir)
+}
+
+// NewClientWithStore creates a client persisting hosts in the given store
+// instead of the default Filestore.
+func NewClientWithStore(store persist.MachineStore, certsDir string) *Client {
 	return &Client{
 		certsDir:            certsDir,
 		IsDebug:             false,
 		SSHClientType:       ssh.External,
-		Filestore:           persist.NewFilestore(storePath, certsDir, certsDir),
+		MachineStore:        store,
 		clientDriverFactory: rpcdriver.NewRPCClientDriverFactory(),
 	}
 }
 
+// Lock locks the machine name in the store, if the store supports it, until
+// unlock is called.
+func (api *Client) Lock(name string) (func(), error) {
+	return persist.LockMachines(api.MachineStore, []string{name})
+}
+
 func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, error) {
 	driver, err := api.clientDriverFactory.NewRPCClientDriver(driverName, rawDriver)
 	if err != nil {
@@ -89,7 +104,7 @@ func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, err
 }
 
 func (api *Client) Load(name string) (*host.Host, error) {
-	h, err := api.Filestore.Load(name)
+	h, err := api.MachineStore.Load(name)
 	if err != nil {
 		return nil, err
 	}
@@ -116,25 +131,46 @@ func (api *Client) Load(name string) (*host.Host, error) {
 // Create is the wrapper method which covers all of the boilerplate around
 // actually creating, provisioning, and persisting an instance in the store.
 func (api *Client) Create(h *host.Host) error {
+	return api.CreateContext(context.Background(), h)
+}
+
+// CreateContext is Create which is aborted as soon as the context is done,
+// e.g. to give up on a hung creation or to set an overall deadline.
+func (api *Client) CreateContext(ctx context.Context, h *host.Host) error {
 	if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
 		return fmt.Errorf("Error generating certificates: %s", err)
 	}
 
-	log.Info("Running pre-create checks...")
+	hostLog := log.WithFields(log.Fields{
+		"machine": h.Name,
+		"driver":  h.DriverName,
+	})
 
-	if err := h.Driver.PreCreateCheck(); err != nil {
+	hostLog.WithField("phase", "pre-create").Info("Running pre-create checks...")
+
+	d := drivers.WithContext(ctx, h.Driver)
+
+	if err := d.PreCreateCheck(); err != nil {
 		return mcnerror.ErrDuringPreCreate{
 			Cause: err,
 		}
 	}
 
 	if err := api.Save(h); err != nil {
-		return fmt.Errorf("Error saving host to store before attempting creation: %s", err)
+		return fmt.Errorf("Error saving host to store before attempting creation: %w", err)
 	}
 
-	log.Info("Creating machine...")
+	if err := writeCloudInit(h); err != nil {
+		return fmt.Errorf("Error generating the cloud-init user-data: %s", err)
+	}
+
+	if err := generateSSHKey(d, h.AuthOptions()); err != nil {
+		return fmt.Errorf("Error generating SSH key: %s", err)
+	}
 
-	if err := api.performCreate(h); err != nil {
+	hostLog.WithField("phase", "create").Info("Creating machine...")
+
+	if err := api.performCreate(ctx, d, h, hostLog); err != nil {
 		return fmt.Errorf("Error creating machine: %s", err)
 	}
 
@@ -143,13 +179,45 @@ func (api *Client) Create(h *host.Host) error {
 	return nil
 }
 
-func (api *Client) performCreate(h *host.Host) error {
-	if err := h.Driver.Create(); err != nil {
+// writeCloudInit generates the cloud-init user-data of a machine provisioned
+// with cloud-init in its directory, where its driver reads it from.
+func writeCloudInit(h *host.Host) error {
+	if h.HostOptions == nil || !h.HostOptions.CloudInit {
+		return nil
+	}
+
+	userData, err := provision.GenerateCloudInit(h.DriverName, h.Name, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(h.HostOptions.AuthOptions.StorePath, drivers.CloudInitFile), userData, 0600)
+}
+
+// generateSSHKey generates the SSH key of a machine ahead of its driver when
+// it is not RSA. The drivers only generate RSA keys, and leave a key which
+// already exists as is. Keys outside of the machine directory were given by
+// the user and are never generated.
+func generateSSHKey(d drivers.Driver, authOptions *auth.Options) error {
+	if authOptions == nil || authOptions.SSHKeyType == "" || authOptions.SSHKeyType == ssh.KeyTypeRSA {
+		return nil
+	}
+
+	keyPath := d.GetSSHKeyPath()
+	if keyPath == "" || filepath.Dir(keyPath) != filepath.Clean(authOptions.StorePath) {
+		return nil
+	}
+
+	return ssh.GenerateSSHKeyOfType(keyPath, authOptions.SSHKeyType)
+}
+
+func (api *Client) performCreate(ctx context.Context, d drivers.Driver, h *host.Host, hostLog *log.Entry) error {
+	if err := d.Create(); err != nil {
 		return fmt.Errorf("Error in driver during machine creation: %s", err)
 	}
 
 	if err := api.Save(h); err != nil {
-		return fmt.Errorf("Error saving host to store after attempting creation: %s", err)
+		return fmt.Errorf("Error saving host to store after attempting creation: %w", err)
 	}
 
 	// TODO: Not really a fan of just checking "none" or "ci-test" here.
@@ -157,32 +225,56 @@ func (api *Client) performCreate(h *host.Host) error {
 		return nil
 	}
 
-	log.Info("Waiting for machine to be running, this may take a few minutes...")
-	if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
+	hostLog.WithField("phase", "wait").Info("Waiting for machine to be running, this may take a few minutes...")
+	if err := mcnutils.WaitForContext(ctx, drivers.MachineInState(d, state.Running)); err != nil {
 		return fmt.Errorf("Error waiting for machine to be running: %s", err)
 	}
 
-	log.Info("Detecting operating system of created instance...")
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	hostLog.WithField("phase", "detect").Info("Detecting operating system of created instance...")
+	provisioner, err := provision.DetectProvisioner(d)
 	if err != nil {
 		return fmt.Errorf("Error detecting OS: %s", err)
 	}
 
-	log.Infof("Provisioning with %s...", provisioner.String())
-	if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+	// Provisioning runs over SSH and cannot be interrupted half way.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if h.HostOptions.CloudInit {
+		hostLog.WithField("phase", "provision").Info("Checking the provisioning by cloud-init...")
+		err = provision.VerifyCloudInit(provisioner, *h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, h.ProvisionScripts())
+	} else {
+		hostLog.WithField("phase", "provision").Infof("Provisioning with %s...", provisioner.String())
+		err = provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions, h.ProvisionScripts())
+	}
+	if err != nil {
+		return fmt.Errorf("Error running provisioning: %s", err)
+	}
+
+	if err := provision.ConfigureSwarmMode(provisioner, h.HostOptions.SwarmOptions); err != nil {
 		return fmt.Errorf("Error running provisioning: %s", err)
 	}
 
+	if err := provision.RunPostProvisionScript(provisioner, h.ProvisionScripts()); err != nil {
+		return fmt.Errorf("Error running provisioning: %s", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// We should check the connection to docker here
-	log.Info("Checking connection to Docker...")
+	hostLog.WithField("phase", "check").Info("Checking connection to Docker...")
 	if _, _, err = check.DefaultConnChecker.Check(h, false); err != nil {
 		return fmt.Errorf("Error checking the host: %s", err)
 	}
 
-	log.Info("Docker is up and running!")
+	hostLog.WithField("phase", "check").Info("Docker is up and running!")
 	return nil
 }
 
 func (api *Client) Close() error {
+	ssh.CloseConnections()
 	return api.clientDriverFactory.Close()
 }
@@ -1,6 +1,9 @@
 package libmachinetest
 
 import (
+	"context"
+	"sync"
+
 	"github.com/leoh0/machine/libmachine"
 	"github.com/leoh0/machine/libmachine/drivers"
 	"github.com/leoh0/machine/libmachine/host"
@@ -10,6 +13,10 @@ import (
 
 type FakeAPI struct {
 	Hosts []*host.Host
+
+	// mu guards Hosts against the commands acting on several machines at
+	// once.
+	mu sync.Mutex
 }
 
 func (api *FakeAPI) NewPluginDriver(string, []byte) (drivers.Driver, error) {
@@ -28,7 +35,14 @@ func (api *FakeAPI) Create(h *host.Host) error {
 	return nil
 }
 
+func (api *FakeAPI) CreateContext(ctx context.Context, h *host.Host) error {
+	return api.Create(h)
+}
+
 func (api *FakeAPI) Exists(name string) (bool, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
 	for _, host := range api.Hosts {
 		if name == host.Name {
 			return true, nil
@@ -39,10 +53,20 @@ func (api *FakeAPI) Exists(name string) (bool, error) {
 }
 
 func (api *FakeAPI) List() ([]string, error) {
-	return []string{}, nil
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	names := []string{}
+	for _, host := range api.Hosts {
+		names = append(names, host.Name)
+	}
+	return names, nil
 }
 
 func (api *FakeAPI) Load(name string) (*host.Host, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
 	for _, host := range api.Hosts {
 		if name == host.Name {
 			return host, nil
@@ -55,6 +79,9 @@ func (api *FakeAPI) Load(name string) (*host.Host, error) {
 }
 
 func (api *FakeAPI) Remove(name string) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
 	newHosts := []*host.Host{}
 
 	for _, host := range api.Hosts {
@@ -72,7 +99,7 @@ func (api *FakeAPI) Save(host *host.Host) error {
 	return nil
 }
 
-func (api FakeAPI) GetMachinesDir() string {
+func (api *FakeAPI) GetMachinesDir() string {
 	return ""
 }
 
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fields are structured data attached to a log message, such as the name of
+// the machine or the driver it relates to.
+type Fields map[string]interface{}
+
+// Level is the severity of a log message.
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levels = []string{
+	"debug",
+	"info",
+	"warn",
+	"error",
+}
+
+func (l Level) String() string {
+	if int(l) >= 0 && int(l) < len(levels) {
+		return levels[l]
+	}
+	return ""
+}
+
+// Entry logs messages with a set of fields attached.
+type Entry struct {
+	fields Fields
+}
+
+// WithField returns an Entry logging messages with the given field.
+func WithField(key string, value interface{}) *Entry {
+	return WithFields(Fields{key: value})
+}
+
+// WithFields returns an Entry logging messages with the given fields.
+func WithFields(fields Fields) *Entry {
+	return (&Entry{}).WithFields(fields)
+}
+
+// WithField returns a copy of the Entry with one more field.
+func (e *Entry) WithField(key string, value interface{}) *Entry {
+	return e.WithFields(Fields{key: value})
+}
+
+// WithFields returns a copy of the Entry with more fields.
+func (e *Entry) WithFields(fields Fields) *Entry {
+	merged := Fields{}
+	for k, v := range e.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Entry{fields: merged}
+}
+
+// Fields returns the fields of the Entry.
+func (e *Entry) Fields() Fields {
+	return e.fields
+}
+
+func (e *Entry) Debug(args ...interface{}) {
+	logEntry(DebugLevel, e.fields, sprintln(args...))
+}
+
+func (e *Entry) Debugf(fmtString string, args ...interface{}) {
+	logEntry(DebugLevel, e.fields, fmt.Sprintf(fmtString, args...))
+}
+
+func (e *Entry) Error(args ...interface{}) {
+	logEntry(ErrorLevel, e.fields, sprintln(args...))
+}
+
+func (e *Entry) Errorf(fmtString string, args ...interface{}) {
+	logEntry(ErrorLevel, e.fields, fmt.Sprintf(fmtString, args...))
+}
+
+func (e *Entry) Info(args ...interface{}) {
+	logEntry(InfoLevel, e.fields, sprintln(args...))
+}
+
+func (e *Entry) Infof(fmtString string, args ...interface{}) {
+	logEntry(InfoLevel, e.fields, fmt.Sprintf(fmtString, args...))
+}
+
+func (e *Entry) Warn(args ...interface{}) {
+	logEntry(WarnLevel, e.fields, sprintln(args...))
+}
+
+func (e *Entry) Warnf(fmtString string, args ...interface{}) {
+	logEntry(WarnLevel, e.fields, fmt.Sprintf(fmtString, args...))
+}
+
+// logEntry logs msg with its fields when the logger writes them, and the
+// message alone otherwise.
+func logEntry(level Level, fields Fields, msg string) {
+	if fl, ok := logger.(FieldLogger); ok {
+		fl.Log(level, fields, msg)
+		return
+	}
+
+	switch level {
+	case DebugLevel:
+		logger.Debug(msg)
+	case ErrorLevel:
+		logger.Error(msg)
+	case WarnLevel:
+		logger.Warn(msg)
+	default:
+		logger.Info(msg)
+	}
+}
+
+// sprintln formats like the fmt.Println based loggers, without the newline.
+func sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
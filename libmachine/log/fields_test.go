@@ -0,0 +1,48 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelString(t *testing.T) {
+	assert.Equal(t, "debug", DebugLevel.String())
+	assert.Equal(t, "error", ErrorLevel.String())
+	assert.Equal(t, "", Level(42).String())
+}
+
+func TestEntryWithFields(t *testing.T) {
+	entry := WithField("machine", "default")
+	withDriver := entry.WithFields(Fields{"driver": "virtualbox", "machine": "other"})
+
+	assert.Equal(t, Fields{"machine": "default"}, entry.Fields())
+	assert.Equal(t, Fields{"machine": "other", "driver": "virtualbox"}, withDriver.Fields())
+}
+
+func TestEntryLogsWithFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	testLogger, _, _ := newTestJSONLogger()
+	testLogger.SetOutWriter(out)
+
+	original := logger
+	defer func() { logger = original }()
+	SetMachineLogger(testLogger)
+
+	WithField("machine", "default").Info("Creating", "machine...")
+
+	assert.Equal(t, `{"level":"info","machine":"default","msg":"Creating machine...","time":"2016-01-02T03:04:05Z"}`+"\n", out.String())
+}
+
+func TestEntryLogsTextWithoutFields(t *testing.T) {
+	testLogger := NewFmtMachineLogger()
+
+	original := logger
+	defer func() { logger = original }()
+	SetMachineLogger(testLogger)
+
+	result := captureOutput(testLogger, func() { WithField("machine", "default").Infof("(%s) %s", "default", "info") })
+
+	assert.Equal(t, "(default) info", result)
+}
@@ -0,0 +1,119 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// JSONMachineLogger writes every message as a JSON object on its own line,
+// with the time, the level, the message and the fields of the message.
+type JSONMachineLogger struct {
+	lock      sync.Mutex
+	outWriter io.Writer
+	errWriter io.Writer
+	debug     bool
+	history   *HistoryRecorder
+	now       func() time.Time
+}
+
+// NewJSONMachineLogger creates a MachineLogger writing JSON lines, for
+// programs embedding libmachine which collect their logs.
+func NewJSONMachineLogger() MachineLogger {
+	return &JSONMachineLogger{
+		outWriter: os.Stdout,
+		errWriter: os.Stderr,
+		history:   NewHistoryRecorder(),
+		now:       time.Now,
+	}
+}
+
+func (ml *JSONMachineLogger) SetDebug(debug bool) {
+	ml.debug = debug
+}
+
+func (ml *JSONMachineLogger) SetOutWriter(out io.Writer) {
+	ml.outWriter = out
+}
+
+func (ml *JSONMachineLogger) SetErrWriter(err io.Writer) {
+	ml.errWriter = err
+}
+
+func (ml *JSONMachineLogger) Debug(args ...interface{}) {
+	ml.Log(DebugLevel, nil, sprintln(args...))
+}
+
+func (ml *JSONMachineLogger) Debugf(fmtString string, args ...interface{}) {
+	ml.Log(DebugLevel, nil, fmt.Sprintf(fmtString, args...))
+}
+
+func (ml *JSONMachineLogger) Error(args ...interface{}) {
+	ml.Log(ErrorLevel, nil, sprintln(args...))
+}
+
+func (ml *JSONMachineLogger) Errorf(fmtString string, args ...interface{}) {
+	ml.Log(ErrorLevel, nil, fmt.Sprintf(fmtString, args...))
+}
+
+func (ml *JSONMachineLogger) Info(args ...interface{}) {
+	ml.Log(InfoLevel, nil, sprintln(args...))
+}
+
+func (ml *JSONMachineLogger) Infof(fmtString string, args ...interface{}) {
+	ml.Log(InfoLevel, nil, fmt.Sprintf(fmtString, args...))
+}
+
+func (ml *JSONMachineLogger) Warn(args ...interface{}) {
+	ml.Log(WarnLevel, nil, sprintln(args...))
+}
+
+func (ml *JSONMachineLogger) Warnf(fmtString string, args ...interface{}) {
+	ml.Log(WarnLevel, nil, fmt.Sprintf(fmtString, args...))
+}
+
+// Log writes the message with its fields. Debug and error messages go to
+// the error writer, like with the FmtMachineLogger.
+func (ml *JSONMachineLogger) Log(level Level, fields Fields, msg string) {
+	ml.history.Record(msg)
+
+	if level == DebugLevel && !ml.debug {
+		return
+	}
+
+	entry := map[string]interface{}{}
+	for k, v := range fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		entry[k] = v
+	}
+	entry["time"] = ml.now().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]string{
+			"time":  entry["time"].(string),
+			"level": ErrorLevel.String(),
+			"msg":   fmt.Sprintf("Error marshalling log fields of %q: %s", msg, err),
+		})
+	}
+
+	w := ml.outWriter
+	if level == DebugLevel || level == ErrorLevel {
+		w = ml.errWriter
+	}
+
+	ml.lock.Lock()
+	defer ml.lock.Unlock()
+	fmt.Fprintf(w, "%s\n", line)
+}
+
+func (ml *JSONMachineLogger) History() []string {
+	return ml.history.records
+}
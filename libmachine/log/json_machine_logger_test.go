@@ -0,0 +1,68 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestJSONLogger() (*JSONMachineLogger, *bytes.Buffer, *bytes.Buffer) {
+	out, err := &bytes.Buffer{}, &bytes.Buffer{}
+
+	testLogger := NewJSONMachineLogger().(*JSONMachineLogger)
+	testLogger.SetOutWriter(out)
+	testLogger.SetErrWriter(err)
+	testLogger.now = func() time.Time {
+		return time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	}
+
+	return testLogger, out, err
+}
+
+func TestJSONInfo(t *testing.T) {
+	testLogger, out, _ := newTestJSONLogger()
+
+	testLogger.Info("info", 42)
+
+	assert.Equal(t, `{"level":"info","msg":"info 42","time":"2016-01-02T03:04:05Z"}`+"\n", out.String())
+}
+
+func TestJSONFields(t *testing.T) {
+	testLogger, out, _ := newTestJSONLogger()
+
+	testLogger.Log(WarnLevel, Fields{"machine": "default", "msg": "ignored", "cause": errors.New("BUG")}, "warn")
+
+	assert.Equal(t, `{"cause":"BUG","level":"warn","machine":"default","msg":"warn","time":"2016-01-02T03:04:05Z"}`+"\n", out.String())
+}
+
+func TestJSONDebug(t *testing.T) {
+	testLogger, _, err := newTestJSONLogger()
+
+	testLogger.Debugf("%s", "hidden")
+	assert.Empty(t, err.String())
+
+	testLogger.SetDebug(true)
+	testLogger.Debugf("%s", "debug")
+	assert.Equal(t, `{"level":"debug","msg":"debug","time":"2016-01-02T03:04:05Z"}`+"\n", err.String())
+}
+
+func TestJSONError(t *testing.T) {
+	testLogger, out, err := newTestJSONLogger()
+
+	testLogger.Errorf("%s", "error")
+
+	assert.Empty(t, out.String())
+	assert.Equal(t, `{"level":"error","msg":"error","time":"2016-01-02T03:04:05Z"}`+"\n", err.String())
+}
+
+func TestJSONHistory(t *testing.T) {
+	testLogger, _, _ := newTestJSONLogger()
+
+	testLogger.Info("info")
+	testLogger.Debug("debug")
+
+	assert.Equal(t, []string{"info", "debug"}, testLogger.History())
+}
@@ -22,3 +22,11 @@ type MachineLogger interface {
 
 	History() []string
 }
+
+// FieldLogger is implemented by the MachineLoggers which write the fields of
+// the messages logged with an Entry, such as the JSONMachineLogger. The
+// other loggers only write the messages.
+type FieldLogger interface {
+	// Log writes a message with structured fields at the given level.
+	Log(level Level, fields Fields, msg string)
+}
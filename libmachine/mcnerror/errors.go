@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/leoh0/machine/libmachine/state"
 )
@@ -44,3 +45,12 @@ type ErrHostAlreadyInState struct {
 func (e ErrHostAlreadyInState) Error() string {
 	return fmt.Sprintf("Machine %q is already %s.", e.Name, strings.ToLower(e.State.String()))
 }
+
+type ErrLockTimeout struct {
+	Path    string
+	Timeout time.Duration
+}
+
+func (e ErrLockTimeout) Error() string {
+	return fmt.Sprintf("Timed out after %s waiting for lock %q, another docker-machine process may be using it", e.Timeout, e.Path)
+}
@@ -1,6 +1,7 @@
 package mcnutils
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
@@ -79,7 +80,16 @@ func CopyFile(src, dst string) error {
 }
 
 func WaitForSpecificOrError(f func() (bool, error), maxAttempts int, waitInterval time.Duration) error {
+	return WaitForSpecificOrErrorContext(context.Background(), f, maxAttempts, waitInterval)
+}
+
+// WaitForSpecificOrErrorContext is WaitForSpecificOrError which gives up
+// with the error of the context as soon as it is done.
+func WaitForSpecificOrErrorContext(ctx context.Context, f func() (bool, error), maxAttempts int, waitInterval time.Duration) error {
 	for i := 0; i < maxAttempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		stop, err := f()
 		if err != nil {
 			return err
@@ -87,19 +97,31 @@ func WaitForSpecificOrError(f func() (bool, error), maxAttempts int, waitInterva
 		if stop {
 			return nil
 		}
-		time.Sleep(waitInterval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitInterval):
+		}
 	}
 	return fmt.Errorf("Maximum number of retries (%d) exceeded", maxAttempts)
 }
 
 func WaitForSpecific(f func() bool, maxAttempts int, waitInterval time.Duration) error {
-	return WaitForSpecificOrError(func() (bool, error) {
+	return WaitForSpecificContext(context.Background(), f, maxAttempts, waitInterval)
+}
+
+func WaitForSpecificContext(ctx context.Context, f func() bool, maxAttempts int, waitInterval time.Duration) error {
+	return WaitForSpecificOrErrorContext(ctx, func() (bool, error) {
 		return f(), nil
 	}, maxAttempts, waitInterval)
 }
 
 func WaitFor(f func() bool) error {
-	return WaitForSpecific(f, 60, 3*time.Second)
+	return WaitForContext(context.Background(), f)
+}
+
+func WaitForContext(ctx context.Context, f func() bool) error {
+	return WaitForSpecificContext(ctx, f, 60, 3*time.Second)
 }
 
 // TruncateID returns a shorten id
@@ -1,11 +1,13 @@
 package mcnutils
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestCopyFile(t *testing.T) {
@@ -99,3 +101,47 @@ func TestShortenIdInvalid(t *testing.T) {
 		t.Fatalf("Id returned is incorrect: truncate on %s returned %s", id, truncID)
 	}
 }
+
+func TestWaitForSpecificContext(t *testing.T) {
+	attempts := 0
+	err := WaitForSpecificContext(context.Background(), func() bool {
+		attempts++
+		return attempts == 3
+	}, 5, time.Millisecond)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestWaitForSpecificContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	err := WaitForSpecificContext(ctx, func() bool {
+		attempts++
+		cancel()
+		return false
+	}, 5, time.Hour)
+
+	if err != context.Canceled {
+		t.Fatalf("Expected %s, got %v", context.Canceled, err)
+	}
+	if attempts != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestWaitForSpecificContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := WaitForSpecificContext(ctx, func() bool { return false }, 5, time.Hour)
+
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected %s, got %v", context.DeadlineExceeded, err)
+	}
+}
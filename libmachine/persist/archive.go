@@ -0,0 +1,307 @@
+package persist
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/leoh0/machine/drivers/none"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/mcnerror"
+)
+
+const (
+	archiveConfigName = "config.json"
+	archiveMachineDir = "machine"
+	archiveCertsDir   = "certs"
+)
+
+// archiveFile maps a file on disk to its name in a machine archive.
+type archiveFile struct {
+	name string
+	path string
+}
+
+// Export writes a gzipped tarball holding the configuration of a machine
+// along with the certificates and SSH keys needed to use it from another
+// workstation. The disks of the machine are not part of the archive.
+func Export(store MachineStore, name string, w io.Writer) error {
+	h, err := store.Load(name)
+	if err != nil {
+		return err
+	}
+
+	config, err := json.MarshalIndent(h, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	if err := writeArchiveEntry(tw, archiveConfigName, config); err != nil {
+		return err
+	}
+
+	for _, f := range exportedFiles(store, h) {
+		data, err := ioutil.ReadFile(f.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := writeArchiveEntry(tw, f.name, data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func exportedFiles(store MachineStore, h *host.Host) []archiveFile {
+	machineDir := filepath.Join(store.GetMachinesDir(), h.Name)
+
+	// The copies of the client certificates made for 'docker-machine config'.
+	files := []archiveFile{
+		{path.Join(archiveMachineDir, "ca.pem"), filepath.Join(machineDir, "ca.pem")},
+		{path.Join(archiveMachineDir, "cert.pem"), filepath.Join(machineDir, "cert.pem")},
+		{path.Join(archiveMachineDir, "key.pem"), filepath.Join(machineDir, "key.pem")},
+	}
+
+	sshKeyPath := host.RawDriverField(h.RawDriver, "SSHKeyPath")
+	if sshKeyPath == "" {
+		sshKeyPath = filepath.Join(machineDir, "id_rsa")
+	}
+	files = append(files,
+		archiveFile{path.Join(archiveMachineDir, filepath.Base(sshKeyPath)), sshKeyPath},
+		archiveFile{path.Join(archiveMachineDir, filepath.Base(sshKeyPath)+".pub"), sshKeyPath + ".pub"},
+	)
+
+	if authOptions := h.AuthOptions(); authOptions != nil {
+		for _, p := range []string{authOptions.ServerCertPath, authOptions.ServerKeyPath} {
+			if p != "" {
+				files = append(files, archiveFile{path.Join(archiveMachineDir, filepath.Base(p)), p})
+			}
+		}
+
+		for _, p := range []string{authOptions.CaCertPath, authOptions.CaPrivateKeyPath, authOptions.ClientCertPath, authOptions.ClientKeyPath} {
+			if p != "" {
+				files = append(files, archiveFile{path.Join(archiveCertsDir, filepath.Base(p)), p})
+			}
+		}
+	}
+
+	// A file referenced twice is only archived once.
+	seen := map[string]bool{}
+	unique := []archiveFile{}
+	for _, f := range files {
+		if !seen[f.name] {
+			seen[f.name] = true
+			unique = append(unique, f)
+		}
+	}
+
+	return unique
+}
+
+func writeArchiveEntry(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	_, err := tw.Write(data)
+	return err
+}
+
+func readArchive(r io.Reader) (map[string][]byte, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	entries := map[string][]byte{}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		dir, base := path.Split(name)
+		knownDir := dir == archiveMachineDir+"/" || dir == archiveCertsDir+"/"
+		if (name != archiveConfigName && !knownDir) || base == "" || strings.HasPrefix(base, ".") {
+			return nil, fmt.Errorf("Unexpected file in machine archive: %s", hdr.Name)
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		entries[name] = data
+	}
+
+	return entries, nil
+}
+
+// Import adds the machine held in an archive made by Export to the store.
+// The paths recorded in the configuration are rewritten to point into the
+// store. The CA and client certificates are shared with certsDir when they
+// are identical to the ones found there, and kept in the machine directory
+// otherwise.
+func Import(store MachineStore, certsDir string, r io.Reader) (*host.Host, error) {
+	entries, err := readArchive(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading machine archive: %s", err)
+	}
+
+	config, ok := entries[archiveConfigName]
+	if !ok {
+		return nil, fmt.Errorf("Error reading machine archive: no %s found", archiveConfigName)
+	}
+
+	var metadata struct {
+		Name string
+	}
+	if err := json.Unmarshal(config, &metadata); err != nil {
+		return nil, fmt.Errorf("Error reading machine configuration: %s", err)
+	}
+
+	name := metadata.Name
+	if !host.ValidateHostName(name) {
+		return nil, mcnerror.ErrInvalidHostname
+	}
+
+	exists, err := store.Exists(name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, mcnerror.ErrHostAlreadyExists{
+			Name: name,
+		}
+	}
+
+	h, _, err := host.MigrateHost(&host.Host{Name: name}, config)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting migrated host: %s", err)
+	}
+	h.Name = name
+
+	storePath := filepath.Dir(store.GetMachinesDir())
+	machineDir := filepath.Join(store.GetMachinesDir(), name)
+
+	if err := os.MkdirAll(machineDir, 0700); err != nil {
+		return nil, err
+	}
+
+	// Do not leave a half imported machine behind.
+	imported := false
+	defer func() {
+		if !imported {
+			os.RemoveAll(machineDir)
+		}
+	}()
+
+	paths := map[string]string{}
+	for entryName, data := range entries {
+		dir, base := path.Split(entryName)
+
+		var target string
+		switch dir {
+		case archiveMachineDir + "/":
+			target = filepath.Join(machineDir, base)
+		case archiveCertsDir + "/":
+			target = filepath.Join(certsDir, base)
+			if existing, err := ioutil.ReadFile(target); err == nil && bytes.Equal(existing, data) {
+				paths[entryName] = target
+				continue
+			}
+			target = filepath.Join(machineDir, archiveCertsDir, base)
+		default:
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(target, data, 0600); err != nil {
+			return nil, err
+		}
+		paths[entryName] = target
+	}
+
+	relocate := func(p, archiveDir, defaultDir string) string {
+		if p == "" {
+			return ""
+		}
+		if target, ok := paths[path.Join(archiveDir, filepath.Base(p))]; ok {
+			return target
+		}
+		return filepath.Join(defaultDir, filepath.Base(p))
+	}
+
+	if authOptions := h.AuthOptions(); authOptions != nil {
+		authOptions.CaCertPath = relocate(authOptions.CaCertPath, archiveCertsDir, certsDir)
+		authOptions.CaPrivateKeyPath = relocate(authOptions.CaPrivateKeyPath, archiveCertsDir, certsDir)
+		authOptions.ClientCertPath = relocate(authOptions.ClientCertPath, archiveCertsDir, certsDir)
+		authOptions.ClientKeyPath = relocate(authOptions.ClientKeyPath, archiveCertsDir, certsDir)
+		authOptions.ServerCertPath = relocate(authOptions.ServerCertPath, archiveMachineDir, machineDir)
+		authOptions.ServerKeyPath = relocate(authOptions.ServerKeyPath, archiveMachineDir, machineDir)
+		if authOptions.CaCertPath != "" {
+			authOptions.CertDir = filepath.Dir(authOptions.CaCertPath)
+		}
+		authOptions.StorePath = machineDir
+	}
+
+	driverFields := map[string]interface{}{
+		"StorePath": storePath,
+	}
+	if sshKeyPath := host.RawDriverField(h.RawDriver, "SSHKeyPath"); sshKeyPath != "" {
+		driverFields["SSHKeyPath"] = relocate(sshKeyPath, archiveMachineDir, machineDir)
+	}
+
+	rawDriver, err := host.RewriteRawDriver(h.RawDriver, driverFields)
+	if err != nil {
+		return nil, fmt.Errorf("Error rewriting driver configuration: %s", err)
+	}
+
+	h.RawDriver = rawDriver
+	h.Driver = &host.RawDataDriver{Driver: none.NewDriver(name, storePath), Data: rawDriver}
+
+	if err := store.Save(h); err != nil {
+		return nil, err
+	}
+
+	imported = true
+
+	return h, nil
+}
@@ -0,0 +1,105 @@
+package persist
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/hosttest"
+	"github.com/leoh0/machine/libmachine/mcnerror"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	from := getTestStore()
+	defer os.RemoveAll(from.Path)
+	to := getTestStore()
+	defer os.RemoveAll(to.Path)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromCerts := filepath.Join(from.Path, "certs")
+	fromMachine := filepath.Join(from.GetMachinesDir(), h.Name)
+	h.HostOptions.AuthOptions.CertDir = fromCerts
+	h.HostOptions.AuthOptions.CaCertPath = filepath.Join(fromCerts, "ca.pem")
+	h.HostOptions.AuthOptions.ClientCertPath = filepath.Join(fromCerts, "cert.pem")
+	h.HostOptions.AuthOptions.ServerCertPath = filepath.Join(fromMachine, "server.pem")
+	h.HostOptions.AuthOptions.StorePath = fromMachine
+
+	if err := from.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, filepath.Join(fromCerts, "ca.pem"), "ca")
+	writeTestFile(t, filepath.Join(fromCerts, "cert.pem"), "client cert")
+	writeTestFile(t, filepath.Join(fromMachine, "server.pem"), "server cert")
+	writeTestFile(t, filepath.Join(fromMachine, "id_rsa"), "ssh key")
+
+	// The destination already has the same CA but another client cert.
+	toCerts := filepath.Join(to.Path, "certs")
+	writeTestFile(t, filepath.Join(toCerts, "ca.pem"), "ca")
+	writeTestFile(t, filepath.Join(toCerts, "cert.pem"), "another client cert")
+
+	archive := &bytes.Buffer{}
+	if err := Export(from, h.Name, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	data := archive.Bytes()
+	if _, err := Import(&to, toCerts, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := to.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toMachine := filepath.Join(to.GetMachinesDir(), h.Name)
+	authOptions := imported.HostOptions.AuthOptions
+
+	expectedPaths := map[string]string{
+		authOptions.CaCertPath:                               filepath.Join(toCerts, "ca.pem"),
+		authOptions.ClientCertPath:                           filepath.Join(toMachine, "certs", "cert.pem"),
+		authOptions.ServerCertPath:                           filepath.Join(toMachine, "server.pem"),
+		authOptions.StorePath:                                toMachine,
+		host.RawDriverField(imported.RawDriver, "StorePath"): to.Path,
+	}
+	for actual, expected := range expectedPaths {
+		if actual != expected {
+			t.Fatalf("Expected path %q, got %q", expected, actual)
+		}
+	}
+
+	for path, expected := range map[string]string{
+		authOptions.ClientCertPath:         "client cert",
+		authOptions.ServerCertPath:         "server cert",
+		filepath.Join(toMachine, "id_rsa"): "ssh key",
+	} {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected {
+			t.Fatalf("Expected %q in %s, got %q", expected, path, content)
+		}
+	}
+
+	if _, err := Import(&to, toCerts, bytes.NewReader(data)); err != (mcnerror.ErrHostAlreadyExists{Name: h.Name}) {
+		t.Fatalf("Expected ErrHostAlreadyExists, got %v", err)
+	}
+}
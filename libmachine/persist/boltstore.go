@@ -0,0 +1,206 @@
+package persist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/mcnerror"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	boltstoreFileName = "machines.db"
+)
+
+var (
+	hostsBucket = []byte("hosts")
+)
+
+// Boltstore keeps every host configuration in a single BoltDB file at the
+// root of the storage path, so listing and loading machines does not require
+// walking the machines directory. Per-machine artifacts (certificates, SSH
+// keys, disks) still live under the machines directory.
+type Boltstore struct {
+	Path             string
+	CaCertPath       string
+	CaPrivateKeyPath string
+	// LockTimeout is how long to wait for another process to release the
+	// database file. DefaultLockTimeout is used if it is zero.
+	LockTimeout time.Duration
+}
+
+func NewBoltstore(path, caCertPath, caPrivateKeyPath string) *Boltstore {
+	return &Boltstore{
+		Path:             path,
+		CaCertPath:       caCertPath,
+		CaPrivateKeyPath: caPrivateKeyPath,
+	}
+}
+
+func (s Boltstore) GetMachinesDir() string {
+	return filepath.Join(s.Path, "machines")
+}
+
+func (s Boltstore) dbPath() string {
+	return filepath.Join(s.Path, boltstoreFileName)
+}
+
+func (s Boltstore) open(readOnly bool) (*bolt.DB, error) {
+	timeout := s.LockTimeout
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+
+	db, err := bolt.Open(s.dbPath(), 0600, &bolt.Options{Timeout: timeout, ReadOnly: readOnly})
+	if err == bolt.ErrTimeout {
+		return nil, mcnerror.ErrLockTimeout{
+			Path:    s.dbPath(),
+			Timeout: timeout,
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error opening store %s: %s", s.dbPath(), err)
+	}
+
+	return db, nil
+}
+
+func (s Boltstore) update(fn func(b *bolt.Bucket) error) error {
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return err
+	}
+
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(hostsBucket)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+func (s Boltstore) view(fn func(b *bolt.Bucket) error) error {
+	// Nothing has ever been saved, so there is nothing to read. Avoid
+	// creating an empty database as a side effect of a read.
+	if _, err := os.Stat(s.dbPath()); os.IsNotExist(err) {
+		return fn(nil)
+	}
+
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(hostsBucket))
+	})
+}
+
+func (s Boltstore) Save(host *host.Host) error {
+	data, err := json.MarshalIndent(host, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// Drivers still expect the machine directory to exist to store their
+	// own files.
+	if err := os.MkdirAll(filepath.Join(s.GetMachinesDir(), host.Name), 0700); err != nil {
+		return err
+	}
+
+	return s.update(func(b *bolt.Bucket) error {
+		return b.Put([]byte(host.Name), data)
+	})
+}
+
+func (s Boltstore) Remove(name string) error {
+	if err := s.update(func(b *bolt.Bucket) error {
+		return b.Delete([]byte(name))
+	}); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(filepath.Join(s.GetMachinesDir(), name))
+}
+
+func (s Boltstore) List() ([]string, error) {
+	hostNames := []string{}
+
+	err := s.view(func(b *bolt.Bucket) error {
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			hostNames = append(hostNames, string(k))
+			return nil
+		})
+	})
+
+	return hostNames, err
+}
+
+func (s Boltstore) Exists(name string) (bool, error) {
+	exists := false
+
+	err := s.view(func(b *bolt.Bucket) error {
+		exists = b != nil && b.Get([]byte(name)) != nil
+		return nil
+	})
+
+	return exists, err
+}
+
+func (s Boltstore) Load(name string) (*host.Host, error) {
+	var data []byte
+
+	if err := s.view(func(b *bolt.Bucket) error {
+		if b == nil {
+			return nil
+		}
+		// The value is only valid for the life of the transaction.
+		if v := b.Get([]byte(name)); v != nil {
+			data = append([]byte{}, v...)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, mcnerror.ErrHostDoesNotExist{
+			Name: name,
+		}
+	}
+
+	h := &host.Host{
+		Name: name,
+	}
+
+	migratedHost, migrationPerformed, err := host.MigrateHost(h, data)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting migrated host: %s", err)
+	}
+
+	*h = *migratedHost
+
+	h.Name = name
+
+	if migrationPerformed {
+		if err := s.Save(h); err != nil {
+			return nil, fmt.Errorf("Error saving config after migration was performed: %s", err)
+		}
+	}
+
+	return h, nil
+}
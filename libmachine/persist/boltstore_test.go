@@ -0,0 +1,190 @@
+package persist
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leoh0/machine/drivers/none"
+	"github.com/leoh0/machine/libmachine/host"
+	"github.com/leoh0/machine/libmachine/hosttest"
+	"github.com/leoh0/machine/libmachine/mcnerror"
+)
+
+func getTestBoltstore() *Boltstore {
+	store := getTestStore()
+	return NewBoltstore(store.Path, store.CaCertPath, store.CaPrivateKeyPath)
+}
+
+func TestBoltstoreSaveLoad(t *testing.T) {
+	defer cleanup()
+
+	expectedURL := "unix:///foo/baz"
+	flags := hosttest.GetTestDriverFlags()
+	flags.Data["url"] = expectedURL
+
+	store := getTestBoltstore()
+	defer os.RemoveAll(store.Path)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Driver.SetConfigFromFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(store.GetMachinesDir(), h.Name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		t.Fatalf("Host path doesn't exist: %s", path)
+	}
+
+	if _, err := os.Stat(filepath.Join(path, "config.json")); !os.IsNotExist(err) {
+		t.Fatal("Boltstore should not write a config.json file")
+	}
+
+	h, err = store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rawDataDriver, ok := h.Driver.(*host.RawDataDriver)
+	if !ok {
+		t.Fatal("Expected driver loaded from store to be of type *host.RawDataDriver and it was not")
+	}
+
+	realDriver := none.NewDriver(h.Name, store.Path)
+	if err := json.Unmarshal(rawDataDriver.Data, &realDriver); err != nil {
+		t.Fatalf("Error unmarshaling rawDataDriver data into concrete 'none' driver: %s", err)
+	}
+
+	h.Driver = realDriver
+
+	actualURL, err := h.URL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actualURL != expectedURL {
+		t.Fatalf("GetURL is not %q, got %q", expectedURL, actualURL)
+	}
+}
+
+func TestBoltstoreListExistsRemove(t *testing.T) {
+	store := getTestBoltstore()
+	defer os.RemoveAll(store.Path)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 0 {
+		t.Fatalf("List returned %d items, expected 0", len(hosts))
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err = store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0] != h.Name {
+		t.Fatalf("List returned %v, expected [%s]", hosts, h.Name)
+	}
+
+	exists, err := store.Exists(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("Host should exist after saving")
+	}
+
+	if err := store.Remove(h.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err = store.Exists(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("Host should not exist after removing")
+	}
+
+	if _, err := os.Stat(filepath.Join(store.GetMachinesDir(), h.Name)); err == nil {
+		t.Fatal("Host path still exists after remove")
+	}
+
+	if _, err := store.Load(h.Name); err != (mcnerror.ErrHostDoesNotExist{Name: h.Name}) {
+		t.Fatalf("Expected ErrHostDoesNotExist, got %v", err)
+	}
+}
+
+func TestMigrateFilestoreToBoltstore(t *testing.T) {
+	defer cleanup()
+
+	from := getTestStore()
+	to := NewBoltstore(from.Path, from.CaCertPath, from.CaPrivateKeyPath)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := from.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := Migrate(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 1 || migrated[0] != h.Name {
+		t.Fatalf("Migrate returned %v, expected [%s]", migrated, h.Name)
+	}
+
+	if _, err := to.Load(h.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hosts already present in the destination are left untouched.
+	migrated, err = Migrate(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 0 {
+		t.Fatalf("Migrate returned %v, expected nothing", migrated)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	if s, err := NewStore("", "/tmp", "", ""); err != nil {
+		t.Fatal(err)
+	} else if _, ok := s.(*Filestore); !ok {
+		t.Fatal("Expected the default store to be a Filestore")
+	}
+
+	if s, err := NewStore(BoltstoreDriver, "/tmp", "", ""); err != nil {
+		t.Fatal(err)
+	} else if _, ok := s.(*Boltstore); !ok {
+		t.Fatal("Expected a Boltstore")
+	}
+
+	if _, err := NewStore("unknown", "/tmp", "", ""); err == nil {
+		t.Fatal("Expected an error for an unknown storage driver")
+	}
+}
@@ -7,15 +7,25 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/leoh0/machine/libmachine/host"
 	"github.com/leoh0/machine/libmachine/mcnerror"
 )
 
+const (
+	storeLockFileName = ".store.lock"
+	machineLocksDir   = ".locks"
+)
+
 type Filestore struct {
 	Path             string
 	CaCertPath       string
 	CaPrivateKeyPath string
+	// LockTimeout is how long to wait for another process to release a
+	// lock on the store or on a machine. DefaultLockTimeout is used if
+	// it is zero.
+	LockTimeout time.Duration
 }
 
 func NewFilestore(path, caCertPath, caPrivateKeyPath string) *Filestore {
@@ -30,31 +40,72 @@ func (s Filestore) GetMachinesDir() string {
 	return filepath.Join(s.Path, "machines")
 }
 
-func (s Filestore) saveToFile(data []byte, file string) error {
-	if _, err := os.Stat(file); os.IsNotExist(err) {
-		return ioutil.WriteFile(file, data, 0600)
+func (s Filestore) lockTimeout() time.Duration {
+	if s.LockTimeout > 0 {
+		return s.LockTimeout
 	}
+	return DefaultLockTimeout
+}
+
+// lockStore takes the store-wide lock. It is held exclusively while
+// machines are removed from the store, and shared while they are saved. It
+// is only held for the duration of a single Save or Remove, and must be
+// taken after any machine lock.
+func (s Filestore) lockStore(exclusive bool) (*fileLock, error) {
+	return acquireLock(filepath.Join(s.Path, storeLockFileName), exclusive, s.lockTimeout())
+}
+
+func (s Filestore) machineLockPath(name string) string {
+	return filepath.Join(s.GetMachinesDir(), machineLocksDir, name+".lock")
+}
 
-	tmpfi, err := ioutil.TempFile(filepath.Dir(file), "config.json.tmp")
+// lockMachine takes the lock of a single machine, held by Lock and while
+// the machine is removed. The lock files live outside of the machine
+// directory so that they survive its removal.
+func (s Filestore) lockMachine(name string) (*fileLock, error) {
+	return acquireLock(s.machineLockPath(name), true, s.lockTimeout())
+}
+
+// Lock locks the machine name until unlock is called. It is held across
+// the load, change and save of the machine configuration, so that other
+// processes cannot change or remove it in between. It does not hold the
+// store lock, so other machines can be removed meanwhile. Remove must not
+// be called on the machine while holding its lock.
+func (s Filestore) Lock(name string) (func(), error) {
+	machineLock, err := s.lockMachine(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		machineLock.Unlock()
+	}, nil
+}
+
+// saveToFile writes data to a temporary file next to file and renames it
+// over file, so that readers never see a partially written file.
+func (s Filestore) saveToFile(data []byte, file string) error {
+	tmpfi, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
 	if err != nil {
 		return err
 	}
 	defer os.Remove(tmpfi.Name())
 
-	if err = ioutil.WriteFile(tmpfi.Name(), data, 0600); err != nil {
+	if _, err = tmpfi.Write(data); err != nil {
+		tmpfi.Close()
 		return err
 	}
 
-	if err = tmpfi.Close(); err != nil {
+	if err = tmpfi.Sync(); err != nil {
+		tmpfi.Close()
 		return err
 	}
 
-	if err = os.Remove(file); err != nil {
+	if err = tmpfi.Close(); err != nil {
 		return err
 	}
 
-	err = os.Rename(tmpfi.Name(), file)
-	return err
+	return os.Rename(tmpfi.Name(), file)
 }
 
 func (s Filestore) Save(host *host.Host) error {
@@ -65,6 +116,12 @@ func (s Filestore) Save(host *host.Host) error {
 
 	hostPath := filepath.Join(s.GetMachinesDir(), host.Name)
 
+	storeLock, err := s.lockStore(false)
+	if err != nil {
+		return err
+	}
+	defer storeLock.Unlock()
+
 	// Ensure that the directory we want to save to exists.
 	if err := os.MkdirAll(hostPath, 0700); err != nil {
 		return err
@@ -74,6 +131,18 @@ func (s Filestore) Save(host *host.Host) error {
 }
 
 func (s Filestore) Remove(name string) error {
+	machineLock, err := s.lockMachine(name)
+	if err != nil {
+		return err
+	}
+	defer machineLock.Unlock()
+
+	storeLock, err := s.lockStore(true)
+	if err != nil {
+		return err
+	}
+	defer storeLock.Unlock()
+
 	hostPath := filepath.Join(s.GetMachinesDir(), name)
 	return os.RemoveAll(hostPath)
 }
@@ -8,11 +8,13 @@ import (
 	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/leoh0/machine/commands/mcndirs"
 	"github.com/leoh0/machine/drivers/none"
 	"github.com/leoh0/machine/libmachine/host"
 	"github.com/leoh0/machine/libmachine/hosttest"
+	"github.com/leoh0/machine/libmachine/mcnerror"
 )
 
 func cleanup() {
@@ -247,3 +249,143 @@ func TestStoreLoad(t *testing.T) {
 		t.Fatalf("GetURL is not %q, got %q", expectedURL, actualURL)
 	}
 }
+
+func TestStoreRemoveWaitsForMachineLock(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+	store.LockTimeout = 100 * time.Millisecond
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := store.Lock(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Remove(h.Name)
+	if _, ok := err.(mcnerror.ErrLockTimeout); !ok {
+		t.Fatalf("Expected ErrLockTimeout while the machine is locked, got %v", err)
+	}
+
+	unlock()
+
+	if err := store.Remove(h.Name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreRemoveLockTimeout(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+	store.LockTimeout = 100 * time.Millisecond
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := store.lockStore(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+
+	err = store.Remove(h.Name)
+	if _, ok := err.(mcnerror.ErrLockTimeout); !ok {
+		t.Fatalf("Expected ErrLockTimeout while the store is locked, got %v", err)
+	}
+
+	if exists, _ := store.Exists(h.Name); !exists {
+		t.Fatal("Host should not have been removed while the store is locked")
+	}
+}
+
+func TestStoreSaveWaitsForStoreLock(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+	store.LockTimeout = 100 * time.Millisecond
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := store.lockStore(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+
+	err = store.Save(h)
+	if _, ok := err.(mcnerror.ErrLockTimeout); !ok {
+		t.Fatalf("Expected ErrLockTimeout while the store is locked, got %v", err)
+	}
+}
+
+func TestStoreLock(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+	store.LockTimeout = 100 * time.Millisecond
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Name = "other"
+
+	if err := store.Save(other); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := store.Lock(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatalf("Expected Save to succeed while holding the lock, got %v", err)
+	}
+
+	// Another process cannot lock the machine meanwhile.
+	_, err = store.lockMachine(h.Name)
+	if _, ok := err.(mcnerror.ErrLockTimeout); !ok {
+		t.Fatalf("Expected ErrLockTimeout while the machine is locked, got %v", err)
+	}
+
+	// But it can remove other machines.
+	if err := store.Remove(other.Name); err != nil {
+		t.Fatalf("Expected Remove of another machine to succeed while holding the lock, got %v", err)
+	}
+
+	unlock()
+
+	lock, err := store.lockMachine(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Unlock()
+}
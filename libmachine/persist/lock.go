@@ -0,0 +1,74 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/leoh0/machine/libmachine/mcnerror"
+)
+
+const (
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+var (
+	// DefaultLockTimeout is how long a store operation waits for another
+	// docker-machine process to release a lock when no timeout is set.
+	DefaultLockTimeout = 30 * time.Second
+)
+
+// fileLock is an advisory lock held on a file, shared between processes.
+type fileLock struct {
+	file *os.File
+}
+
+// TryLockFile takes an exclusive lock on file without waiting, and tells if
+// it got it. The lock is released when file is closed.
+func TryLockFile(file *os.File) (bool, error) {
+	return tryLock(file, true)
+}
+
+// acquireLock blocks until the lock on path is obtained or the timeout
+// expires, in which case an mcnerror.ErrLockTimeout is returned.
+func acquireLock(path string, exclusive bool, timeout time.Duration) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(file, exclusive)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		if locked {
+			return &fileLock{file: file}, nil
+		}
+
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, mcnerror.ErrLockTimeout{
+				Path:    path,
+				Timeout: timeout,
+			}
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+func (l *fileLock) Unlock() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
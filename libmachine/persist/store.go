@@ -1,9 +1,17 @@
 package persist
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/leoh0/machine/libmachine/host"
 )
 
+const (
+	FilestoreDriver = "filestore"
+	BoltstoreDriver = "boltdb"
+)
+
 type Store interface {
 	// Exists returns whether a machine exists or not
 	Exists(name string) (bool, error)
@@ -21,6 +29,104 @@ type Store interface {
 	Save(host *host.Host) error
 }
 
+// MachineLocker is implemented by the stores able to lock a machine across
+// the load, change and save of its configuration.
+type MachineLocker interface {
+	// Lock locks the machine name until unlock is called.
+	Lock(name string) (unlock func(), err error)
+}
+
+// LockMachines locks the named machines of s, if s is a MachineLocker, and
+// returns the function unlocking them. The machines are locked in name
+// order, so that processes locking the same machines cannot deadlock.
+func LockMachines(s Store, names []string) (func(), error) {
+	unlocks := []func(){}
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+
+	locker, ok := s.(MachineLocker)
+	if !ok {
+		return unlockAll, nil
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	for i, name := range sorted {
+		if i > 0 && name == sorted[i-1] {
+			continue
+		}
+
+		unlock, err := locker.Lock(name)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+
+	return unlockAll, nil
+}
+
+// MachineStore is a Store rooted in a storage path. Whatever the backend
+// used for the host configurations, the machines directory holds the
+// certificates, SSH keys and disks of each machine.
+type MachineStore interface {
+	Store
+
+	// GetMachinesDir returns the directory holding per-machine artifacts
+	GetMachinesDir() string
+}
+
+// NewStore returns the MachineStore implementation for the given storage
+// driver. An empty driver name selects the Filestore.
+func NewStore(driverName, path, caCertPath, caPrivateKeyPath string) (MachineStore, error) {
+	switch driverName {
+	case "", FilestoreDriver:
+		return NewFilestore(path, caCertPath, caPrivateKeyPath), nil
+	case BoltstoreDriver:
+		return NewBoltstore(path, caCertPath, caPrivateKeyPath), nil
+	default:
+		return nil, fmt.Errorf("Unsupported storage driver %q, expected one of: %s, %s", driverName, FilestoreDriver, BoltstoreDriver)
+	}
+}
+
+// Migrate copies every host found in from into to, skipping the hosts which
+// already exist in to. It returns the names of the hosts copied.
+func Migrate(from, to Store) ([]string, error) {
+	hostNames, err := from.List()
+	if err != nil {
+		return nil, err
+	}
+
+	migrated := []string{}
+	for _, hostName := range hostNames {
+		exists, err := to.Exists(hostName)
+		if err != nil {
+			return migrated, err
+		}
+		if exists {
+			continue
+		}
+
+		h, err := from.Load(hostName)
+		if err != nil {
+			return migrated, fmt.Errorf("Error loading host %q: %s", hostName, err)
+		}
+
+		if err := to.Save(h); err != nil {
+			return migrated, fmt.Errorf("Error saving host %q: %s", hostName, err)
+		}
+
+		migrated = append(migrated, hostName)
+	}
+
+	return migrated, nil
+}
+
 func LoadHosts(s Store, hostNames []string) ([]*host.Host, map[string]error) {
 	loadedHosts := []*host.Host{}
 	errors := map[string]error{}
@@ -88,7 +88,7 @@ func (provisioner *ArchProvisioner) dockerDaemonResponding() bool {
 	return true
 }
 
-func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
@@ -106,6 +106,10 @@ func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOp
 		return err
 	}
 
+	if err := runProvisionScript(provisioner, preProvisionScript, scripts.PreProvision); err != nil {
+		return err
+	}
+
 	log.Debug("Setting hostname")
 	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
 		return err
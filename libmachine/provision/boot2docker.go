@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
 	"path"
 	"text/template"
 	"time"
@@ -39,6 +40,9 @@ type Boot2DockerProvisioner struct {
 	AuthOptions   auth.Options
 	EngineOptions engine.Options
 	SwarmOptions  swarm.Options
+	// sshCommander runs the SSH commands instead of the driver when set,
+	// for dry runs.
+	sshCommander SSHCommander
 }
 
 func (provisioner *Boot2DockerProvisioner) String() string {
@@ -155,7 +159,7 @@ DOCKER_TLS=auto
 SERVERKEY={{.AuthOptions.ServerKeyRemotePath}}
 SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 
-{{range .EngineOptions.Env}}export \"{{ printf "%q" . }}\"
+{{range .EngineOptions.Env}}export {{ printf "%q" . }}
 {{end}}
 `
 	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
@@ -163,10 +167,18 @@ SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 		return nil, err
 	}
 
+	// /etc does not persist on boot2docker, daemon.json is kept with the
+	// other settings.
+	daemonConfig := newDaemonConfig(provisioner.EngineOptions, path.Join(provisioner.GetDockerOptionsDir(), "daemon.json"))
+	flagOptions := engineFlagOptions(provisioner.EngineOptions)
+	if daemonConfig != nil {
+		flagOptions.ArbitraryFlags = append(flagOptions.ArbitraryFlags, "config-file="+daemonConfig.Path)
+	}
+
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   provisioner.AuthOptions,
-		EngineOptions: provisioner.EngineOptions,
+		EngineOptions: flagOptions,
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -175,6 +187,7 @@ SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: daemonOptsDir,
+		DaemonConfig:      daemonConfig,
 	}, nil
 }
 
@@ -215,7 +228,7 @@ You also might want to clear any VirtualBox host only interfaces you are not usi
 	}
 }
 
-func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) error {
 	var (
 		err error
 	)
@@ -235,6 +248,10 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options,
 		provisioner.EngineOptions.StorageDriver = "overlay2"
 	}
 
+	if err = runProvisionScript(provisioner, preProvisionScript, scripts.PreProvision); err != nil {
+		return err
+	}
+
 	if err = provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
 		return err
 	}
@@ -260,9 +277,26 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options,
 }
 
 func (provisioner *Boot2DockerProvisioner) SSHCommand(args string) (string, error) {
+	if provisioner.sshCommander != nil {
+		return provisioner.sshCommander.SSHCommand(args)
+	}
 	return drivers.RunSSHCommandFromDriver(provisioner.Driver, args)
 }
 
+func (provisioner *Boot2DockerProvisioner) SSHUpload(content []byte, dst string, mode os.FileMode, owner string) error {
+	if provisioner.sshCommander != nil {
+		return provisioner.sshCommander.SSHUpload(content, dst, mode, owner)
+	}
+	return uploadFile(provisioner, provisioner.Driver, content, dst, mode, owner)
+}
+
+func (provisioner *Boot2DockerProvisioner) SSHStream(args string, logLine func(string)) error {
+	if provisioner.sshCommander != nil {
+		return provisioner.sshCommander.SSHStream(args, logLine)
+	}
+	return GenericSSHCommander{Driver: provisioner.Driver}.SSHStream(args, logLine)
+}
+
 func (provisioner *Boot2DockerProvisioner) GetDriver() drivers.Driver {
 	return provisioner.Driver
 }
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net"
-	"path"
+	"os"
 	"strings"
 	"text/template"
 	"time"
@@ -71,7 +71,7 @@ func concatStrings(src []string, prefix string, postfix string) []string {
 func updateUnit(p SSHCommander, name string, content string, dst string) error {
 	log.Infof("Updating %s unit: %s ...", name, dst)
 
-	if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s && printf %%s \"%s\" | sudo tee %s.new", path.Dir(dst), content, dst)); err != nil {
+	if err := p.SSHUpload([]byte(content), dst+".new", 0644, ""); err != nil {
 		return err
 	}
 	if _, err := p.SSHCommand(fmt.Sprintf("sudo diff -u %s %s.new || { sudo mv %s.new %s; sudo systemctl -f daemon-reload && sudo systemctl -f enable %s && sudo systemctl -f restart %s; }", dst, dst, dst, dst, name, name)); err != nil {
@@ -160,7 +160,7 @@ Restart=on-failure
 # container runtimes. If left unlimited, it may result in OOM issues with MySQL.
 ExecStart=
 ExecStart=/usr/bin/dockerd -H tcp://0.0.0.0:2376 -H unix:///var/run/docker.sock --default-ulimit=nofile=1048576:1048576 --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
-ExecReload=/bin/kill -s HUP \$MAINPID
+ExecReload=/bin/kill -s HUP $MAINPID
 
 # Having non-zero Limit*s causes performance problems due to accounting overhead
 # in the kernel. We recommend using cgroups to do container-local accounting.
@@ -190,7 +190,7 @@ WantedBy=multi-user.target
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   p.AuthOptions,
-		EngineOptions: p.EngineOptions,
+		EngineOptions: engineFlagOptions(p.EngineOptions),
 	}
 
 	escapeSystemdDirectives(&engineConfigContext)
@@ -200,6 +200,16 @@ WantedBy=multi-user.target
 	do := &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: "/lib/systemd/system/docker.service",
+		DaemonConfig:      newDaemonConfig(p.EngineOptions, defaultDaemonConfigPath),
+	}
+
+	// updateUnit restarts docker, daemon.json must be there by then. It is
+	// not written again by ConfigureAuth.
+	if do.DaemonConfig != nil {
+		if err := configureDaemonConfig(p, do.DaemonConfig); err != nil {
+			return nil, err
+		}
+		do.DaemonConfig = nil
 	}
 
 	return do, updateUnit(p, "docker", do.EngineOptions, do.EngineOptionsPath)
@@ -242,7 +252,7 @@ You also might want to clear any VirtualBox host only interfaces you are not usi
 	}
 }
 
-func (p *BuildRootProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+func (p *BuildRootProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) error {
 	var (
 		err error
 	)
@@ -262,6 +272,10 @@ func (p *BuildRootProvisioner) Provision(swarmOptions swarm.Options, authOptions
 		p.EngineOptions.StorageDriver = "overlay2"
 	}
 
+	if err = runProvisionScript(p, preProvisionScript, scripts.PreProvision); err != nil {
+		return err
+	}
+
 	if err = p.SetHostname(p.Driver.GetMachineName()); err != nil {
 		return err
 	}
@@ -289,7 +303,15 @@ func (p *BuildRootProvisioner) Provision(swarmOptions swarm.Options, authOptions
 }
 
 func (p *BuildRootProvisioner) SSHCommand(args string) (string, error) {
-	return drivers.RunSSHCommandFromDriver(p.Driver, args)
+	return p.SSHCommander.SSHCommand(args)
+}
+
+func (p *BuildRootProvisioner) SSHUpload(content []byte, dst string, mode os.FileMode, owner string) error {
+	return p.SSHCommander.SSHUpload(content, dst, mode, owner)
+}
+
+func (p *BuildRootProvisioner) SSHStream(args string, logLine func(string)) error {
+	return p.SSHCommander.SSHStream(args, logLine)
 }
 
 func (p *BuildRootProvisioner) GetDriver() drivers.Driver {
@@ -0,0 +1,158 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"text/template"
+	"time"
+
+	"github.com/leoh0/machine/libmachine/auth"
+	"github.com/leoh0/machine/libmachine/engine"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/mcnutils"
+	"github.com/leoh0/machine/libmachine/provision/serviceaction"
+	"github.com/leoh0/machine/libmachine/swarm"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The machines provisioned with cloud-init are set up like the systemd
+	// provisioners do over SSH.
+	cloudInitDockerDir     = "/etc/docker"
+	cloudInitOptionsFile   = "/etc/systemd/system/docker.service.d/10-machine.conf"
+	cloudInitStorageDriver = "overlay2"
+
+	cloudInitBootFinished = "/var/lib/cloud/instance/boot-finished"
+	cloudInitOutput       = "/var/log/cloud-init-output.log"
+)
+
+type cloudConfig struct {
+	Hostname       string            `yaml:"hostname"`
+	ManageEtcHosts bool              `yaml:"manage_etc_hosts"`
+	Packages       []string          `yaml:"packages"`
+	WriteFiles     []cloudConfigFile `yaml:"write_files"`
+	RunCmd         []string          `yaml:"runcmd"`
+}
+
+type cloudConfigFile struct {
+	Path        string `yaml:"path"`
+	Permissions string `yaml:"permissions"`
+	Content     string `yaml:"content"`
+}
+
+// GenerateCloudInit renders the cloud-config user-data which sets the
+// hostname, installs the engine and writes its options, to create a machine
+// of the given driver with. The image booted must run cloud-init and
+// systemd, like the default images of the cloud drivers.
+//
+// The CA certificate is written by cloud-init, but the server certificate is
+// issued for the IP address of the machine, which is unknown until it is
+// created; it is installed over SSH by VerifyCloudInit.
+func GenerateCloudInit(driverName, hostname string, authOptions auth.Options, engineOptions engine.Options) ([]byte, error) {
+	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the CA certificate: %s", err)
+	}
+
+	engineOptions.Labels = append(append([]string{}, engineOptions.Labels...), fmt.Sprintf("provider=%s", driverName))
+	if engineOptions.StorageDriver == "" {
+		engineOptions.StorageDriver = cloudInitStorageDriver
+	}
+
+	t, err := template.New("engineConfig").Parse(systemdEngineConfigTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	authOptions = withRemoteCertPaths(authOptions, cloudInitDockerDir)
+
+	var engineCfg bytes.Buffer
+	if err := t.Execute(&engineCfg, EngineConfigContext{
+		DockerPort:    engine.DefaultPort,
+		AuthOptions:   authOptions,
+		EngineOptions: engineFlagOptions(engineOptions),
+	}); err != nil {
+		return nil, err
+	}
+
+	config := cloudConfig{
+		Hostname:       hostname,
+		ManageEtcHosts: true,
+		Packages:       []string{"curl"},
+		WriteFiles: []cloudConfigFile{
+			{
+				Path:        cloudInitOptionsFile,
+				Permissions: "0644",
+				Content:     engineCfg.String(),
+			},
+			{
+				Path:        authOptions.CaCertRemotePath,
+				Permissions: "0644",
+				Content:     string(caCert),
+			},
+		},
+		RunCmd: []string{
+			fmt.Sprintf("if ! type docker; then curl -sSL %s | sh -; fi", engineOptions.InstallURL),
+			"systemctl daemon-reload",
+			"systemctl enable docker",
+		},
+	}
+
+	if daemonConfig := newDaemonConfig(engineOptions, defaultDaemonConfigPath); daemonConfig != nil {
+		content, err := daemonConfig.Merge("")
+		if err != nil {
+			return nil, err
+		}
+		config.WriteFiles = append(config.WriteFiles, cloudConfigFile{
+			Path:        daemonConfig.Path,
+			Permissions: "0644",
+			Content:     string(content),
+		})
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte("#cloud-config\n"), data...), nil
+}
+
+// VerifyCloudInit finishes the provisioning of a machine created with the
+// user-data of GenerateCloudInit. It waits for cloud-init to be done, and
+// installs the certificates before restarting the engine. The scripts run
+// around those steps, like in Provision.
+func VerifyCloudInit(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options, scripts Scripts) error {
+	log.Info("Waiting for cloud-init to provision the machine...")
+
+	if err := mcnutils.WaitForSpecific(func() bool {
+		_, err := p.SSHCommand("test -f " + cloudInitBootFinished)
+		return err == nil
+	}, 100, 3*time.Second); err != nil {
+		return fmt.Errorf("Error waiting for cloud-init: %s", err)
+	}
+
+	if _, err := DockerClientVersion(p); err != nil {
+		return fmt.Errorf("Error: Docker was not installed by cloud-init, see %s on the machine: %s", cloudInitOutput, err)
+	}
+
+	if err := runProvisionScript(p, preProvisionScript, scripts.PreProvision); err != nil {
+		return err
+	}
+
+	authOptions = withRemoteCertPaths(authOptions, cloudInitDockerDir)
+	if err := installCerts(p, authOptions, swarmOptions); err != nil {
+		return err
+	}
+
+	if err := p.Service("docker", serviceaction.Restart); err != nil {
+		return err
+	}
+
+	if err := WaitForDocker(p, engine.DefaultPort); err != nil {
+		return err
+	}
+
+	return configureSwarm(p, swarmOptions, authOptions)
+}
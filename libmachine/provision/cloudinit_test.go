@@ -0,0 +1,76 @@
+package provision
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leoh0/machine/libmachine/auth"
+	"github.com/leoh0/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func cloudInitAuthOptions(t *testing.T) auth.Options {
+	caCertPath := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caCertPath, []byte("CA CERTIFICATE\n"), 0644))
+
+	return auth.Options{CaCertPath: caCertPath}
+}
+
+func TestGenerateCloudInit(t *testing.T) {
+	engineOptions := engine.Options{
+		Labels:     []string{"env=test"},
+		InstallURL: "https://get.docker.com",
+	}
+
+	userData, err := GenerateCloudInit("amazonec2", "foo", cloudInitAuthOptions(t), engineOptions)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(userData), "#cloud-config\n"))
+
+	config := cloudConfig{}
+	assert.NoError(t, yaml.Unmarshal(userData, &config))
+	assert.Equal(t, "foo", config.Hostname)
+	assert.Equal(t, []string{"curl"}, config.Packages)
+	assert.Contains(t, config.RunCmd, "if ! type docker; then curl -sSL https://get.docker.com | sh -; fi")
+
+	assert.Len(t, config.WriteFiles, 2)
+	unit := config.WriteFiles[0]
+	assert.Equal(t, cloudInitOptionsFile, unit.Path)
+	assert.Contains(t, unit.Content, "--storage-driver overlay2")
+	assert.Contains(t, unit.Content, "--tlscacert /etc/docker/ca.pem --tlscert /etc/docker/server.pem --tlskey /etc/docker/server-key.pem")
+	assert.Contains(t, unit.Content, "--label env=test --label provider=amazonec2")
+
+	caCert := config.WriteFiles[1]
+	assert.Equal(t, "/etc/docker/ca.pem", caCert.Path)
+	assert.Equal(t, "0644", caCert.Permissions)
+	assert.Equal(t, "CA CERTIFICATE\n", caCert.Content)
+
+	assert.Equal(t, []string{"env=test"}, engineOptions.Labels)
+}
+
+func TestGenerateCloudInitDaemonJSON(t *testing.T) {
+	engineOptions := engine.Options{
+		DaemonJSON: true,
+		Labels:     []string{"env=test"},
+		LogDriver:  "journald",
+	}
+
+	userData, err := GenerateCloudInit("google", "foo", cloudInitAuthOptions(t), engineOptions)
+	assert.NoError(t, err)
+
+	config := cloudConfig{}
+	assert.NoError(t, yaml.Unmarshal(userData, &config))
+	assert.Len(t, config.WriteFiles, 3)
+	assert.NotContains(t, config.WriteFiles[0].Content, "--label")
+
+	daemonJSON := config.WriteFiles[2]
+	assert.Equal(t, defaultDaemonConfigPath, daemonJSON.Path)
+	assert.JSONEq(t, `{"labels": ["env=test", "provider=google"], "log-driver": "journald"}`, daemonJSON.Content)
+}
+
+func TestGenerateCloudInitWithoutCACert(t *testing.T) {
+	_, err := GenerateCloudInit("amazonec2", "foo", auth.Options{CaCertPath: filepath.Join(t.TempDir(), "ca.pem")}, engine.Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/ssh"
+	"github.com/leoh0/machine/libmachine/swarm"
+)
+
+// ConfigureSwarmMode makes the machine a node of a swarm mode cluster, with
+// the role given by swarmOptions. It creates a new cluster, or joins the
+// manager at swarmOptions.ModeJoin. A machine already in a cluster is left
+// as is. The join tokens of managers are stored in swarmOptions, for the
+// machines joining later.
+func ConfigureSwarmMode(p Provisioner, swarmOptions *swarm.Options) error {
+	if swarmOptions.ModeRole == "" {
+		return nil
+	}
+
+	ip, err := p.GetDriver().GetIP()
+	if err != nil {
+		return err
+	}
+
+	return configureSwarmMode(p, ip, swarmOptions)
+}
+
+func configureSwarmMode(p SSHCommander, ip string, swarmOptions *swarm.Options) error {
+	out, err := p.SSHCommand("sudo docker info --format '{{.Swarm.LocalNodeState}}'")
+	if err != nil {
+		return fmt.Errorf("Error getting the swarm mode state: %s", err)
+	}
+
+	if strings.TrimSpace(out) != "active" {
+		cmd, err := swarmModeCommand(ip, swarmOptions)
+		if err != nil {
+			return err
+		}
+
+		if out, err := p.SSHCommand(cmd); err != nil {
+			return fmt.Errorf("Error configuring swarm mode: %s\n%s", err, out)
+		}
+	}
+
+	if swarmOptions.ModeRole != swarm.ModeManager {
+		return nil
+	}
+
+	// Only the managers can tell the join tokens.
+	tokens := map[string]*string{
+		swarm.ModeManager: &swarmOptions.ModeManagerToken,
+		swarm.ModeWorker:  &swarmOptions.ModeWorkerToken,
+	}
+	for role, token := range tokens {
+		out, err := p.SSHCommand("sudo docker swarm join-token -q " + role)
+		if err != nil {
+			return fmt.Errorf("Error getting the swarm mode %s join token: %s", role, err)
+		}
+		*token = strings.TrimSpace(out)
+	}
+
+	return nil
+}
+
+// swarmModeCommand returns the command creating or joining the swarm mode
+// cluster.
+func swarmModeCommand(ip string, swarmOptions *swarm.Options) (string, error) {
+	switch swarmOptions.ModeRole {
+	case swarm.ModeManager, swarm.ModeWorker:
+	default:
+		return "", fmt.Errorf("Error: Unknown swarm mode role %q", swarmOptions.ModeRole)
+	}
+
+	if swarmOptions.ModeJoin == "" {
+		if swarmOptions.ModeRole != swarm.ModeManager {
+			return "", fmt.Errorf("Error: A swarm mode worker needs a manager to join")
+		}
+
+		log.Info("Creating a swarm mode cluster...")
+		return fmt.Sprintf("sudo docker swarm init --advertise-addr %s", ip), nil
+	}
+
+	token := swarmOptions.ModeWorkerToken
+	if swarmOptions.ModeRole == swarm.ModeManager {
+		token = swarmOptions.ModeManagerToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("Error: No %s join token for the swarm mode cluster of %s", swarmOptions.ModeRole, swarmOptions.ModeJoin)
+	}
+
+	log.Infof("Joining the swarm mode cluster of %s as a %s...", swarmOptions.ModeJoin, swarmOptions.ModeRole)
+	return fmt.Sprintf("sudo docker swarm join --token %s --advertise-addr %s %s", ssh.ShellQuote(token), ip, ssh.ShellQuote(swarmOptions.ModeJoin)), nil
+}
@@ -0,0 +1,65 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/leoh0/machine/libmachine/provision/provisiontest"
+	"github.com/leoh0/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+const swarmModeStateCommand = "sudo docker info --format '{{.Swarm.LocalNodeState}}'"
+
+func TestConfigureSwarmModeInit(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			swarmModeStateCommand:                              "inactive\n",
+			"sudo docker swarm init --advertise-addr 10.0.0.5": "Swarm initialized\n",
+			"sudo docker swarm join-token -q manager":          "SWMTKN-manager\n",
+			"sudo docker swarm join-token -q worker":           "SWMTKN-worker\n",
+		},
+	}
+	swarmOptions := &swarm.Options{ModeRole: swarm.ModeManager}
+
+	assert.NoError(t, configureSwarmMode(commander, "10.0.0.5", swarmOptions))
+	assert.Equal(t, "SWMTKN-manager", swarmOptions.ModeManagerToken)
+	assert.Equal(t, "SWMTKN-worker", swarmOptions.ModeWorkerToken)
+}
+
+func TestConfigureSwarmModeJoin(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			swarmModeStateCommand: "inactive\n",
+			"sudo docker swarm join --token 'SWMTKN-worker' --advertise-addr 10.0.0.6 '10.0.0.5:2377'": "This node joined a swarm as a worker.\n",
+		},
+	}
+	swarmOptions := &swarm.Options{
+		ModeRole:        swarm.ModeWorker,
+		ModeJoin:        "10.0.0.5:2377",
+		ModeWorkerToken: "SWMTKN-worker",
+	}
+
+	assert.NoError(t, configureSwarmMode(commander, "10.0.0.6", swarmOptions))
+}
+
+func TestConfigureSwarmModeAlreadyActive(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			swarmModeStateCommand: "active\n",
+		},
+	}
+	swarmOptions := &swarm.Options{ModeRole: swarm.ModeWorker, ModeJoin: "10.0.0.5:2377"}
+
+	assert.NoError(t, configureSwarmMode(commander, "10.0.0.6", swarmOptions))
+}
+
+func TestSwarmModeCommandErrors(t *testing.T) {
+	for _, swarmOptions := range []*swarm.Options{
+		{ModeRole: "leader"},
+		{ModeRole: swarm.ModeWorker},
+		{ModeRole: swarm.ModeManager, ModeJoin: "10.0.0.5:2377", ModeWorkerToken: "SWMTKN-worker"},
+	} {
+		_, err := swarmModeCommand("10.0.0.6", swarmOptions)
+		assert.Error(t, err)
+	}
+}
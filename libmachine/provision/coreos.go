@@ -15,11 +15,9 @@ import (
 )
 
 const (
-	hostTmpl = `sudo tee /var/tmp/hostname.yml << EOF
-#cloud-config
+	hostTmpl = `#cloud-config
 
 hostname: %s
-EOF
 `
 )
 
@@ -46,7 +44,7 @@ func (provisioner *CoreOSProvisioner) String() string {
 func (provisioner *CoreOSProvisioner) SetHostname(hostname string) error {
 	log.Debugf("SetHostname: %s", hostname)
 
-	if _, err := provisioner.SSHCommand(fmt.Sprintf(hostTmpl, hostname)); err != nil {
+	if err := provisioner.SSHUpload([]byte(fmt.Sprintf(hostTmpl, hostname)), "/var/tmp/hostname.yml", 0644, ""); err != nil {
 		return err
 	}
 
@@ -78,7 +76,7 @@ func (provisioner *CoreOSProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 	engineConfigTmpl := `[Service]
 Environment=TMPDIR=/var/tmp
 ExecStart=
-ExecStart=/usr/lib/coreos/dockerd ` + arg + ` --host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:{{.DockerPort}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}{{ range .EngineOptions.Labels }} --label {{.}}{{ end }}{{ range .EngineOptions.InsecureRegistry }} --insecure-registry {{.}}{{ end }}{{ range .EngineOptions.RegistryMirror }} --registry-mirror {{.}}{{ end }}{{ range .EngineOptions.ArbitraryFlags }} --{{.}}{{ end }} \$DOCKER_OPTS \$DOCKER_OPT_BIP \$DOCKER_OPT_MTU \$DOCKER_OPT_IPMASQ
+ExecStart=/usr/lib/coreos/dockerd ` + arg + ` --host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:{{.DockerPort}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}{{ range .EngineOptions.Labels }} --label {{.}}{{ end }}{{ range .EngineOptions.InsecureRegistry }} --insecure-registry {{.}}{{ end }}{{ range .EngineOptions.RegistryMirror }} --registry-mirror {{.}}{{ end }}{{ range .EngineOptions.ArbitraryFlags }} --{{.}}{{ end }} $DOCKER_OPTS $DOCKER_OPT_BIP $DOCKER_OPT_MTU $DOCKER_OPT_IPMASQ
 Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
 `
 
@@ -90,7 +88,7 @@ Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   provisioner.AuthOptions,
-		EngineOptions: provisioner.EngineOptions,
+		EngineOptions: engineFlagOptions(provisioner.EngineOptions),
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -98,6 +96,7 @@ Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		DaemonConfig:      newDaemonConfig(provisioner.EngineOptions, defaultDaemonConfigPath),
 	}, nil
 }
 
@@ -105,11 +104,15 @@ func (provisioner *CoreOSProvisioner) Package(name string, action pkgaction.Pack
 	return nil
 }
 
-func (provisioner *CoreOSProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+func (provisioner *CoreOSProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
 
+	if err := runProvisionScript(provisioner, preProvisionScript, scripts.PreProvision); err != nil {
+		return err
+	}
+
 	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
 		return err
 	}
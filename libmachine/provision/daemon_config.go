@@ -0,0 +1,197 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/leoh0/machine/libmachine/engine"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/ssh"
+)
+
+const defaultDaemonConfigPath = "/etc/docker/daemon.json"
+
+// daemonFlagKeys are the daemon.json keys of the settings always passed as
+// flags to the daemon, which refuses to start when a setting is in both.
+var daemonFlagKeys = []string{"hosts", "storage-driver", "tls", "tlsverify", "tlscacert", "tlscert", "tlskey"}
+
+// DaemonConfig is a daemon.json on the machine, and the engine options to
+// merge into it.
+type DaemonConfig struct {
+	Path          string
+	EngineOptions engine.Options
+}
+
+// newDaemonConfig returns the daemon.json to write at path, or nil when the
+// engine options are passed as flags.
+func newDaemonConfig(opts engine.Options, path string) *DaemonConfig {
+	if !opts.DaemonJSON {
+		return nil
+	}
+
+	return &DaemonConfig{
+		Path:          path,
+		EngineOptions: opts,
+	}
+}
+
+// engineFlagOptions returns the engine options to render the flags of the
+// daemon from. The settings written to daemon.json are left out of them,
+// and the ones the templates have no dedicated flag for are passed as
+// arbitrary flags.
+func engineFlagOptions(opts engine.Options) engine.Options {
+	flagOpts := opts
+
+	if opts.DaemonJSON {
+		flagOpts.Labels = nil
+		flagOpts.InsecureRegistry = nil
+		flagOpts.RegistryMirror = nil
+		return flagOpts
+	}
+
+	flags := append([]string{}, opts.ArbitraryFlags...)
+	if opts.LogDriver != "" {
+		flags = append(flags, "log-driver="+opts.LogDriver)
+	}
+	for _, o := range opts.LogOpts {
+		flags = append(flags, "log-opt="+o)
+	}
+	if opts.CgroupDriver != "" {
+		flags = append(flags, "exec-opt=native.cgroupdriver="+opts.CgroupDriver)
+	}
+	for _, pool := range opts.DefaultAddressPools {
+		flags = append(flags, "default-address-pool="+pool.String())
+	}
+	flagOpts.ArbitraryFlags = flags
+
+	return flagOpts
+}
+
+// configureDaemonConfig merges the engine options into the daemon.json of
+// the machine.
+func configureDaemonConfig(p SSHCommander, c *DaemonConfig) error {
+	existing, err := p.SSHCommand(readDaemonConfigCommand(c.Path))
+	if err != nil {
+		return fmt.Errorf("Error reading %s: %s", c.Path, err)
+	}
+
+	content, err := c.Merge(existing)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Updating %s...", c.Path)
+	return p.SSHUpload(content, c.Path, 0644, "")
+}
+
+func readDaemonConfigCommand(path string) string {
+	return fmt.Sprintf("if [ -f %[1]s ]; then sudo cat %[1]s; fi", ssh.ShellQuote(path))
+}
+
+// Merge returns the existing daemon.json with the engine options set. The
+// lists of labels, registries and exec options are extended, log options
+// are kept unless the log driver changes, and the settings passed as flags
+// are removed.
+func (c *DaemonConfig) Merge(existing string) ([]byte, error) {
+	settings := map[string]interface{}{}
+	if strings.TrimSpace(existing) != "" {
+		if err := json.Unmarshal([]byte(existing), &settings); err != nil {
+			return nil, fmt.Errorf("Error parsing %s: %s", c.Path, err)
+		}
+	}
+
+	opts := c.EngineOptions
+	appendSettings(settings, "labels", opts.Labels)
+	appendSettings(settings, "insecure-registries", opts.InsecureRegistry)
+	appendSettings(settings, "registry-mirrors", opts.RegistryMirror)
+
+	if opts.LogDriver != "" {
+		// The options of another log driver are unlikely to be valid for
+		// this one.
+		if driver, _ := settings["log-driver"].(string); driver != opts.LogDriver {
+			delete(settings, "log-opts")
+		}
+		settings["log-driver"] = opts.LogDriver
+	}
+
+	if len(opts.LogOpts) > 0 {
+		logOpts, ok := settings["log-opts"].(map[string]interface{})
+		if !ok {
+			logOpts = map[string]interface{}{}
+		}
+		for _, o := range opts.LogOpts {
+			kv := strings.SplitN(o, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("Invalid log option %q, expected key=value", o)
+			}
+			logOpts[kv[0]] = kv[1]
+		}
+		settings["log-opts"] = logOpts
+	}
+
+	if opts.CgroupDriver != "" {
+		execOpts := []interface{}{}
+		current, _ := settings["exec-opts"].([]interface{})
+		for _, o := range current {
+			if s, ok := o.(string); ok && strings.HasPrefix(s, "native.cgroupdriver=") {
+				continue
+			}
+			execOpts = append(execOpts, o)
+		}
+		settings["exec-opts"] = append(execOpts, "native.cgroupdriver="+opts.CgroupDriver)
+	}
+
+	// The pools are replaced rather than extended, they could overlap.
+	if len(opts.DefaultAddressPools) > 0 {
+		pools := []interface{}{}
+		for _, pool := range opts.DefaultAddressPools {
+			pools = append(pools, map[string]interface{}{
+				"base": pool.Base,
+				"size": pool.Size,
+			})
+		}
+		settings["default-address-pools"] = pools
+	}
+
+	flagKeys := append([]string{}, daemonFlagKeys...)
+	for _, f := range opts.ArbitraryFlags {
+		flagKeys = append(flagKeys, strings.SplitN(f, "=", 2)[0])
+	}
+	for _, key := range flagKeys {
+		if _, ok := settings[key]; ok {
+			log.Warnf("Removing %q from %s, it is set by a flag of the daemon", key, c.Path)
+			delete(settings, key)
+		}
+	}
+
+	data, err := json.MarshalIndent(settings, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// appendSettings adds the values missing from the list setting key.
+func appendSettings(settings map[string]interface{}, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	list, _ := settings[key].([]interface{})
+	present := map[string]bool{}
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			present[s] = true
+		}
+	}
+
+	for _, v := range values {
+		if !present[v] {
+			list = append(list, v)
+			present[v] = true
+		}
+	}
+	settings[key] = list
+}
@@ -0,0 +1,183 @@
+package provision
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/engine"
+	"github.com/leoh0/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineFlagOptions(t *testing.T) {
+	opts := engine.Options{
+		ArbitraryFlags:      []string{"debug"},
+		Labels:              []string{"a=b"},
+		LogDriver:           "json-file",
+		LogOpts:             []string{"max-size=10m"},
+		CgroupDriver:        "systemd",
+		DefaultAddressPools: []engine.AddressPool{{Base: "10.10.0.0/16", Size: 24}},
+	}
+
+	flagOpts := engineFlagOptions(opts)
+	assert.Equal(t, []string{
+		"debug",
+		"log-driver=json-file",
+		"log-opt=max-size=10m",
+		"exec-opt=native.cgroupdriver=systemd",
+		"default-address-pool=base=10.10.0.0/16,size=24",
+	}, flagOpts.ArbitraryFlags)
+	assert.Equal(t, []string{"a=b"}, flagOpts.Labels)
+	assert.Equal(t, []string{"debug"}, opts.ArbitraryFlags)
+
+	opts.DaemonJSON = true
+	flagOpts = engineFlagOptions(opts)
+	assert.Equal(t, []string{"debug"}, flagOpts.ArbitraryFlags)
+	assert.Nil(t, flagOpts.Labels)
+}
+
+func TestNewDaemonConfig(t *testing.T) {
+	assert.Nil(t, newDaemonConfig(engine.Options{}, defaultDaemonConfigPath))
+
+	c := newDaemonConfig(engine.Options{DaemonJSON: true}, defaultDaemonConfigPath)
+	assert.Equal(t, defaultDaemonConfigPath, c.Path)
+}
+
+func TestDaemonConfigMerge(t *testing.T) {
+	c := &DaemonConfig{
+		Path: defaultDaemonConfigPath,
+		EngineOptions: engine.Options{
+			DaemonJSON:          true,
+			ArbitraryFlags:      []string{"debug"},
+			Labels:              []string{"provider=generic", "a=b"},
+			RegistryMirror:      []string{"https://mirror.example.com"},
+			LogOpts:             []string{"max-size=10m"},
+			CgroupDriver:        "systemd",
+			DefaultAddressPools: []engine.AddressPool{{Base: "10.10.0.0/16", Size: 24}},
+		},
+	}
+
+	existing := `{
+  "labels": ["a=b", "zone=1"],
+  "log-driver": "json-file",
+  "log-opts": {"max-file": "3"},
+  "exec-opts": ["native.cgroupdriver=cgroupfs", "native.umask=normal"],
+  "default-address-pools": [{"base": "172.80.0.0/16", "size": 24}],
+  "hosts": ["unix:///var/run/docker.sock"],
+  "debug": true,
+  "data-root": "/data/docker"
+}`
+
+	content, err := c.Merge(existing)
+	assert.NoError(t, err)
+
+	settings := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(content, &settings))
+	assert.Equal(t, map[string]interface{}{
+		"labels":           []interface{}{"a=b", "zone=1", "provider=generic"},
+		"registry-mirrors": []interface{}{"https://mirror.example.com"},
+		"log-driver":       "json-file",
+		"log-opts":         map[string]interface{}{"max-file": "3", "max-size": "10m"},
+		"exec-opts":        []interface{}{"native.umask=normal", "native.cgroupdriver=systemd"},
+		"default-address-pools": []interface{}{
+			map[string]interface{}{"base": "10.10.0.0/16", "size": float64(24)},
+		},
+		"data-root": "/data/docker",
+	}, settings)
+}
+
+func TestDaemonConfigMergeLogDriverChange(t *testing.T) {
+	c := &DaemonConfig{
+		Path:          defaultDaemonConfigPath,
+		EngineOptions: engine.Options{LogDriver: "journald"},
+	}
+
+	content, err := c.Merge(`{"log-driver": "json-file", "log-opts": {"max-size": "10m"}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n    \"log-driver\": \"journald\"\n}\n", string(content))
+}
+
+func TestDaemonConfigMergeErrors(t *testing.T) {
+	c := &DaemonConfig{Path: defaultDaemonConfigPath}
+
+	_, err := c.Merge("{")
+	assert.Error(t, err)
+
+	c.EngineOptions.LogOpts = []string{"max-size"}
+	_, err = c.Merge("")
+	assert.Error(t, err)
+}
+
+func TestConfigureDaemonConfig(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"if [ -f '/etc/docker/daemon.json' ]; then sudo cat '/etc/docker/daemon.json'; fi": `{"data-root": "/data/docker"}`,
+		},
+	}
+	c := &DaemonConfig{
+		Path:          defaultDaemonConfigPath,
+		EngineOptions: engine.Options{DaemonJSON: true, CgroupDriver: "systemd"},
+	}
+
+	assert.NoError(t, configureDaemonConfig(commander, c))
+
+	upload := commander.Uploads[defaultDaemonConfigPath]
+	assert.Equal(t, 0644, int(upload.Mode))
+	assert.JSONEq(t, `{"data-root": "/data/docker", "exec-opts": ["native.cgroupdriver=systemd"]}`, string(upload.Content))
+}
+
+// orderedSSHCommander records the commands and uploads in the order they
+// are made.
+type orderedSSHCommander struct {
+	events []string
+}
+
+func (o *orderedSSHCommander) SSHCommand(args string) (string, error) {
+	o.events = append(o.events, "run "+args)
+	return "", nil
+}
+
+func (o *orderedSSHCommander) SSHUpload(content []byte, dst string, mode os.FileMode, owner string) error {
+	o.events = append(o.events, "upload "+dst)
+	return nil
+}
+
+func (o *orderedSSHCommander) SSHStream(args string, logLine func(string)) error {
+	o.events = append(o.events, "run "+args)
+	return nil
+}
+
+func TestBuildRootWritesDaemonConfigBeforeRestart(t *testing.T) {
+	commander := &orderedSSHCommander{}
+	p := NewBuildRootProvisioner(&fakedriver.Driver{}).(*BuildRootProvisioner)
+	p.SSHCommander = commander
+	p.EngineOptions = engine.Options{DaemonJSON: true, Labels: []string{"env=test"}}
+
+	dockerOptions, err := p.GenerateDockerOptions(2376)
+	assert.NoError(t, err)
+	assert.Nil(t, dockerOptions.DaemonConfig)
+
+	daemonConfig, restart := -1, -1
+	for i, event := range commander.events {
+		if event == "upload "+defaultDaemonConfigPath {
+			daemonConfig = i
+		}
+		if strings.HasPrefix(event, "run ") && strings.Contains(event, "systemctl -f restart docker") {
+			restart = i
+		}
+	}
+	assert.NotEqual(t, -1, daemonConfig)
+	assert.True(t, daemonConfig < restart, "daemon.json must be written before docker restarts: %v", commander.events)
+}
+
+func TestRancherRejectsDaemonConfig(t *testing.T) {
+	p := NewRancherProvisioner(&fakedriver.Driver{}).(*RancherProvisioner)
+	p.EngineOptions = engine.Options{DaemonJSON: true}
+
+	_, err := p.GenerateDockerOptions(2376)
+
+	assert.Equal(t, errRancherDaemonJSON, err)
+}
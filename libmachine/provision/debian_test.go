@@ -13,7 +13,7 @@ import (
 func TestDebianDefaultStorageDriver(t *testing.T) {
 	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
 	p.SSHCommander = provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
-	p.Provision(swarm.Options{}, auth.Options{}, engine.Options{})
+	p.Provision(swarm.Options{}, auth.Options{}, engine.Options{}, Scripts{})
 	if p.EngineOptions.StorageDriver != "overlay2" {
 		t.Fatal("Default storage driver should be overlay2")
 	}
@@ -0,0 +1,283 @@
+package provision
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/leoh0/machine/libmachine/auth"
+	"github.com/leoh0/machine/libmachine/engine"
+	"github.com/leoh0/machine/libmachine/provision/pkgaction"
+	"github.com/leoh0/machine/libmachine/provision/serviceaction"
+	"github.com/leoh0/machine/libmachine/swarm"
+)
+
+// RecordingSSHCommander is an SSHCommander which records the commands and
+// uploads given to it instead of running them on the machine. The commands
+// in Responses are answered with their response, the others succeed with
+// no output.
+type RecordingSSHCommander struct {
+	Responses map[string]string
+	Commands  []string
+	Uploads   []RecordedUpload
+}
+
+// RecordedUpload is a file given to RecordingSSHCommander.SSHUpload.
+type RecordedUpload struct {
+	Dst     string
+	Content []byte
+	Mode    os.FileMode
+	Owner   string
+}
+
+func (r *RecordingSSHCommander) SSHCommand(args string) (string, error) {
+	r.Commands = append(r.Commands, args)
+	return r.Responses[args], nil
+}
+
+func (r *RecordingSSHCommander) SSHUpload(content []byte, dst string, mode os.FileMode, owner string) error {
+	r.Uploads = append(r.Uploads, RecordedUpload{
+		Dst:     dst,
+		Content: content,
+		Mode:    mode,
+		Owner:   owner,
+	})
+	return nil
+}
+
+// SSHStream records args with the commands, it has no output.
+func (r *RecordingSSHCommander) SSHStream(args string, logLine func(string)) error {
+	r.Commands = append(r.Commands, args)
+	return nil
+}
+
+// ProvisionPlan is what provisioning a machine would do, as found by
+// DryRunProvision.
+type ProvisionPlan struct {
+	Provisioner   string
+	DockerOptions *DockerOptions
+	Packages      []string
+	// Certificates are the certificates to generate, with the hosts they
+	// are valid for.
+	Certificates []string
+	Services     []string
+	Scripts      []string
+	// Commands and Uploads are what would be run and written on the
+	// machine, in order.
+	Commands []string
+	Uploads  []RecordedUpload
+}
+
+// optionsProvisioner is implemented by the provisioners which can be set up
+// with their options without provisioning the machine, to dry run them or
+// to configure their certificates alone.
+type optionsProvisioner interface {
+	// setOptions sets the provisioner up with the options like Provision
+	// does. It returns the packages Provision installs.
+	setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) []string
+
+	// setSSHCommander makes the provisioner run its SSH commands with
+	// sshCmder.
+	setSSHCommander(sshCmder SSHCommander)
+}
+
+// DryRunProvision tells what Provision would do on the machine of p,
+// without changing the machine nor the local files. The commands of p are
+// recorded by a RecordingSSHCommander, p must not be used to provision the
+// machine afterwards.
+func DryRunProvision(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) (*ProvisionPlan, error) {
+	op, ok := p.(optionsProvisioner)
+	if !ok {
+		return nil, fmt.Errorf("Error: The %s provisioner does not support dry runs", p.String())
+	}
+
+	// The state of the machine read by the provisioning is read before
+	// recording the commands.
+	recorder := &RecordingSSHCommander{Responses: map[string]string{}}
+	queries := []string{
+		"docker --version",
+		readDaemonConfigCommand(defaultDaemonConfigPath),
+		readDaemonConfigCommand(path.Join(p.GetDockerOptionsDir(), "daemon.json")),
+	}
+	for _, query := range queries {
+		if out, err := p.SSHCommand(query); err == nil {
+			recorder.Responses[query] = out
+		}
+	}
+
+	op.setSSHCommander(recorder)
+	plan := &ProvisionPlan{
+		Provisioner: p.String(),
+		Packages:    op.setOptions(swarmOptions, authOptions, engineOptions),
+	}
+
+	if scripts.PreProvision != "" {
+		plan.Scripts = append(plan.Scripts, fmt.Sprintf("%s: %s", preProvisionScript, scripts.PreProvision))
+	}
+
+	if err := p.SetHostname(p.GetDriver().GetMachineName()); err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range plan.Packages {
+		if err := p.Package(pkg, pkgaction.Install); err != nil {
+			return nil, err
+		}
+	}
+
+	certificate, err := serverCertPlan(p)
+	if err != nil {
+		return nil, err
+	}
+	plan.Certificates = append(plan.Certificates, certificate)
+
+	dockerPort, err := enginePort(p)
+	if err != nil {
+		return nil, err
+	}
+
+	before := len(recorder.Uploads)
+	dockerOptions, err := p.GenerateDockerOptions(dockerPort)
+	if err != nil {
+		return nil, fmt.Errorf("Error generating the docker options: %s", err)
+	}
+	plan.DockerOptions = dockerOptions
+
+	// Some provisioners, like buildroot, already write the engine options
+	// in GenerateDockerOptions.
+	written := recorder.Uploads[before:]
+	if dockerOptions != nil {
+		if !uploaded(written, dockerOptions.EngineOptionsPath) {
+			if err := recorder.SSHUpload([]byte(dockerOptions.EngineOptions), dockerOptions.EngineOptionsPath, 0644, ""); err != nil {
+				return nil, err
+			}
+		}
+
+		if c := dockerOptions.DaemonConfig; c != nil {
+			content, err := c.Merge(recorder.Responses[readDaemonConfigCommand(c.Path)])
+			if err != nil {
+				return nil, err
+			}
+			if err := recorder.SSHUpload(content, c.Path, 0644, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := p.Service("docker", serviceaction.Restart); err != nil {
+		return nil, err
+	}
+	plan.Services = append(plan.Services, "docker")
+
+	if scripts.PostProvision != "" {
+		plan.Scripts = append(plan.Scripts, fmt.Sprintf("%s: %s", postProvisionScript, scripts.PostProvision))
+	}
+
+	plan.Commands = recorder.Commands
+	plan.Uploads = recorder.Uploads
+
+	return plan, nil
+}
+
+// uploaded tells if dst is among uploads, directly or as the new version
+// of a unit written by updateUnit.
+func uploaded(uploads []RecordedUpload, dst string) bool {
+	for _, u := range uploads {
+		if u.Dst == dst || u.Dst == dst+".new" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serverCertPlan tells the server certificate installCerts would generate,
+// and records the uploads of the certificates.
+func serverCertPlan(p Provisioner) (string, error) {
+	authOptions := p.GetAuthOptions()
+
+	ip, err := p.GetDriver().GetIP()
+	if err != nil {
+		return "", err
+	}
+	hosts := append(append([]string{}, authOptions.ServerCertSANs...), ip, "localhost")
+
+	certs := []struct {
+		dst  string
+		mode os.FileMode
+	}{
+		{authOptions.CaCertRemotePath, 0644},
+		{authOptions.ServerCertRemotePath, 0644},
+		{authOptions.ServerKeyRemotePath, 0600},
+	}
+	for _, c := range certs {
+		if err := p.SSHUpload(nil, c.dst, c.mode, ""); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%s for %v", authOptions.ServerCertPath, hosts), nil
+}
+
+func (provisioner *GenericProvisioner) setSSHCommander(sshCmder SSHCommander) {
+	provisioner.SSHCommander = sshCmder
+}
+
+func (provisioner *GenericProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) []string {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.EngineOptions = engineOptions
+	provisioner.AuthOptions = withRemoteCertPaths(authOptions, provisioner.DockerOptionsDir)
+
+	// Provision picks the default of the distribution, most use this one.
+	if provisioner.EngineOptions.StorageDriver == "" {
+		provisioner.EngineOptions.StorageDriver = "overlay2"
+	}
+
+	return provisioner.Packages
+}
+
+func (provisioner *Boot2DockerProvisioner) setSSHCommander(sshCmder SSHCommander) {
+	provisioner.sshCommander = sshCmder
+}
+
+func (provisioner *Boot2DockerProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) []string {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.EngineOptions = engineOptions
+	provisioner.AuthOptions = withRemoteCertPaths(authOptions, provisioner.GetDockerOptionsDir())
+
+	if provisioner.EngineOptions.StorageDriver == "" {
+		provisioner.EngineOptions.StorageDriver = "overlay2"
+	}
+
+	return nil
+}
+
+func (p *BuildRootProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) []string {
+	p.SystemdProvisioner.setOptions(swarmOptions, authOptions, engineOptions)
+	p.AuthOptions = withRemoteCertPaths(authOptions, p.GetDockerOptionsDir())
+
+	return nil
+}
+
+func (provisioner *CoreOSProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) []string {
+	provisioner.SystemdProvisioner.setOptions(swarmOptions, authOptions, engineOptions)
+
+	return nil
+}
+
+func (provisioner *RancherProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) []string {
+	if engineOptions.StorageDriver == "" {
+		engineOptions.StorageDriver = "overlay"
+	}
+
+	return provisioner.GenericProvisioner.setOptions(swarmOptions, authOptions, engineOptions)
+}
+
+func (provisioner *SUSEProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) []string {
+	// Provision uses btrfs instead on a btrfs /var/lib.
+	if engineOptions.StorageDriver == "" {
+		engineOptions.StorageDriver = "overlay"
+	}
+
+	return provisioner.SystemdProvisioner.setOptions(swarmOptions, authOptions, engineOptions)
+}
@@ -0,0 +1,113 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/auth"
+	"github.com/leoh0/machine/libmachine/engine"
+	"github.com/leoh0/machine/libmachine/provision/provisiontest"
+	"github.com/leoh0/machine/libmachine/state"
+	"github.com/leoh0/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func recordedUpload(plan *ProvisionPlan, dst string) (RecordedUpload, bool) {
+	for _, u := range plan.Uploads {
+		if u.Dst == dst {
+			return u, true
+		}
+	}
+
+	return RecordedUpload{}, false
+}
+
+func TestDryRunProvision(t *testing.T) {
+	d := &fakedriver.Driver{MockName: "foo", MockState: state.Running, MockIP: "1.2.3.4"}
+	p := NewUbuntuSystemdProvisioner(d).(*UbuntuSystemdProvisioner)
+	sshCmder := provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+	sshCmder.Responses["docker --version"] = "Docker version 20.10.7, build f0df350\n"
+	sshCmder.Responses["if [ -f '/etc/docker/daemon.json' ]; then sudo cat '/etc/docker/daemon.json'; fi"] = `{"debug": true}`
+	p.SSHCommander = sshCmder
+
+	plan, err := DryRunProvision(p, swarm.Options{}, auth.Options{
+		ServerCertPath: "/machines/foo/server.pem",
+		ServerCertSANs: []string{"foo.example.com"},
+	}, engine.Options{
+		Labels:     []string{"env=test"},
+		DaemonJSON: true,
+	}, Scripts{PostProvision: "/scripts/post.sh"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, sshCmder.Uploads)
+
+	assert.Equal(t, "ubuntu(systemd)", plan.Provisioner)
+	assert.Equal(t, []string{"curl"}, plan.Packages)
+	assert.Equal(t, []string{"/machines/foo/server.pem for [foo.example.com 1.2.3.4 localhost]"}, plan.Certificates)
+	assert.Equal(t, []string{"docker"}, plan.Services)
+	assert.Equal(t, []string{"post-provision: /scripts/post.sh"}, plan.Scripts)
+
+	assert.Contains(t, plan.DockerOptions.EngineOptions, "--storage-driver overlay2")
+	assert.Contains(t, plan.DockerOptions.EngineOptions, "--tlscert /etc/docker/server.pem")
+	assert.Contains(t, plan.Commands, "sudo systemctl -f restart docker")
+
+	upload, ok := recordedUpload(plan, "/etc/systemd/system/docker.service.d/10-machine.conf")
+	assert.True(t, ok)
+	assert.Equal(t, plan.DockerOptions.EngineOptions, string(upload.Content))
+
+	upload, ok = recordedUpload(plan, "/etc/docker/daemon.json")
+	assert.True(t, ok)
+	assert.Contains(t, string(upload.Content), `"env=test"`)
+	assert.Contains(t, string(upload.Content), `"debug": true`)
+
+	_, ok = recordedUpload(plan, "/etc/docker/server-key.pem")
+	assert.True(t, ok)
+}
+
+func TestDryRunProvisionBoot2Docker(t *testing.T) {
+	d := &fakedriver.Driver{MockName: "foo", MockState: state.Running, MockIP: "1.2.3.4"}
+	p := NewBoot2DockerProvisioner(d).(*Boot2DockerProvisioner)
+	p.sshCommander = provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+
+	plan, err := DryRunProvision(p, swarm.Options{}, auth.Options{}, engine.Options{}, Scripts{})
+
+	assert.NoError(t, err)
+	assert.Empty(t, plan.Packages)
+	assert.Contains(t, plan.DockerOptions.EngineOptions, "DOCKER_STORAGE=overlay2")
+
+	for _, cmd := range plan.Commands {
+		if strings.Contains(cmd, "/etc/init.d/docker restart") {
+			return
+		}
+	}
+	t.Fatal("expected the restart of docker to be recorded")
+}
+
+func TestDryRunProvisionBuildRootUploadsOnce(t *testing.T) {
+	d := &fakedriver.Driver{MockName: "foo", MockState: state.Running, MockIP: "1.2.3.4"}
+	p := NewBuildRootProvisioner(d).(*BuildRootProvisioner)
+	p.SSHCommander = provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+
+	plan, err := DryRunProvision(p, swarm.Options{}, auth.Options{}, engine.Options{DaemonJSON: true}, Scripts{})
+
+	assert.NoError(t, err)
+
+	units, configs := 0, 0
+	for _, u := range plan.Uploads {
+		switch u.Dst {
+		case "/lib/systemd/system/docker.service", "/lib/systemd/system/docker.service.new":
+			units++
+		case "/etc/docker/daemon.json":
+			configs++
+		}
+	}
+	assert.Equal(t, 1, units)
+	assert.Equal(t, 1, configs)
+}
+
+func TestDryRunProvisionUnsupported(t *testing.T) {
+	_, err := DryRunProvision(&FakeProvisioner{}, swarm.Options{}, auth.Options{}, engine.Options{}, Scripts{})
+
+	assert.EqualError(t, err, "Error: The fakeprovisioner provisioner does not support dry runs")
+}
@@ -5,6 +5,14 @@ import (
 	"github.com/leoh0/machine/libmachine/engine"
 )
 
+// systemdEngineConfigTemplate is the systemd drop-in which runs dockerd with
+// the options of the machine, executed with an EngineConfigContext.
+const systemdEngineConfigTemplate = `[Service]
+ExecStart=
+ExecStart=/usr/bin/dockerd -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
+Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
+`
+
 type EngineConfigContext struct {
 	DockerPort       int
 	AuthOptions      auth.Options
@@ -1,6 +1,8 @@
 package provision
 
 import (
+	"os"
+
 	"github.com/leoh0/machine/libmachine/auth"
 	"github.com/leoh0/machine/libmachine/drivers"
 	"github.com/leoh0/machine/libmachine/engine"
@@ -27,6 +29,14 @@ func (fp *FakeProvisioner) SSHCommand(args string) (string, error) {
 	return "", nil
 }
 
+func (fp *FakeProvisioner) SSHUpload(content []byte, dst string, mode os.FileMode, owner string) error {
+	return nil
+}
+
+func (fp *FakeProvisioner) SSHStream(args string, logLine func(string)) error {
+	return nil
+}
+
 func (fp *FakeProvisioner) String() string {
 	return "fakeprovisioner"
 }
@@ -63,7 +73,7 @@ func (fp *FakeProvisioner) CompatibleWithHost() bool {
 	return true
 }
 
-func (fp *FakeProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+func (fp *FakeProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) error {
 	return nil
 }
 
@@ -3,6 +3,7 @@ package provision
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"text/template"
 
 	"github.com/leoh0/machine/libmachine/auth"
@@ -32,6 +33,19 @@ func (sshCmder GenericSSHCommander) SSHCommand(args string) (string, error) {
 	return drivers.RunSSHCommandFromDriver(sshCmder.Driver, args)
 }
 
+func (sshCmder GenericSSHCommander) SSHUpload(content []byte, dst string, mode os.FileMode, owner string) error {
+	return uploadFile(sshCmder, sshCmder.Driver, content, dst, mode, owner)
+}
+
+func (sshCmder GenericSSHCommander) SSHStream(args string, logLine func(string)) error {
+	client, err := drivers.GetSSHClientFromDriver(sshCmder.Driver)
+	if err != nil {
+		return err
+	}
+
+	return streamSSHCommand(client, args, logLine)
+}
+
 func (provisioner *GenericProvisioner) Hostname() (string, error) {
 	return provisioner.SSHCommand("hostname")
 }
@@ -111,7 +125,7 @@ DOCKER_OPTS='
 {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}}
 {{ end }}
 '
-{{range .EngineOptions.Env}}export \"{{ printf "%q" . }}\"
+{{range .EngineOptions.Env}}export {{ printf "%q" . }}
 {{end}}
 `
 	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
@@ -122,7 +136,7 @@ DOCKER_OPTS='
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   provisioner.AuthOptions,
-		EngineOptions: provisioner.EngineOptions,
+		EngineOptions: engineFlagOptions(provisioner.EngineOptions),
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -130,6 +144,7 @@ DOCKER_OPTS='
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		DaemonConfig:      newDaemonConfig(provisioner.EngineOptions, defaultDaemonConfigPath),
 	}, nil
 }
 
@@ -0,0 +1,99 @@
+package provision
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sync"
+
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/mcnutils"
+	"github.com/leoh0/machine/libmachine/ssh"
+)
+
+const (
+	preProvisionScript  = "pre-provision"
+	postProvisionScript = "post-provision"
+
+	provisionScriptDir = "/tmp"
+)
+
+// Scripts are the local scripts run as root on the machine: the
+// pre-provision one by Provision before anything else is done, the
+// post-provision one by RunPostProvisionScript once the machine is
+// provisioned. The fields are paths, a script is not run when its path is
+// empty.
+type Scripts struct {
+	PreProvision  string
+	PostProvision string
+}
+
+// RunPostProvisionScript runs the post-provision script of scripts. It is
+// run after ConfigureSwarmMode, so that the machine is in its swarm mode
+// cluster by then.
+func RunPostProvisionScript(p Provisioner, scripts Scripts) error {
+	return runProvisionScript(p, postProvisionScript, scripts.PostProvision)
+}
+
+// runProvisionScript uploads the script at path to the machine and runs it,
+// logging its output as it goes.
+func runProvisionScript(p Provisioner, name, scriptPath string) error {
+	if scriptPath == "" {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(scriptPath)
+	if err != nil {
+		return fmt.Errorf("Error reading the %s script: %s", name, err)
+	}
+
+	// The name is random so that it cannot be replaced by other users of the
+	// machine before it runs.
+	dst := path.Join(provisionScriptDir, fmt.Sprintf("docker-machine-%s-%s", name, mcnutils.TruncateID(mcnutils.GenerateRandomID())))
+	if err := p.SSHUpload(content, dst, 0700, ""); err != nil {
+		return fmt.Errorf("Error uploading the %s script: %s", name, err)
+	}
+
+	log.Infof("Running the %s script %s...", name, scriptPath)
+	if err := p.SSHStream("sudo "+ssh.ShellQuote(dst), func(line string) {
+		log.Infof("[%s] %s", name, line)
+	}); err != nil {
+		return fmt.Errorf("Error running the %s script: %s", name, err)
+	}
+
+	// The script is left on the machine when it fails, to look into it.
+	_, err = p.SSHCommand("sudo rm -f " + ssh.ShellQuote(dst))
+	return err
+}
+
+// streamSSHCommand runs command with client, passing each line of its
+// standard output and error to logLine once it is written.
+func streamSSHCommand(client ssh.Client, command string, logLine func(string)) error {
+	stdout, stderr, err := client.Start(command)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, r := range []io.Reader{stdout, stderr} {
+		wg.Add(1)
+		go func(r io.Reader) {
+			defer wg.Done()
+
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				mu.Lock()
+				logLine(scanner.Text())
+				mu.Unlock()
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	return client.Wait()
+}
@@ -0,0 +1,84 @@
+package provision
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/leoh0/machine/drivers/fakedriver"
+	"github.com/leoh0/machine/libmachine/provision/provisiontest"
+	"github.com/leoh0/machine/libmachine/ssh/sshtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamSSHCommand(t *testing.T) {
+	client := &sshtest.FakeClient{
+		Outputs: map[string]sshtest.CmdResult{
+			"sudo '/tmp/docker-machine-pre-provision'": {Out: "first\nsecond\n"},
+		},
+	}
+
+	lines := []string{}
+	err := streamSSHCommand(client, "sudo '/tmp/docker-machine-pre-provision'", func(line string) {
+		lines = append(lines, line)
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, lines)
+}
+
+func TestStreamSSHCommandError(t *testing.T) {
+	client := &sshtest.FakeClient{
+		Outputs: map[string]sshtest.CmdResult{
+			"false": {Out: "failed\n", Err: errors.New("exit status 1")},
+		},
+	}
+
+	lines := []string{}
+	err := streamSSHCommand(client, "false", func(line string) {
+		lines = append(lines, line)
+	})
+
+	assert.EqualError(t, err, "exit status 1")
+	assert.Equal(t, []string{"failed"}, lines)
+}
+
+func TestRunProvisionScript(t *testing.T) {
+	sshCmder := provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+	p := &fakeProvisioner{GenericProvisioner{
+		SSHCommander: sshCmder,
+		Driver:       &fakedriver.Driver{},
+	}}
+
+	assert.NoError(t, runProvisionScript(p, preProvisionScript, ""))
+	assert.Empty(t, sshCmder.Uploads)
+
+	err := runProvisionScript(p, preProvisionScript, "/does/not/exist")
+	assert.Error(t, err)
+	assert.Empty(t, sshCmder.Uploads)
+}
+
+func TestRunProvisionScriptThroughSSHCommander(t *testing.T) {
+	script, err := ioutil.TempFile("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(script.Name())
+	script.WriteString("#!/bin/sh\necho hello\n")
+	script.Close()
+
+	recorder := &RecordingSSHCommander{}
+	p := &fakeProvisioner{GenericProvisioner{
+		SSHCommander: recorder,
+		Driver:       &fakedriver.Driver{},
+	}}
+
+	assert.NoError(t, RunPostProvisionScript(p, Scripts{PostProvision: script.Name()}))
+
+	assert.Len(t, recorder.Uploads, 1)
+	dst := recorder.Uploads[0].Dst
+	assert.Regexp(t, `^/tmp/docker-machine-post-provision-[0-9a-f]{12}$`, dst)
+	assert.Equal(t, os.FileMode(0700), recorder.Uploads[0].Mode)
+	assert.Equal(t, []string{"sudo '" + dst + "'", "sudo rm -f '" + dst + "'"}, recorder.Commands)
+}
@@ -2,6 +2,7 @@ package provision
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/leoh0/machine/libmachine/auth"
 	"github.com/leoh0/machine/libmachine/drivers"
@@ -24,6 +25,14 @@ const (
 type SSHCommander interface {
 	// Short-hand for accessing an SSH command from the driver.
 	SSHCommand(args string) (string, error)
+
+	// SSHUpload installs content at dst with the given mode. owner is
+	// "user[:group]", root when empty.
+	SSHUpload(content []byte, dst string, mode os.FileMode, owner string) error
+
+	// SSHStream runs args like SSHCommand, passing each line of its output
+	// to logLine as soon as it is written.
+	SSHStream(args string, logLine func(string)) error
 }
 
 type Detector interface {
@@ -71,7 +80,9 @@ type Provisioner interface {
 	//     3. Configure the daemon to accept connections over TLS.
 	//     4. Copy the needed certificates to the server and local config dir.
 	//     5. Configure / activate swarm if applicable.
-	Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error
+	// The pre-provision script of scripts runs before the first step. The
+	// post-provision one is left to RunPostProvisionScript.
+	Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) error
 
 	// Perform action on a named service e.g. stop
 	Service(name string, action serviceaction.ServiceAction) error
@@ -1,7 +1,11 @@
 //Package provisiontest provides utilities for testing provisioners
 package provisiontest
 
-import "errors"
+import (
+	"errors"
+	"os"
+	"strings"
+)
 
 //FakeSSHCommanderOptions is intended to create a FakeSSHCommander without actually knowing the underlying sshcommands by passing it to NewSSHCommander
 type FakeSSHCommanderOptions struct {
@@ -13,6 +17,15 @@ type FakeSSHCommanderOptions struct {
 //Extend it when needed
 type FakeSSHCommander struct {
 	Responses map[string]string
+	//Uploads records the files uploaded with SSHUpload by destination
+	Uploads map[string]FakeUpload
+}
+
+//FakeUpload is a file uploaded to a FakeSSHCommander
+type FakeUpload struct {
+	Content []byte
+	Mode    os.FileMode
+	Owner   string
 }
 
 //NewFakeSSHCommander creates a FakeSSHCommander without actually knowing the underlying sshcommands
@@ -37,3 +50,26 @@ func (sshCmder *FakeSSHCommander) SSHCommand(args string) (string, error) {
 	}
 	return response, nil
 }
+
+//SSHUpload is an implementation of provision.SSHCommander.SSHUpload recording the uploaded files
+func (sshCmder *FakeSSHCommander) SSHUpload(content []byte, dst string, mode os.FileMode, owner string) error {
+	if sshCmder.Uploads == nil {
+		sshCmder.Uploads = map[string]FakeUpload{}
+	}
+	sshCmder.Uploads[dst] = FakeUpload{Content: content, Mode: mode, Owner: owner}
+	return nil
+}
+
+//SSHStream is an implementation of provision.SSHCommander.SSHStream passing the lines of the registered response to logLine
+func (sshCmder *FakeSSHCommander) SSHStream(args string, logLine func(string)) error {
+	response, err := sshCmder.SSHCommand(args)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(response, "\n") {
+		if line != "" {
+			logLine(line)
+		}
+	}
+	return nil
+}
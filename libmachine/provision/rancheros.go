@@ -2,6 +2,7 @@ package provision
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -21,15 +22,14 @@ import (
 const (
 	versionsURL  = "http://releases.rancher.com/os/versions.yml"
 	isoURL       = "https://github.com/rancherio/os/releases/download/%s/machine-rancheros.iso"
-	hostnameTmpl = `sudo mkdir -p /var/lib/rancher/conf/cloud-config.d/  
-sudo tee /var/lib/rancher/conf/cloud-config.d/machine-hostname.yml << EOF
-#cloud-config
+	hostnameTmpl = `#cloud-config
 
 hostname: %s
-EOF
 `
 )
 
+var errRancherDaemonJSON = errors.New("Unsupported engine option: RancherOS does not read daemon.json, --engine-daemon-json cannot be used")
+
 func init() {
 	Register("RancherOS", &RegisteredProvisioner{
 		New: NewRancherProvisioner,
@@ -92,7 +92,17 @@ func (provisioner *RancherProvisioner) Package(name string, action pkgaction.Pac
 	return nil
 }
 
-func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+// GenerateDockerOptions refuses to configure the engine with daemon.json,
+// which the system-docker engine of RancherOS does not read.
+func (provisioner *RancherProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	if provisioner.EngineOptions.DaemonJSON {
+		return nil, errRancherDaemonJSON
+	}
+
+	return provisioner.GenericProvisioner.GenerateDockerOptions(dockerPort)
+}
+
+func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) error {
 	log.Debugf("Running RancherOS provisioner on %s", provisioner.Driver.GetMachineName())
 
 	provisioner.SwarmOptions = swarmOptions
@@ -106,6 +116,14 @@ func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, aut
 		return fmt.Errorf("Unsupported storage driver: %s", provisioner.EngineOptions.StorageDriver)
 	}
 
+	if provisioner.EngineOptions.DaemonJSON {
+		return errRancherDaemonJSON
+	}
+
+	if err := runProvisionScript(provisioner, preProvisionScript, scripts.PreProvision); err != nil {
+		return err
+	}
+
 	log.Debugf("Setting hostname %s", provisioner.Driver.GetMachineName())
 	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
 		return err
@@ -150,7 +168,7 @@ func (provisioner *RancherProvisioner) SetHostname(hostname string) error {
 		return err
 	}
 
-	if _, err := provisioner.SSHCommand(fmt.Sprintf(hostnameTmpl, hostname)); err != nil {
+	if err := provisioner.SSHUpload([]byte(fmt.Sprintf(hostnameTmpl, hostname)), "/var/lib/rancher/conf/cloud-config.d/machine-hostname.yml", 0644, ""); err != nil {
 		return err
 	}
 
@@ -19,12 +19,7 @@ import (
 
 var (
 	ErrUnknownYumOsRelease = errors.New("unknown OS for Yum repository")
-	engineConfigTemplate   = `[Service]
-ExecStart=
-ExecStart=/usr/bin/dockerd -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
-Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
-`
-	majorVersionRE = regexp.MustCompile(`^(\d+)(\..*)?`)
+	majorVersionRE         = regexp.MustCompile(`^(\d+)(\..*)?`)
 )
 
 type PackageListInfo struct {
@@ -127,7 +122,7 @@ func (provisioner *RedHatProvisioner) dockerDaemonResponding() bool {
 	return true
 }
 
-func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
@@ -140,6 +135,10 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, auth
 	}
 	provisioner.EngineOptions.StorageDriver = storageDriver
 
+	if err := runProvisionScript(provisioner, preProvisionScript, scripts.PreProvision); err != nil {
+		return err
+	}
+
 	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
 		return err
 	}
@@ -190,7 +189,7 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 
 	// systemd / redhat will not load options if they are on newlines
 	// instead, it just continues with a different set of options; yeah...
-	t, err := template.New("engineConfig").Parse(engineConfigTemplate)
+	t, err := template.New("engineConfig").Parse(systemdEngineConfigTemplate)
 	if err != nil {
 		return nil, err
 	}
@@ -198,7 +197,7 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 	engineConfigContext := EngineConfigContext{
 		DockerPort:       dockerPort,
 		AuthOptions:      provisioner.AuthOptions,
-		EngineOptions:    provisioner.EngineOptions,
+		EngineOptions:    engineFlagOptions(provisioner.EngineOptions),
 		DockerOptionsDir: provisioner.DockerOptionsDir,
 	}
 
@@ -208,5 +207,6 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: daemonOptsDir,
+		DaemonConfig:      newDaemonConfig(provisioner.EngineOptions, defaultDaemonConfigPath),
 	}, nil
 }
@@ -2,6 +2,8 @@ package provision
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/leoh0/machine/libmachine/drivers"
 	"github.com/leoh0/machine/libmachine/log"
@@ -43,3 +45,38 @@ output  : %s`, args, err, output)
 
 	return output, nil
 }
+
+// SSHUpload copies the file without a tty, which would mangle it, and moves
+// it in place with SSHCommand, which has the tty sudo requires.
+func (sshCmder RedHatSSHCommander) SSHUpload(content []byte, dst string, mode os.FileMode, owner string) error {
+	return uploadFile(sshCmder, sshCmder.Driver, content, dst, mode, owner)
+}
+
+// SSHStream runs args with a tty like SSHCommand, so the output of the
+// command is all on its standard output.
+func (sshCmder RedHatSSHCommander) SSHStream(args string, logLine func(string)) error {
+	client, err := drivers.GetSSHClientFromDriver(sshCmder.Driver)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("About to run SSH command:\n%s", args)
+
+	switch c := client.(type) {
+	case *ssh.ExternalClient:
+		c.BaseArgs = append(c.BaseArgs, "-tt")
+	case *ssh.NativeClient:
+		client = ptyClient{c}
+	}
+
+	return streamSSHCommand(client, args, logLine)
+}
+
+// ptyClient is a NativeClient starting the commands with a tty.
+type ptyClient struct {
+	*ssh.NativeClient
+}
+
+func (c ptyClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
+	return c.StartWithPty(command)
+}
@@ -64,7 +64,7 @@ Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   p.AuthOptions,
-		EngineOptions: p.EngineOptions,
+		EngineOptions: engineFlagOptions(p.EngineOptions),
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -72,6 +72,7 @@ Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: p.DaemonOptionsFile,
+		DaemonConfig:      newDaemonConfig(p.EngineOptions, defaultDaemonConfigPath),
 	}, nil
 }
 
@@ -0,0 +1,48 @@
+package provision
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/leoh0/machine/libmachine/drivers"
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/leoh0/machine/libmachine/mcnutils"
+	"github.com/leoh0/machine/libmachine/ssh"
+)
+
+// uploadFile installs content at dst on the machine of d. The file is first
+// copied over scp to the home directory of the SSH user, then moved in place
+// with sudo through p, so that its content never ends up on a command line.
+func uploadFile(p SSHCommander, d drivers.Driver, content []byte, dst string, mode os.FileMode, owner string) error {
+	log.Debugf("Uploading %s ...", dst)
+
+	// The name is unique so that concurrent uploads of the same file do not
+	// install each other's content.
+	tmp := fmt.Sprintf(".machine-upload-%s-%s", path.Base(dst), mcnutils.TruncateID(mcnutils.GenerateRandomID()))
+	if err := drivers.UploadFileFromDriver(d, content, tmp, 0600); err != nil {
+		return err
+	}
+
+	_, err := p.SSHCommand(installCommand(tmp, dst, mode, owner))
+	return err
+}
+
+// installCommand returns the command moving the uploaded file tmp to dst
+// with the given mode and owner, "user[:group]" or root when empty. tmp is
+// removed whether it succeeds or not.
+func installCommand(tmp, dst string, mode os.FileMode, owner string) string {
+	args := []string{"sudo", "install", "-m", fmt.Sprintf("%04o", mode.Perm())}
+	if owner != "" {
+		parts := strings.SplitN(owner, ":", 2)
+		args = append(args, "-o", ssh.ShellQuote(parts[0]))
+		if len(parts) == 2 && parts[1] != "" {
+			args = append(args, "-g", ssh.ShellQuote(parts[1]))
+		}
+	}
+	args = append(args, ssh.ShellQuote(tmp), ssh.ShellQuote(dst))
+
+	return fmt.Sprintf("sudo mkdir -p %s && %s; status=$?; rm -f %s; exit $status",
+		ssh.ShellQuote(path.Dir(dst)), strings.Join(args, " "), ssh.ShellQuote(tmp))
+}
@@ -0,0 +1,36 @@
+package provision
+
+import (
+	"os"
+	"testing"
+
+	"github.com/leoh0/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallCommand(t *testing.T) {
+	cases := []struct {
+		mode     os.FileMode
+		owner    string
+		expected string
+	}{
+		{0644, "", `sudo mkdir -p '/etc/docker' && sudo install -m 0644 '.machine-upload-ca.pem' '/etc/docker/ca.pem'; status=$?; rm -f '.machine-upload-ca.pem'; exit $status`},
+		{0600, "docker", `sudo mkdir -p '/etc/docker' && sudo install -m 0600 -o 'docker' '.machine-upload-ca.pem' '/etc/docker/ca.pem'; status=$?; rm -f '.machine-upload-ca.pem'; exit $status`},
+		{0600, "docker:staff", `sudo mkdir -p '/etc/docker' && sudo install -m 0600 -o 'docker' -g 'staff' '.machine-upload-ca.pem' '/etc/docker/ca.pem'; status=$?; rm -f '.machine-upload-ca.pem'; exit $status`},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, installCommand(".machine-upload-ca.pem", "/etc/docker/ca.pem", c.mode, c.owner))
+	}
+}
+
+func TestUpdateUnitUploadsTheUnit(t *testing.T) {
+	unit := "[Service]\nExecReload=/bin/kill -s HUP $MAINPID\n"
+	sshCmder := provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+	sshCmder.Responses["sudo diff -u /lib/systemd/system/docker.service /lib/systemd/system/docker.service.new || { sudo mv /lib/systemd/system/docker.service.new /lib/systemd/system/docker.service; sudo systemctl -f daemon-reload && sudo systemctl -f enable docker && sudo systemctl -f restart docker; }"] = ""
+
+	err := updateUnit(sshCmder, "docker", unit, "/lib/systemd/system/docker.service")
+
+	assert.NoError(t, err)
+	assert.Equal(t, provisiontest.FakeUpload{Content: []byte(unit), Mode: 0644}, sshCmder.Uploads["/lib/systemd/system/docker.service.new"])
+}
@@ -1,6 +1,7 @@
 package provision
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -17,11 +18,16 @@ import (
 	"github.com/leoh0/machine/libmachine/log"
 	"github.com/leoh0/machine/libmachine/mcnutils"
 	"github.com/leoh0/machine/libmachine/provision/serviceaction"
+	"github.com/leoh0/machine/libmachine/swarm"
 )
 
 type DockerOptions struct {
 	EngineOptions     string
 	EngineOptionsPath string
+	// DaemonConfig is the daemon.json to merge the engine options into,
+	// nil when they are passed as flags or when GenerateDockerOptions
+	// already wrote it.
+	DaemonConfig *DaemonConfig
 }
 
 func installDockerGeneric(p Provisioner, baseURL string) error {
@@ -44,9 +50,12 @@ func makeDockerOptionsDir(p Provisioner) error {
 }
 
 func setRemoteAuthOptions(p Provisioner) auth.Options {
-	dockerDir := p.GetDockerOptionsDir()
-	authOptions := p.GetAuthOptions()
+	return withRemoteCertPaths(p.GetAuthOptions(), p.GetDockerOptionsDir())
+}
 
+// withRemoteCertPaths returns authOptions with the paths of the certificates
+// on the machine, in dockerDir.
+func withRemoteCertPaths(authOptions auth.Options, dockerDir string) auth.Options {
 	// due to windows clients, we cannot use filepath.Join as the paths
 	// will be mucked on the linux hosts
 	authOptions.CaCertRemotePath = path.Join(dockerDir, "ca.pem")
@@ -56,15 +65,15 @@ func setRemoteAuthOptions(p Provisioner) auth.Options {
 	return authOptions
 }
 
-func ConfigureAuth(p Provisioner) error {
+// installCerts generates the server certificate of the machine for its IP
+// address, and copies it to the machine with the CA certificate.
+func installCerts(p Provisioner, authOptions auth.Options, swarmOptions swarm.Options) error {
 	var (
 		err error
 	)
 
 	driver := p.GetDriver()
 	machineName := driver.GetMachineName()
-	authOptions := p.GetAuthOptions()
-	swarmOptions := p.GetSwarmOptions()
 	org := mcnutils.GetUsername() + "." + machineName
 	bits := 2048
 
@@ -99,16 +108,18 @@ func ConfigureAuth(p Provisioner) error {
 
 	// TODO: Switch to passing just authOptions to this func
 	// instead of all these individual fields
-	err = cert.GenerateCert(&cert.Options{
-		Hosts:       hosts,
-		CertFile:    authOptions.ServerCertPath,
-		KeyFile:     authOptions.ServerKeyPath,
-		CAFile:      authOptions.CaCertPath,
-		CAKeyFile:   authOptions.CaPrivateKeyPath,
-		Org:         org,
-		Bits:        bits,
-		SwarmMaster: swarmOptions.Master,
-	})
+	err = cert.GenerateCertWithSigner(&cert.Options{
+		Hosts:        hosts,
+		CertFile:     authOptions.ServerCertPath,
+		KeyFile:      authOptions.ServerKeyPath,
+		CAFile:       authOptions.CaCertPath,
+		CAKeyFile:    authOptions.CaPrivateKeyPath,
+		Org:          org,
+		Bits:         bits,
+		SwarmMaster:  swarmOptions.Master,
+		KeyType:      authOptions.TLSKeyType,
+		ValidityDays: authOptions.CertValidityDays,
+	}, cert.SignerFor(&authOptions))
 
 	if err != nil {
 		return fmt.Errorf("error generating server cert: %s", err)
@@ -139,40 +150,38 @@ func ConfigureAuth(p Provisioner) error {
 
 	log.Info("Copying certs to the remote machine...")
 
-	// printf will choke if we don't pass a format string because of the
-	// dashes, so that's the reason for the '%%s'
-	certTransferCmdFmt := "printf '%%s' '%s' | sudo tee %s"
-
 	// These ones are for Jessie and Mike <3 <3 <3
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(caCert), authOptions.CaCertRemotePath)); err != nil {
+	if err := p.SSHUpload(caCert, authOptions.CaCertRemotePath, 0644, ""); err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverCert), authOptions.ServerCertRemotePath)); err != nil {
+	if err := p.SSHUpload(serverCert, authOptions.ServerCertRemotePath, 0644, ""); err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverKey), authOptions.ServerKeyRemotePath)); err != nil {
+	if err := p.SSHUpload(serverKey, authOptions.ServerKeyRemotePath, 0600, ""); err != nil {
 		return err
 	}
 
-	dockerURL, err := driver.GetURL()
-	if err != nil {
+	return nil
+}
+
+func ConfigureAuth(p Provisioner) error {
+	return configureAuth(p, serviceaction.Start)
+}
+
+// configureAuth is ConfigureAuth, applying the configuration with the given
+// action on the docker service.
+func configureAuth(p Provisioner, action serviceaction.ServiceAction) error {
+	authOptions := p.GetAuthOptions()
+	if err := installCerts(p, authOptions, p.GetSwarmOptions()); err != nil {
 		return err
 	}
-	u, err := url.Parse(dockerURL)
+
+	dockerPort, err := enginePort(p)
 	if err != nil {
 		return err
 	}
-	dockerPort := engine.DefaultPort
-	parts := strings.Split(u.Host, ":")
-	if len(parts) == 2 {
-		dPort, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return err
-		}
-		dockerPort = dPort
-	}
 
 	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
 	if err != nil {
@@ -181,17 +190,60 @@ func ConfigureAuth(p Provisioner) error {
 
 	log.Info("Setting Docker configuration on the remote daemon...")
 
-	if _, err = p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s && printf %%s \"%s\" | sudo tee %s", path.Dir(dkrcfg.EngineOptionsPath), dkrcfg.EngineOptions, dkrcfg.EngineOptionsPath)); err != nil {
+	if err = p.SSHUpload([]byte(dkrcfg.EngineOptions), dkrcfg.EngineOptionsPath, 0644, ""); err != nil {
 		return err
 	}
 
-	if err := p.Service("docker", serviceaction.Start); err != nil {
+	if dkrcfg.DaemonConfig != nil {
+		if err := configureDaemonConfig(p, dkrcfg.DaemonConfig); err != nil {
+			return err
+		}
+	}
+
+	if err := p.Service("docker", action); err != nil {
 		return err
 	}
 
 	return WaitForDocker(p, dockerPort)
 }
 
+// RegenerateServerCert generates a new server certificate for the machine of
+// p like ConfigureAuth, restarting the running engine to use it. Unlike
+// Provision, it leaves the rest of the machine alone.
+func RegenerateServerCert(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	op, ok := p.(optionsProvisioner)
+	if !ok {
+		return fmt.Errorf("Error: The %s provisioner cannot regenerate the server certificate alone", p.String())
+	}
+	op.setOptions(swarmOptions, authOptions, engineOptions)
+
+	return configureAuth(p, serviceaction.Restart)
+}
+
+// enginePort returns the port of the engine in the URL of the machine.
+func enginePort(p Provisioner) (int, error) {
+	dockerURL, err := p.GetDriver().GetURL()
+	if err != nil {
+		return 0, err
+	}
+	u, err := url.Parse(dockerURL)
+	if err != nil {
+		return 0, err
+	}
+
+	dockerPort := engine.DefaultPort
+	parts := strings.Split(u.Host, ":")
+	if len(parts) == 2 {
+		dPort, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, err
+		}
+		dockerPort = dPort
+	}
+
+	return dockerPort, nil
+}
+
 func matchNetstatOut(reDaemonListening, netstatOut string) bool {
 	// TODO: I would really prefer this be a Scanner directly on
 	// the STDOUT of the executed command than to do all the string
@@ -266,7 +318,16 @@ func checkDaemonUp(p Provisioner, dockerPort int) func() bool {
 }
 
 func WaitForDocker(p Provisioner, dockerPort int) error {
-	if err := mcnutils.WaitForSpecific(checkDaemonUp(p, dockerPort), 10, 3*time.Second); err != nil {
+	return WaitForDockerContext(context.Background(), p, dockerPort)
+}
+
+// WaitForDockerContext is WaitForDocker which gives up as soon as the
+// context is done.
+func WaitForDockerContext(ctx context.Context, p Provisioner, dockerPort int) error {
+	if err := mcnutils.WaitForSpecificContext(ctx, checkDaemonUp(p, dockerPort), 10, 3*time.Second); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
 		return NewErrDaemonAvailable(err)
 	}
 
@@ -202,7 +202,7 @@ func (provisioner *fakeProvisioner) Package(name string, action pkgaction.Packag
 	return nil
 }
 
-func (provisioner *fakeProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+func (provisioner *fakeProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, scripts Scripts) error {
 	return nil
 }
 
@@ -282,3 +282,9 @@ func TestDockerClientVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestRegenerateServerCertUnsupported(t *testing.T) {
+	err := RegenerateServerCert(&FakeProvisioner{}, swarm.Options{}, auth.Options{}, engine.Options{})
+
+	assert.EqualError(t, err, "Error: The fakeprovisioner provisioner cannot regenerate the server certificate alone")
+}
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/pkg/term"
 	"github.com/leoh0/machine/libmachine/log"
@@ -33,6 +34,11 @@ type Client interface {
 	// Wait waits for the command started by the Start function to exit. The
 	// returned error follows the same logic as in the exec.Cmd.Wait function.
 	Wait() error
+
+	// Upload copies size bytes of src to the file dst of the remote host,
+	// created with the given mode, over the scp protocol. A relative dst is
+	// in the home directory of the user.
+	Upload(src io.Reader, size int64, dst string, mode os.FileMode) error
 }
 
 type ExternalClient struct {
@@ -47,11 +53,16 @@ type NativeClient struct {
 	Port        int
 	openSession *ssh.Session
 	openClient  *ssh.Client
+	auth        *Auth
 }
 
 type Auth struct {
 	Passwords []string
 	Keys      []string
+
+	// KnownHosts pins the host key of the machine. Host keys are not
+	// checked when it is nil.
+	KnownHosts *KnownHosts
 }
 
 type ClientType string
@@ -70,11 +81,11 @@ var (
 		"-F", "/dev/null",
 		"-o", "ConnectionAttempts=3", // retry 3 times if SSH connection fails
 		"-o", "ConnectTimeout=10", // timeout after 10 seconds
-		"-o", "ControlMaster=no", // disable ssh multiplexing
-		"-o", "ControlPath=none",
 		"-o", "LogLevel=quiet", // suppress "Warning: Permanently added '[localhost]:2022' (ECDSA) to the list of known hosts."
 		"-o", "PasswordAuthentication=no",
 		"-o", "ServerAliveInterval=60", // prevents connection to be dropped if command takes too long
+	}
+	insecureSSHArgs = []string{
 		"-o", "StrictHostKeyChecking=no",
 		"-o", "UserKnownHostsFile=/dev/null",
 	}
@@ -93,6 +104,12 @@ func SetDefaultClient(clientType ClientType) {
 	}
 }
 
+// DefaultClient returns the type of client used when the ssh binary is
+// found.
+func DefaultClient() ClientType {
+	return defaultClientType
+}
+
 func NewClient(user string, host string, port int, auth *Auth) (Client, error) {
 	sshBinaryPath, err := exec.LookPath("ssh")
 	if err != nil {
@@ -125,6 +142,7 @@ func NewNativeClient(user, host string, port int, auth *Auth) (Client, error) {
 		Config:   config,
 		Hostname: host,
 		Port:     port,
+		auth:     auth,
 	}, nil
 }
 
@@ -151,43 +169,149 @@ func NewNativeConfig(user string, auth *Auth) (ssh.ClientConfig, error) {
 		authMethods = append(authMethods, ssh.Password(p))
 	}
 
+	if auth.KnownHosts == nil {
+		return ssh.ClientConfig{
+			User:            user,
+			Auth:            authMethods,
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		}, nil
+	}
+
 	return ssh.ClientConfig{
-		User:            user,
-		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:              user,
+		Auth:              authMethods,
+		HostKeyCallback:   auth.KnownHosts.HostKeyCallback(),
+		HostKeyAlgorithms: auth.KnownHosts.hostKeyAlgorithms(),
 	}, nil
 }
 
-func (client *NativeClient) dialSuccess() bool {
-	conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config)
+// dial connects to the machine. A host key mismatch is returned as is, so
+// that callers do not retry.
+func (client *NativeClient) dial() (*ssh.Client, error) {
+	var hostKeyErr error
+
+	config := client.Config
+	if hostKeyCallback := config.HostKeyCallback; hostKeyCallback != nil {
+		config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKeyErr = hostKeyCallback(hostname, remote, key)
+			return hostKeyErr
+		}
+	}
+
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &config)
+	if hostKeyErr != nil {
+		return nil, hostKeyErr
+	}
+
+	return conn, err
+}
+
+func (client *NativeClient) dialSuccess() (bool, error) {
+	conn, err := client.dial()
+	if _, ok := err.(ErrHostKeyMismatch); ok {
+		return false, err
+	}
 	if err != nil {
 		log.Debugf("Error dialing TCP: %s", err)
-		return false
+		return false, nil
 	}
 	closeConn(conn)
-	return true
+	return true, nil
+}
+
+// dialWhenReady waits for the machine to accept SSH connections, then
+// connects to it.
+func (client *NativeClient) dialWhenReady() (*ssh.Client, error) {
+	if err := mcnutils.WaitForSpecificOrError(client.dialSuccess, 60, 3*time.Second); err != nil {
+		return nil, fmt.Errorf("Error attempting SSH client dial: %s", err)
+	}
+
+	conn, err := client.dial()
+	if err != nil {
+		return nil, fmt.Errorf("Mysterious error dialing TCP for SSH (we already succeeded at least once) : %s", err)
+	}
+
+	return conn, nil
+}
+
+// poolKey identifies the connections a client can share.
+func (client *NativeClient) poolKey() string {
+	return connectionKey(client.Config.User, client.Hostname, client.Port, client.auth)
+}
+
+// connectionKey identifies the connections to a machine which can be
+// shared. Clients with other keys or another pinned host key do not share
+// their connection.
+func connectionKey(user, host string, port int, auth *Auth) string {
+	key := fmt.Sprintf("%s@%s", user, net.JoinHostPort(host, strconv.Itoa(port)))
+	if auth == nil {
+		return key
+	}
+
+	key += fmt.Sprintf(" keys=%q", auth.Keys)
+	if k := auth.KnownHosts; k != nil {
+		key += fmt.Sprintf(" known_hosts=%q alias=%q", k.Path, k.Alias)
+	}
+	return key
 }
 
+// session opens a session on the connection shared by the clients of the
+// machine, or on a new connection if connections are not reused. The
+// connection must be given back to release once the session is over.
 func (client *NativeClient) session(command string) (*ssh.Client, *ssh.Session, error) {
-	if err := mcnutils.WaitFor(client.dialSuccess); err != nil {
-		return nil, nil, fmt.Errorf("Error attempting SSH client dial: %s", err)
+	if !connectionReuse {
+		conn, err := client.dialWhenReady()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		session, err := conn.NewSession()
+		if err != nil {
+			closeConn(conn)
+			return nil, nil, err
+		}
+
+		return conn, session, nil
 	}
 
-	conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config)
+	key := client.poolKey()
+
+	conn, err := defaultPool.get(key, client.dialWhenReady)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Mysterious error dialing TCP for SSH (we already succeeded at least once) : %s", err)
+		return nil, nil, err
 	}
+
 	session, err := conn.NewSession()
+	if err != nil {
+		// The connection was lost since it was last used, reconnect.
+		log.Debugf("Reconnecting to %s: %s", key, err)
+		defaultPool.drop(key, conn)
 
-	return conn, session, err
+		conn, err = defaultPool.get(key, client.dialWhenReady)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		session, err = conn.NewSession()
+		if err != nil {
+			defaultPool.drop(key, conn)
+			return nil, nil, err
+		}
+	}
+
+	return conn, session, nil
+}
+
+func (client *NativeClient) release(conn *ssh.Client) {
+	defaultPool.release(conn)
 }
 
 func (client *NativeClient) Output(command string) (string, error) {
 	conn, session, err := client.session(command)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
-	defer closeConn(conn)
+	defer client.release(conn)
 	defer session.Close()
 
 	output, err := session.CombinedOutput(command)
@@ -198,16 +322,27 @@ func (client *NativeClient) Output(command string) (string, error) {
 func (client *NativeClient) OutputWithPty(command string) (string, error) {
 	conn, session, err := client.session(command)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
-	defer closeConn(conn)
+	defer client.release(conn)
 	defer session.Close()
 
+	if err := requestPty(session); err != nil {
+		return "", err
+	}
+
+	output, err := session.CombinedOutput(command)
+
+	return string(output), err
+}
+
+// requestPty requests a tty of the size of the local terminal for session.
+func requestPty(session *ssh.Session) error {
 	fd := int(os.Stdout.Fd())
 
 	termWidth, termHeight, err := terminal.GetSize(fd)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	modes := ssh.TerminalModes{
@@ -218,21 +353,33 @@ func (client *NativeClient) OutputWithPty(command string) (string, error) {
 
 	// request tty -- fixes error with hosts that use
 	// "Defaults requiretty" in /etc/sudoers - I'm looking at you RedHat
-	if err := session.RequestPty("xterm", termHeight, termWidth, modes); err != nil {
-		return "", err
-	}
+	return session.RequestPty("xterm", termHeight, termWidth, modes)
+}
 
-	output, err := session.CombinedOutput(command)
+func (client *NativeClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
+	return client.start(command, false)
+}
 
-	return string(output), err
+// StartWithPty is Start with a tty allocated for the command, like
+// OutputWithPty. The standard error is written to the standard output.
+func (client *NativeClient) StartWithPty(command string) (io.ReadCloser, io.ReadCloser, error) {
+	return client.start(command, true)
 }
 
-func (client *NativeClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
+func (client *NativeClient) start(command string, pty bool) (io.ReadCloser, io.ReadCloser, error) {
 	conn, session, err := client.session(command)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if pty {
+		if err := requestPty(session); err != nil {
+			session.Close()
+			client.release(conn)
+			return nil, nil, err
+		}
+	}
+
 	stdout, err := session.StdoutPipe()
 	if err != nil {
 		return nil, nil, err
@@ -258,10 +405,7 @@ func (client *NativeClient) Wait() error {
 
 	_ = client.openSession.Close()
 
-	err = client.openClient.Close()
-	if err != nil {
-		return err
-	}
+	client.release(client.openClient)
 
 	client.openSession = nil
 	client.openClient = nil
@@ -272,7 +416,7 @@ func (client *NativeClient) Shell(args ...string) error {
 	var (
 		termWidth, termHeight int
 	)
-	conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config)
+	conn, err := client.dial()
 	if err != nil {
 		return err
 	}
@@ -337,7 +481,14 @@ func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (
 		BinaryPath: sshBinaryPath,
 	}
 
-	args := append(baseSSHArgs, fmt.Sprintf("%s@%s", user, host))
+	args := append([]string{}, baseSSHArgs...)
+	args = append(args, controlMasterArgs(connectionKey(user, host, port, auth))...)
+	if auth.KnownHosts == nil {
+		args = append(args, insecureSSHArgs...)
+	} else {
+		args = append(args, auth.KnownHosts.externalArgs()...)
+	}
+	args = append(args, fmt.Sprintf("%s@%s", user, host))
 
 	// If no identities are explicitly provided, also look at the identities
 	// offered by ssh-agent
@@ -0,0 +1,240 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/leoh0/machine/libmachine/log"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// CopyWriter receives the files of a copy. Paths are slash separated and
+// relative to the destination, starting with the name of the copied file or
+// directory, as scp places them.
+type CopyWriter interface {
+	Mkdir(path string, mode os.FileMode) error
+	WriteFile(path string, mode os.FileMode, size int64, r io.Reader) error
+}
+
+// CopyTarget returns where the file rel is copied to, like scp does: into
+// dest when it is a directory, as dest otherwise.
+func CopyTarget(dest string, destIsDir bool, rel string) string {
+	if destIsDir {
+		return path.Join(dest, rel)
+	}
+
+	parts := strings.SplitN(rel, "/", 2)
+	if len(parts) == 1 {
+		return path.Clean(dest)
+	}
+	return path.Join(dest, parts[1])
+}
+
+// sftpSession is an SFTP client over a session of the connection of a
+// NativeClient. Copies only need the SFTP subsystem of the SSH server, not
+// an scp binary on the machine.
+type sftpSession struct {
+	*sftp.Client
+	client  *NativeClient
+	conn    *ssh.Client
+	session *ssh.Session
+}
+
+func (client *NativeClient) openSFTP() (*sftpSession, error) {
+	conn, session, err := client.session("sftp")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sftpSession{
+		client:  client,
+		conn:    conn,
+		session: session,
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		s.release()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		s.release()
+		return nil, err
+	}
+
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		s.release()
+		return nil, fmt.Errorf("Error starting the SFTP subsystem: %s", err)
+	}
+
+	if s.Client, err = sftp.NewClientPipe(stdout, stdin); err != nil {
+		s.release()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *sftpSession) Close() error {
+	defer s.release()
+
+	return s.Client.Close()
+}
+
+func (s *sftpSession) release() {
+	s.session.Close()
+	s.client.release(s.conn)
+}
+
+// Stat returns the description of the file p of the remote host.
+func (client *NativeClient) Stat(p string) (os.FileInfo, error) {
+	s, err := client.openSFTP()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	return s.Stat(p)
+}
+
+// SFTPWriter is a CopyWriter writing the files on the remote host over
+// SFTP. It must be closed once all the files are written.
+type SFTPWriter struct {
+	client    *sftp.Client
+	closer    io.Closer
+	dest      string
+	destIsDir bool
+}
+
+// CopyTo returns a writer copying files to dst on the remote host, like
+// "scp -r" does: into dst when it is a directory, as dst otherwise.
+func (client *NativeClient) CopyTo(dst string) (*SFTPWriter, error) {
+	s, err := client.openSFTP()
+	if err != nil {
+		return nil, err
+	}
+
+	return newSFTPWriter(s.Client, s, dst), nil
+}
+
+func newSFTPWriter(client *sftp.Client, closer io.Closer, dst string) *SFTPWriter {
+	info, err := client.Stat(dst)
+
+	return &SFTPWriter{
+		client:    client,
+		closer:    closer,
+		dest:      dst,
+		destIsDir: err == nil && info.IsDir(),
+	}
+}
+
+func (w *SFTPWriter) Mkdir(p string, mode os.FileMode) error {
+	target := CopyTarget(w.dest, w.destIsDir, p)
+	if err := w.client.MkdirAll(target); err != nil {
+		return fmt.Errorf("Error creating %s: %s", target, err)
+	}
+
+	return w.client.Chmod(target, mode.Perm())
+}
+
+func (w *SFTPWriter) WriteFile(p string, mode os.FileMode, size int64, r io.Reader) error {
+	target := CopyTarget(w.dest, w.destIsDir, p)
+	if err := w.client.MkdirAll(path.Dir(target)); err != nil {
+		return fmt.Errorf("Error creating %s: %s", path.Dir(target), err)
+	}
+
+	f, err := w.client.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %s", target, err)
+	}
+
+	// The mode is set before writing, private files are never readable
+	// by others.
+	if err := w.client.Chmod(target, mode.Perm()); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := io.CopyN(f, r, size); err != nil {
+		f.Close()
+		return fmt.Errorf("Error writing %s: %s", target, err)
+	}
+
+	return f.Close()
+}
+
+// Close ends the SFTP session.
+func (w *SFTPWriter) Close() error {
+	return w.closer.Close()
+}
+
+// CopyFrom copies src from the remote host to w. Directories are only
+// copied when recursive is set.
+func (client *NativeClient) CopyFrom(src string, recursive bool, w CopyWriter) error {
+	s, err := client.openSFTP()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return sftpCopyFrom(s.Client, src, recursive, w)
+}
+
+func sftpCopyFrom(client *sftp.Client, src string, recursive bool, w CopyWriter) error {
+	root := path.Clean(src)
+	info, err := client.Stat(root)
+	if err != nil {
+		return fmt.Errorf("Error reading %s: %s", src, err)
+	}
+	if info.IsDir() && !recursive {
+		return fmt.Errorf("%s is a directory", src)
+	}
+
+	base := path.Base(root)
+	prefix := strings.TrimSuffix(root, "/") + "/"
+
+	walker := client.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+
+		p := walker.Path()
+		rel := base
+		if p != root {
+			// The remote host must not write outside of the destination.
+			if !strings.HasPrefix(p, prefix) {
+				return fmt.Errorf("Invalid path %q outside of %s", p, root)
+			}
+			rel = path.Join(base, strings.TrimPrefix(p, prefix))
+		}
+
+		info := walker.Stat()
+		if info.IsDir() {
+			if err := w.Mkdir(rel, info.Mode().Perm()); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			log.Debugf("Skipping %s, which is not a regular file", p)
+			continue
+		}
+
+		f, err := client.Open(p)
+		if err != nil {
+			return fmt.Errorf("Error reading %s: %s", p, err)
+		}
+		err = w.WriteFile(rel, info.Mode().Perm(), info.Size(), io.LimitReader(f, info.Size()))
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
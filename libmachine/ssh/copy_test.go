@@ -0,0 +1,105 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingCopyWriter records the directories and files it receives.
+type recordingCopyWriter struct {
+	dirs  []string
+	files map[string]string
+}
+
+func (w *recordingCopyWriter) Mkdir(p string, mode os.FileMode) error {
+	w.dirs = append(w.dirs, fmt.Sprintf("%s %04o", p, mode))
+	return nil
+}
+
+func (w *recordingCopyWriter) WriteFile(p string, mode os.FileMode, size int64, r io.Reader) error {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if w.files == nil {
+		w.files = map[string]string{}
+	}
+	w.files[fmt.Sprintf("%s %04o", p, mode)] = string(content)
+	return nil
+}
+
+// localSFTPClient returns an SFTP client of the local file system.
+func localSFTPClient(t *testing.T) *sftp.Client {
+	clientConn, serverConn := net.Pipe()
+
+	server, err := sftp.NewServer(serverConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	return client
+}
+
+func TestCopyTarget(t *testing.T) {
+	assert.Equal(t, "/tmp/dest/foo/a.txt", CopyTarget("/tmp/dest", true, "foo/a.txt"))
+	assert.Equal(t, "/tmp/dest/a.txt", CopyTarget("/tmp/dest", false, "foo/a.txt"))
+	assert.Equal(t, "/tmp/dest", CopyTarget("/tmp/dest/", false, "foo"))
+	assert.Equal(t, "/tmp/dest/foo", CopyTarget("/tmp/dest/", true, "foo"))
+}
+
+func TestSFTPCopy(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	client := localSFTPClient(t)
+	dest := filepath.ToSlash(filepath.Join(tmpDir, "dest"))
+	w := newSFTPWriter(client, ioutil.NopCloser(nil), dest)
+
+	assert.NoError(t, w.Mkdir("foo", 0755))
+	assert.NoError(t, w.WriteFile("foo/a.txt", 0644, 5, strings.NewReader("hello")))
+	assert.NoError(t, w.WriteFile("foo/bar/empty", 0600, 0, strings.NewReader("")))
+
+	copied := &recordingCopyWriter{}
+	err = sftpCopyFrom(client, dest, true, copied)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dest 0755", "dest/bar 0755"}, copied.dirs)
+	assert.Equal(t, map[string]string{"dest/a.txt 0644": "hello", "dest/bar/empty 0600": ""}, copied.files)
+}
+
+func TestSFTPCopyFromDirectoryNotRecursive(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	copied := &recordingCopyWriter{}
+	err = sftpCopyFrom(localSFTPClient(t), filepath.ToSlash(tmpDir), false, copied)
+
+	assert.EqualError(t, err, filepath.ToSlash(tmpDir)+" is a directory")
+	assert.Empty(t, copied.dirs)
+}
@@ -0,0 +1,139 @@
+package ssh
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/leoh0/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// Dial opens a connection to addr from the remote host, over the SSH
+// connection shared by the clients of the machine.
+func (client *NativeClient) Dial(network, addr string) (net.Conn, error) {
+	if !connectionReuse {
+		conn, err := client.dialWhenReady()
+		if err != nil {
+			return nil, err
+		}
+		return client.dialFrom(conn, network, addr)
+	}
+
+	key := client.poolKey()
+
+	conn, err := defaultPool.get(key, client.dialWhenReady)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := conn.Dial(network, addr)
+	if _, refused := err.(*ssh.OpenChannelError); err == nil || refused {
+		return client.pooledConn(conn, c, err)
+	}
+
+	// The connection was lost since it was last used, reconnect.
+	log.Debugf("Reconnecting to %s: %s", key, err)
+	defaultPool.drop(key, conn)
+
+	conn, err = defaultPool.get(key, client.dialWhenReady)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.dialFrom(conn, network, addr)
+}
+
+func (client *NativeClient) dialFrom(conn *ssh.Client, network, addr string) (net.Conn, error) {
+	c, err := conn.Dial(network, addr)
+	return client.pooledConn(conn, c, err)
+}
+
+// pooledConn gives conn back to the pool once c is closed.
+func (client *NativeClient) pooledConn(conn *ssh.Client, c net.Conn, err error) (net.Conn, error) {
+	if err != nil {
+		client.release(conn)
+		return nil, err
+	}
+
+	return &releasingConn{Conn: c, release: func() { client.release(conn) }}, nil
+}
+
+type releasingConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *releasingConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// Forward accepts the connections on l and forwards them to addr from the
+// remote host, until ctx is done.
+func (client *NativeClient) Forward(ctx context.Context, l net.Listener, addr string) error {
+	return serve(ctx, l, func(local net.Conn) {
+		remote, err := client.Dial("tcp", addr)
+		if err != nil {
+			log.Warnf("Error forwarding %s to %s: %s", l.Addr(), addr, err)
+			local.Close()
+			return
+		}
+
+		pipe(local, remote)
+	})
+}
+
+// serve handles the connections accepted on l concurrently, until ctx is
+// done.
+func serve(ctx context.Context, l net.Listener, handle func(net.Conn)) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		go handle(c)
+	}
+}
+
+// pipe copies the data between a and b until either is closed, then closes
+// both.
+func pipe(a, b net.Conn) {
+	var once sync.Once
+	closeBoth := func() {
+		a.Close()
+		b.Close()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(a, b)
+		once.Do(closeBoth)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(b, a)
+		once.Do(closeBoth)
+	}()
+	wg.Wait()
+}
@@ -1,21 +1,33 @@
 package ssh
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
+	"strings"
 
 	gossh "golang.org/x/crypto/ssh"
 )
 
+// Types of the SSH keys generated for machines.
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
 var (
 	ErrKeyGeneration     = errors.New("Unable to generate key")
 	ErrValidation        = errors.New("Unable to validate key")
@@ -23,14 +35,54 @@ var (
 	ErrUnableToWriteFile = errors.New("Unable to write file")
 )
 
+// KeyTypes are the types of SSH keys which can be generated.
+var KeyTypes = []string{KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519}
+
 type KeyPair struct {
 	PrivateKey []byte
 	PublicKey  []byte
+
+	// pemType is the type of the PEM block of the private key, RSA
+	// PRIVATE KEY when empty.
+	pemType string
+}
+
+// ValidateKeyType returns an error if SSH keys of keyType cannot be
+// generated. The empty type is RSA.
+func ValidateKeyType(keyType string) error {
+	if keyType == "" {
+		return nil
+	}
+	for _, t := range KeyTypes {
+		if keyType == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown SSH key type %q, expected one of %s", keyType, strings.Join(KeyTypes, ", "))
 }
 
 // NewKeyPair generates a new SSH keypair
 // This will return a private & public key encoded as DER.
 func NewKeyPair() (keyPair *KeyPair, err error) {
+	return NewKeyPairOfType(KeyTypeRSA)
+}
+
+// NewKeyPairOfType generates a new SSH keypair of the given type: RSA 2048,
+// ECDSA P-256 or Ed25519.
+func NewKeyPairOfType(keyType string) (*KeyPair, error) {
+	switch keyType {
+	case "", KeyTypeRSA:
+		return newRSAKeyPair()
+	case KeyTypeECDSA:
+		return newECDSAKeyPair()
+	case KeyTypeEd25519:
+		return newEd25519KeyPair()
+	}
+
+	return nil, ValidateKeyType(keyType)
+}
+
+func newRSAKeyPair() (*KeyPair, error) {
 	priv, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		return nil, ErrKeyGeneration
@@ -53,6 +105,102 @@ func NewKeyPair() (keyPair *KeyPair, err error) {
 	}, nil
 }
 
+func newECDSAKeyPair() (*KeyPair, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, ErrKeyGeneration
+	}
+
+	privDer, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return nil, ErrKeyGeneration
+	}
+
+	pubSSH, err := gossh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		return nil, ErrPublicKey
+	}
+
+	return &KeyPair{
+		PrivateKey: privDer,
+		PublicKey:  gossh.MarshalAuthorizedKey(pubSSH),
+		pemType:    "EC PRIVATE KEY",
+	}, nil
+}
+
+// newEd25519KeyPair generates an Ed25519 key. OpenSSH only reads those in
+// its own format, so the private key is not DER but an openssh-key-v1 blob.
+func newEd25519KeyPair() (*KeyPair, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, ErrKeyGeneration
+	}
+
+	pubSSH, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		return nil, ErrPublicKey
+	}
+
+	privBlob, err := marshalOpenSSHEd25519(pubSSH, pub, priv)
+	if err != nil {
+		return nil, ErrKeyGeneration
+	}
+
+	return &KeyPair{
+		PrivateKey: privBlob,
+		PublicKey:  gossh.MarshalAuthorizedKey(pubSSH),
+		pemType:    "OPENSSH PRIVATE KEY",
+	}, nil
+}
+
+// marshalOpenSSHEd25519 encodes an unencrypted private key in the format
+// described in PROTOCOL.key of OpenSSH.
+func marshalOpenSSHEd25519(pubSSH gossh.PublicKey, pub ed25519.PublicKey, priv ed25519.PrivateKey) ([]byte, error) {
+	var check uint32
+	if err := binary.Read(rand.Reader, binary.BigEndian, &check); err != nil {
+		return nil, err
+	}
+
+	privKeys := struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+		Pad     []byte `ssh:"rest"`
+	}{
+		Check1:  check,
+		Check2:  check,
+		Keytype: gossh.KeyAlgoED25519,
+		Pub:     pub,
+		Priv:    priv,
+	}
+
+	// The private keys are padded to the block size of the cipher, 8 for
+	// none, with the bytes 1, 2, 3...
+	for i := 0; len(gossh.Marshal(privKeys))%8 != 0; i++ {
+		privKeys.Pad = append(privKeys.Pad, byte(i+1))
+	}
+
+	blob := struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       pubSSH.Marshal(),
+		PrivKeyBlock: gossh.Marshal(privKeys),
+	}
+
+	return append([]byte("openssh-key-v1\x00"), gossh.Marshal(blob)...), nil
+}
+
 // WriteToFile writes keypair to files
 func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) error {
 	files := []struct {
@@ -62,7 +210,7 @@ func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) erro
 	}{
 		{
 			File:  privateKeyPath,
-			Value: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Headers: nil, Bytes: kp.PrivateKey}),
+			Value: pem.EncodeToMemory(&pem.Block{Type: kp.privateKeyPEMType(), Headers: nil, Bytes: kp.PrivateKey}),
 		},
 		{
 			File:  publicKeyPath,
@@ -92,6 +240,13 @@ func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) erro
 	return nil
 }
 
+func (kp *KeyPair) privateKeyPEMType() string {
+	if kp.pemType == "" {
+		return "RSA PRIVATE KEY"
+	}
+	return kp.pemType
+}
+
 // Fingerprint calculates the fingerprint of the public key
 func (kp *KeyPair) Fingerprint() string {
 	b, _ := base64.StdEncoding.DecodeString(string(kp.PublicKey))
@@ -105,12 +260,18 @@ func (kp *KeyPair) Fingerprint() string {
 // GenerateSSHKey generates SSH keypair based on path of the private key
 // The public key would be generated to the same path with ".pub" added
 func GenerateSSHKey(path string) error {
+	return GenerateSSHKeyOfType(path, KeyTypeRSA)
+}
+
+// GenerateSSHKeyOfType is GenerateSSHKey for keys of the given type. It
+// leaves an existing key as is, whatever its type.
+func GenerateSSHKeyOfType(path, keyType string) error {
 	if _, err := os.Stat(path); err != nil {
 		if !os.IsNotExist(err) {
 			return fmt.Errorf("Desired directory for SSH keys does not exist: %s", err)
 		}
 
-		kp, err := NewKeyPair()
+		kp, err := NewKeyPairOfType(keyType)
 		if err != nil {
 			return fmt.Errorf("Error generating key pair: %s", err)
 		}
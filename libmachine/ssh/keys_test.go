@@ -2,7 +2,12 @@ package ssh
 
 import (
 	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
 )
 
 func TestNewKeyPair(t *testing.T) {
@@ -19,3 +24,41 @@ func TestNewKeyPair(t *testing.T) {
 		t.Fatal("Unable to generate fingerprint")
 	}
 }
+
+func TestGenerateSSHKeyOfType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, keyType := range KeyTypes {
+		path := filepath.Join(dir, "id_"+keyType)
+		if err := GenerateSSHKeyOfType(path, keyType); err != nil {
+			t.Fatal(err)
+		}
+
+		privateKey, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := gossh.ParsePrivateKey(privateKey)
+		if err != nil {
+			t.Fatalf("Unable to parse %s key: %s", keyType, err)
+		}
+
+		publicKey, err := ioutil.ReadFile(path + ".pub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(gossh.MarshalAuthorizedKey(signer.PublicKey())) != string(publicKey) {
+			t.Fatalf("Public key of %s key does not match its private key", keyType)
+		}
+	}
+}
+
+func TestNewKeyPairOfUnknownType(t *testing.T) {
+	if _, err := NewKeyPairOfType("dsa"); err == nil {
+		t.Fatal("Expected an error for an unknown key type")
+	}
+}
@@ -0,0 +1,211 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leoh0/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// KnownHostsFile is the name of the file pinning the SSH host key of a
+// machine, in the store directory of the machine.
+const KnownHostsFile = "known_hosts"
+
+var (
+	knownHostsLock = &sync.Mutex{}
+
+	errHostKeyScanned = errors.New("Host key scanned")
+)
+
+// ErrHostKeyMismatch is returned when a machine offers another SSH host key
+// than the one pinned on the first connection.
+type ErrHostKeyMismatch struct {
+	Alias       string
+	Path        string
+	Fingerprint string
+}
+
+func (e ErrHostKeyMismatch) Error() string {
+	return fmt.Sprintf("The SSH host key of %q (%s) does not match the key pinned in %s. If the machine legitimately got a new key, run `docker-machine ssh-keyscan --reset %s`", e.Alias, e.Fingerprint, e.Path, e.Alias)
+}
+
+// KnownHosts trusts the SSH host key of a machine on first use: the key is
+// recorded on the first connection, and the next connections fail if the
+// machine offers another key.
+//
+// The key is recorded under the name of the machine rather than its address,
+// which can change when the machine restarts.
+type KnownHosts struct {
+	Path  string
+	Alias string
+}
+
+// NewKnownHosts returns the KnownHosts of the machine named alias, pinning
+// its key in dir, the store directory of the machine.
+func NewKnownHosts(dir, alias string) *KnownHosts {
+	return &KnownHosts{
+		Path:  filepath.Join(dir, KnownHostsFile),
+		Alias: alias,
+	}
+}
+
+// Keys returns the pinned host keys, none before the first connection.
+func (k *KnownHosts) Keys() ([]ssh.PublicKey, error) {
+	data, err := ioutil.ReadFile(k.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []ssh.PublicKey
+	for len(bytes.TrimSpace(data)) > 0 {
+		_, _, key, _, rest, err := ssh.ParseKnownHosts(data)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %s", k.Path, err)
+		}
+		keys = append(keys, key)
+		data = rest
+	}
+
+	return keys, nil
+}
+
+// Pin records key as a host key of the machine.
+func (k *KnownHosts) Pin(key ssh.PublicKey) error {
+	f, err := os.OpenFile(k.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{k.Alias}, key))
+	return err
+}
+
+// Reset forgets the pinned host keys, so that the next connection pins the
+// new key of the machine.
+func (k *KnownHosts) Reset() error {
+	if err := os.Remove(k.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// HostKeyCallback pins the key of the machine on the first connection and
+// checks it on the next ones.
+func (k *KnownHosts) HostKeyCallback() ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsLock.Lock()
+		defer knownHostsLock.Unlock()
+
+		keys, err := k.Keys()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			log.Debugf("Pinning SSH host key %s of %q in %s", ssh.FingerprintSHA256(key), k.Alias, k.Path)
+			return k.Pin(key)
+		}
+
+		for _, pinned := range keys {
+			if bytes.Equal(pinned.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+
+		return ErrHostKeyMismatch{
+			Alias:       k.Alias,
+			Path:        k.Path,
+			Fingerprint: ssh.FingerprintSHA256(key),
+		}
+	}
+}
+
+// Scan connects to the machine, without authenticating, to get its host key.
+// The key is pinned if none is yet, or checked against the pinned keys.
+func (k *KnownHosts) Scan(host string, port int) (ssh.PublicKey, error) {
+	var scanned ssh.PublicKey
+
+	config := &ssh.ClientConfig{
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			scanned = key
+			return errHostKeyScanned
+		},
+		HostKeyAlgorithms: k.hostKeyAlgorithms(),
+		Timeout:           10 * time.Second,
+	}
+
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)), config)
+	if err == nil {
+		closeConn(conn)
+	}
+	if scanned == nil {
+		return nil, fmt.Errorf("Error getting the SSH host key: %s", err)
+	}
+
+	return scanned, k.HostKeyCallback()(host, nil, scanned)
+}
+
+// Fingerprint returns the SHA256 fingerprint of key, as printed by OpenSSH.
+func Fingerprint(key ssh.PublicKey) string {
+	return ssh.FingerprintSHA256(key)
+}
+
+// hostKeyAlgorithms restricts the native client to the types of the pinned
+// keys, which the machine could otherwise offer another key of.
+func (k *KnownHosts) hostKeyAlgorithms() []string {
+	keys, err := k.Keys()
+	if err != nil {
+		return nil
+	}
+
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if !seen[key.Type()] {
+			seen[key.Type()] = true
+			algorithms = append(algorithms, key.Type())
+		}
+	}
+
+	return algorithms
+}
+
+// externalArgs makes the ssh binary pin the key in the same file. It adds
+// the key on the first connection, and refuses another key afterwards.
+func (k *KnownHosts) externalArgs() []string {
+	strictHostKeyChecking := "no"
+	if keys, err := k.Keys(); err == nil && len(keys) > 0 {
+		strictHostKeyChecking = "yes"
+	}
+
+	return []string{
+		"-o", "StrictHostKeyChecking=" + strictHostKeyChecking,
+		"-o", "UserKnownHostsFile=" + sshOptionValue(k.Path),
+		"-o", "HostKeyAlias=" + sshOptionValue(k.Alias),
+	}
+}
+
+// sshOptionValue returns v unescaped for an ssh -o option, keeping the
+// backslashes of Windows paths. ssh splits option values on whitespace, so
+// a value with spaces is put in double quotes.
+func sshOptionValue(v string) string {
+	if strings.ContainsAny(v, " \t") {
+		return `"` + v + `"`
+	}
+	return v
+}
@@ -0,0 +1,129 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestKnownHosts(t *testing.T) (*KnownHosts, func()) {
+	dir, err := ioutil.TempDir("", "known-hosts")
+	assert.NoError(t, err)
+
+	return NewKnownHosts(dir, "default"), func() { os.RemoveAll(dir) }
+}
+
+func newTestHostKey(t *testing.T) ssh.Signer {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	assert.NoError(t, err)
+
+	return signer
+}
+
+func TestHostKeyCallbackPinsOnFirstUse(t *testing.T) {
+	knownHosts, cleanup := newTestKnownHosts(t)
+	defer cleanup()
+
+	key := newTestHostKey(t).PublicKey()
+	callback := knownHosts.HostKeyCallback()
+
+	assert.NoError(t, callback("127.0.0.1:2222", nil, key))
+	assert.NoError(t, callback("127.0.0.1:3333", nil, key))
+
+	keys, err := knownHosts.Keys()
+	assert.NoError(t, err)
+	assert.Equal(t, []ssh.PublicKey{key}, keys)
+	assert.Equal(t, []string{ssh.KeyAlgoED25519}, knownHosts.hostKeyAlgorithms())
+}
+
+func TestHostKeyCallbackRefusesAnotherKey(t *testing.T) {
+	knownHosts, cleanup := newTestKnownHosts(t)
+	defer cleanup()
+
+	pinned := newTestHostKey(t).PublicKey()
+	other := newTestHostKey(t).PublicKey()
+	assert.NoError(t, knownHosts.Pin(pinned))
+
+	err := knownHosts.HostKeyCallback()("127.0.0.1:2222", nil, other)
+
+	assert.Equal(t, ErrHostKeyMismatch{
+		Alias:       "default",
+		Path:        knownHosts.Path,
+		Fingerprint: Fingerprint(other),
+	}, err)
+}
+
+func TestKnownHostsReset(t *testing.T) {
+	knownHosts, cleanup := newTestKnownHosts(t)
+	defer cleanup()
+
+	assert.NoError(t, knownHosts.Pin(newTestHostKey(t).PublicKey()))
+	assert.NoError(t, knownHosts.Reset())
+	assert.NoError(t, knownHosts.Reset())
+
+	keys, err := knownHosts.Keys()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestKnownHostsExternalArgs(t *testing.T) {
+	knownHosts, cleanup := newTestKnownHosts(t)
+	defer cleanup()
+
+	path := filepath.Join(filepath.Dir(knownHosts.Path), "known_hosts")
+
+	assert.Equal(t, []string{
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=" + path,
+		"-o", "HostKeyAlias=default",
+	}, knownHosts.externalArgs())
+
+	assert.NoError(t, knownHosts.Pin(newTestHostKey(t).PublicKey()))
+
+	assert.Equal(t, "StrictHostKeyChecking=yes", knownHosts.externalArgs()[1])
+}
+
+func TestKnownHostsExternalArgsPaths(t *testing.T) {
+	withSpaces := &KnownHosts{Path: "/Users/John Doe/.docker/machine/machines/my machine/known_hosts", Alias: "my machine"}
+	assert.Equal(t, []string{
+		"-o", `UserKnownHostsFile="/Users/John Doe/.docker/machine/machines/my machine/known_hosts"`,
+		"-o", `HostKeyAlias="my machine"`,
+	}, withSpaces.externalArgs()[2:])
+
+	withBackslashes := &KnownHosts{Path: `C:\Users\docker\.docker\machine\machines\default\known_hosts`, Alias: "default"}
+	assert.Equal(t, []string{
+		"-o", `UserKnownHostsFile=C:\Users\docker\.docker\machine\machines\default\known_hosts`,
+		"-o", "HostKeyAlias=default",
+	}, withBackslashes.externalArgs()[2:])
+}
+
+func TestKnownHostsScan(t *testing.T) {
+	knownHosts, cleanup := newTestKnownHosts(t)
+	defer cleanup()
+
+	server := newTestSSHServer(t)
+	defer server.Close()
+
+	key, err := knownHosts.Scan("127.0.0.1", server.port())
+	assert.NoError(t, err)
+	assert.Equal(t, server.hostKey.PublicKey(), key)
+
+	keys, err := knownHosts.Keys()
+	assert.NoError(t, err)
+	assert.Equal(t, []ssh.PublicKey{server.hostKey.PublicKey()}, keys)
+
+	assert.NoError(t, knownHosts.Reset())
+	assert.NoError(t, knownHosts.Pin(newTestHostKey(t).PublicKey()))
+
+	_, err = knownHosts.Scan("127.0.0.1", server.port())
+	assert.IsType(t, ErrHostKeyMismatch{}, err)
+}
@@ -0,0 +1,232 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/leoh0/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// controlPersist is how long, in seconds, the master of the external
+	// client waits for another session once the last one is done.
+	controlPersist = 10
+)
+
+var (
+	connectionReuse   = true
+	keepAliveInterval = 30 * time.Second
+	defaultPool       = newConnPool()
+	controlSockets    = &controlDir{}
+)
+
+// SetConnectionReuse sets whether the commands run on a machine share one
+// SSH connection. The native client keeps the connection open until
+// CloseConnections is called, and the external client multiplexes its
+// sessions with ControlMaster.
+func SetConnectionReuse(enabled bool) {
+	connectionReuse = enabled
+}
+
+// CloseConnections closes the SSH connections kept open by the native
+// client, and removes the control sockets of the external client.
+func CloseConnections() {
+	defaultPool.closeAll()
+	controlSockets.remove()
+}
+
+// connPool keeps one authenticated connection per machine. The connections
+// are kept alive, and dropped once they fail.
+type connPool struct {
+	sync.Mutex
+	entries map[string]*poolEntry
+}
+
+type poolEntry struct {
+	sync.Mutex
+	conn *ssh.Client
+}
+
+func newConnPool() *connPool {
+	return &connPool{
+		entries: map[string]*poolEntry{},
+	}
+}
+
+// get returns the connection for key, calling dial if there is none yet.
+// Dialing only blocks the callers of the same key.
+func (p *connPool) get(key string, dial func() (*ssh.Client, error)) (*ssh.Client, error) {
+	p.Lock()
+	entry, ok := p.entries[key]
+	if !ok {
+		entry = &poolEntry{}
+		p.entries[key] = entry
+	}
+	p.Unlock()
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	if entry.conn != nil {
+		return entry.conn, nil
+	}
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	entry.conn = conn
+	go p.keepAlive(key, conn)
+
+	return conn, nil
+}
+
+// drop closes conn and forgets it, so that the next get for key dials again.
+func (p *connPool) drop(key string, conn *ssh.Client) {
+	p.Lock()
+	entry, ok := p.entries[key]
+	p.Unlock()
+
+	if ok {
+		entry.Lock()
+		if entry.conn == conn {
+			entry.conn = nil
+		}
+		entry.Unlock()
+	}
+
+	closeConn(conn)
+}
+
+// release closes conn, unless it is kept in the pool.
+func (p *connPool) release(conn *ssh.Client) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, entry := range p.entries {
+		entry.Lock()
+		pooled := entry.conn == conn
+		entry.Unlock()
+
+		if pooled {
+			return
+		}
+	}
+
+	closeConn(conn)
+}
+
+func (p *connPool) keepAlive(key string, conn *ssh.Client) {
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, _, err := conn.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+			log.Debugf("Dropping SSH connection to %s: %s", key, err)
+			p.drop(key, conn)
+			return
+		}
+	}
+}
+
+func (p *connPool) closeAll() {
+	p.Lock()
+	defer p.Unlock()
+
+	for key, entry := range p.entries {
+		entry.Lock()
+		if entry.conn != nil {
+			closeConn(entry.conn)
+		}
+		entry.Unlock()
+		delete(p.entries, key)
+	}
+}
+
+// controlDir is the directory holding the control sockets of the ssh
+// masters started by this process.
+type controlDir struct {
+	sync.Mutex
+	path string
+}
+
+// get returns the directory, creating it on first use. It is created anew
+// with a random name and mode 0700, so that no other user can own it or
+// plant a socket in it, unlike a directory at a predictable path in a
+// shared temporary directory.
+func (d *controlDir) get() (string, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.path != "" {
+		return d.path, nil
+	}
+
+	// Unix sockets paths are short, so this cannot live in the store.
+	path, err := ioutil.TempDir("", "docker-machine-ssh-")
+	if err != nil {
+		return "", err
+	}
+
+	d.path = path
+	return path, nil
+}
+
+// remove removes the directory. The masters still running exit once their
+// ControlPersist delay is over.
+func (d *controlDir) remove() {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.path == "" {
+		return
+	}
+
+	if err := os.RemoveAll(d.path); err != nil {
+		log.Debugf("Error removing the SSH control sockets: %s", err)
+	}
+	d.path = ""
+}
+
+// controlMasterArgs makes the ssh binary share one connection between its
+// sessions to a machine with the given connection key. The first ssh
+// process is the master, it keeps the connection open for a few seconds
+// after the last session so that the next command of this process reuses
+// it. The control sockets are private to this process and to the key, so
+// the connection is never shared with clients which did not check the same
+// host key.
+func controlMasterArgs(key string) []string {
+	noControlMaster := []string{
+		"-o", "ControlMaster=no", // disable ssh multiplexing
+		"-o", "ControlPath=none",
+	}
+
+	// Windows builds of OpenSSH do not support multiplexing.
+	if !connectionReuse || runtime.GOOS == "windows" {
+		return noControlMaster
+	}
+
+	dir, err := controlSockets.get()
+	if err != nil {
+		log.Debugf("Not multiplexing SSH connections: %s", err)
+		return noControlMaster
+	}
+
+	// The key holds paths, which do not fit in a socket path.
+	sum := sha256.Sum256([]byte(key))
+
+	return []string{
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(dir, hex.EncodeToString(sum[:8])),
+		"-o", fmt.Sprintf("ControlPersist=%d", controlPersist),
+	}
+}
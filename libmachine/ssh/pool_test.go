@@ -0,0 +1,186 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer runs every command successfully, printing "ok".
+type testSSHServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+	accepted int32
+}
+
+func newTestSSHServer(t *testing.T) *testSSHServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	server := &testSSHServer{
+		listener: listener,
+		hostKey:  newTestHostKey(t),
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(server.hostKey)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&server.accepted, 1)
+			go server.serve(conn, config)
+		}
+	}()
+
+	return server
+}
+
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range channelRequests {
+				req.Reply(req.Type == "exec", nil)
+				if req.Type == "exec" {
+					channel.Write([]byte("ok"))
+					status := make([]byte, 4)
+					binary.BigEndian.PutUint32(status, 0)
+					channel.SendRequest("exit-status", false, status)
+					channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+func (s *testSSHServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testSSHServer) Close() error {
+	return s.listener.Close()
+}
+
+func newTestNativeClient(t *testing.T, server *testSSHServer) Client {
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port(), &Auth{})
+	assert.NoError(t, err)
+	return client
+}
+
+func TestNativeClientReusesConnection(t *testing.T) {
+	defer CloseConnections()
+
+	server := newTestSSHServer(t)
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		output, err := newTestNativeClient(t, server).Output("true")
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", output)
+	}
+
+	// One connection to check that SSH is up, and the shared one.
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.accepted))
+}
+
+func TestNativeClientReconnects(t *testing.T) {
+	defer CloseConnections()
+
+	server := newTestSSHServer(t)
+	defer server.Close()
+
+	client := newTestNativeClient(t, server)
+
+	_, err := client.Output("true")
+	assert.NoError(t, err)
+
+	CloseConnections()
+
+	output, err := client.Output("true")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", output)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&server.accepted))
+}
+
+func TestNativeClientWithoutConnectionReuse(t *testing.T) {
+	SetConnectionReuse(false)
+	defer SetConnectionReuse(true)
+
+	server := newTestSSHServer(t)
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := newTestNativeClient(t, server).Output("true")
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(4), atomic.LoadInt32(&server.accepted))
+}
+
+func TestPoolKeyIncludesAuth(t *testing.T) {
+	newClient := func(auth *Auth) *NativeClient {
+		client, err := NewNativeClient("docker", "127.0.0.1", 22, auth)
+		assert.NoError(t, err)
+		return client.(*NativeClient)
+	}
+
+	plain := newClient(&Auth{})
+	pinned := newClient(&Auth{KnownHosts: &KnownHosts{Path: "/store/known_hosts", Alias: "default"}})
+	otherAlias := newClient(&Auth{KnownHosts: &KnownHosts{Path: "/store/known_hosts", Alias: "other"}})
+
+	assert.Equal(t, plain.poolKey(), newClient(&Auth{}).poolKey())
+	assert.NotEqual(t, plain.poolKey(), pinned.poolKey())
+	assert.NotEqual(t, pinned.poolKey(), otherAlias.poolKey())
+}
+
+func TestControlMasterArgs(t *testing.T) {
+	SetConnectionReuse(false)
+	defer SetConnectionReuse(true)
+
+	assert.Equal(t, []string{"-o", "ControlMaster=no", "-o", "ControlPath=none"}, controlMasterArgs("docker@127.0.0.1:22"))
+}
+
+func TestControlMasterArgsPrivateDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("OpenSSH for Windows does not support multiplexing")
+	}
+	defer CloseConnections()
+
+	args := controlMasterArgs("docker@127.0.0.1:22")
+	assert.Equal(t, "ControlPersist=10", args[5])
+
+	controlPath := strings.TrimPrefix(args[3], "ControlPath=")
+	fi, err := os.Lstat(filepath.Dir(controlPath))
+	assert.NoError(t, err)
+	assert.True(t, fi.IsDir())
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+
+	otherArgs := controlMasterArgs(`docker@127.0.0.1:22 keys=["/store/id_rsa"]`)
+	assert.Equal(t, filepath.Dir(controlPath), filepath.Dir(strings.TrimPrefix(otherArgs[3], "ControlPath=")))
+	assert.NotEqual(t, args[3], otherArgs[3])
+
+	CloseConnections()
+	_, err = os.Stat(filepath.Dir(controlPath))
+	assert.True(t, os.IsNotExist(err))
+}
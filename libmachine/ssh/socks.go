@@ -0,0 +1,141 @@
+package ssh
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/leoh0/machine/libmachine/log"
+)
+
+// SOCKS5 protocol values, see RFC 1928.
+const (
+	socksVersion         = 5
+	socksNoAuth          = 0
+	socksNoAcceptable    = 0xff
+	socksConnect         = 1
+	socksAddrIPv4        = 1
+	socksAddrDomain      = 3
+	socksAddrIPv6        = 4
+	socksSucceeded       = 0
+	socksHostRefused     = 5
+	socksCmdUnsupported  = 7
+	socksAddrUnsupported = 8
+)
+
+// ServeSOCKS accepts SOCKS5 connections on l and opens the requested
+// connections from the remote host, until ctx is done. Only the CONNECT
+// command without authentication is supported.
+func (client *NativeClient) ServeSOCKS(ctx context.Context, l net.Listener) error {
+	return serve(ctx, l, func(c net.Conn) {
+		handleSOCKS(c, client.Dial)
+	})
+}
+
+func handleSOCKS(c net.Conn, dial func(network, addr string) (net.Conn, error)) {
+	addr, err := socksHandshake(c)
+	if err != nil {
+		log.Debugf("Error in the SOCKS handshake: %s", err)
+		c.Close()
+		return
+	}
+
+	remote, err := dial("tcp", addr)
+	if err != nil {
+		log.Debugf("Error connecting to %s: %s", addr, err)
+		socksReply(c, socksHostRefused)
+		c.Close()
+		return
+	}
+
+	if err := socksReply(c, socksSucceeded); err != nil {
+		c.Close()
+		remote.Close()
+		return
+	}
+
+	pipe(c, remote)
+}
+
+// socksHandshake negotiates the authentication method and reads the CONNECT
+// request, and returns the address to connect to.
+func socksHandshake(c io.ReadWriter) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c, header); err != nil {
+		return "", err
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("Unsupported SOCKS version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(c, methods); err != nil {
+		return "", err
+	}
+
+	method := byte(socksNoAcceptable)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := c.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+	if method == socksNoAcceptable {
+		return "", errors.New("No supported SOCKS authentication method")
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(c, request); err != nil {
+		return "", err
+	}
+	if request[1] != socksConnect {
+		socksReply(c, socksCmdUnsupported)
+		return "", fmt.Errorf("Unsupported SOCKS command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		ip := make([]byte, net.IPv4len)
+		if request[3] == socksAddrIPv6 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(c, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socksAddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(c, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(c, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		socksReply(c, socksAddrUnsupported)
+		return "", fmt.Errorf("Unsupported SOCKS address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(c, port); err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksReply answers a CONNECT request. The bound address is not known
+// since the connection is opened from the remote host.
+func socksReply(c io.Writer, status byte) error {
+	_, err := c.Write([]byte{socksVersion, status, 0, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,108 @@
+package ssh
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDial connects the SOCKS clients to an echo server and records the
+// addresses they asked for.
+func fakeDial(addrs chan<- string) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		addrs <- addr
+		local, remote := net.Pipe()
+		go io.Copy(remote, remote)
+		return local, nil
+	}
+}
+
+func TestSOCKSConnect(t *testing.T) {
+	cases := []struct {
+		request  []byte
+		expected string
+	}{
+		{[]byte{5, 1, 0, 1, 10, 0, 0, 5, 0, 80}, "10.0.0.5:80"},
+		{append(append([]byte{5, 1, 0, 3, 9}, "localhost"...), 0x1f, 0x90), "localhost:8080"},
+		{[]byte{5, 1, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 22}, "[::1]:22"},
+	}
+
+	for _, c := range cases {
+		client, server := net.Pipe()
+		addrs := make(chan string, 1)
+		go handleSOCKS(server, fakeDial(addrs))
+
+		_, err := client.Write([]byte{5, 1, 0})
+		assert.NoError(t, err)
+		method := make([]byte, 2)
+		_, err = io.ReadFull(client, method)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{5, 0}, method)
+
+		_, err = client.Write(c.request)
+		assert.NoError(t, err)
+		reply := make([]byte, 10)
+		_, err = io.ReadFull(client, reply)
+		assert.NoError(t, err)
+		assert.Equal(t, byte(socksSucceeded), reply[1])
+		assert.Equal(t, c.expected, <-addrs)
+
+		_, err = client.Write([]byte("ping"))
+		assert.NoError(t, err)
+		echo := make([]byte, 4)
+		_, err = io.ReadFull(client, echo)
+		assert.NoError(t, err)
+		assert.Equal(t, "ping", string(echo))
+
+		client.Close()
+	}
+}
+
+func TestSOCKSRejectsAuthentication(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go handleSOCKS(server, fakeDial(make(chan string, 1)))
+
+	_, err := client.Write([]byte{5, 1, 2})
+	assert.NoError(t, err)
+	method := make([]byte, 2)
+	_, err = io.ReadFull(client, method)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{5, socksNoAcceptable}, method)
+}
+
+func TestSOCKSRejectsBind(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go handleSOCKS(server, fakeDial(make(chan string, 1)))
+
+	_, err := client.Write([]byte{5, 1, 0})
+	assert.NoError(t, err)
+	method := make([]byte, 2)
+	_, err = io.ReadFull(client, method)
+	assert.NoError(t, err)
+
+	// The rest of the request is not read once the command is rejected.
+	go client.Write([]byte{5, 2, 0, 1, 10, 0, 0, 5, 0, 80})
+	reply := make([]byte, 10)
+	_, err = io.ReadFull(client, reply)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(socksCmdUnsupported), reply[1])
+}
+
+func TestServeStopsWhenDone(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- serve(ctx, l, func(c net.Conn) { c.Close() })
+	}()
+
+	cancel()
+	assert.NoError(t, <-done)
+}
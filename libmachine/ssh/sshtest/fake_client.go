@@ -1,6 +1,11 @@
 package sshtest
 
-import "io"
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
 
 type CmdResult struct {
 	Out string
@@ -10,6 +15,14 @@ type CmdResult struct {
 type FakeClient struct {
 	ActivatedShell []string
 	Outputs        map[string]CmdResult
+	Uploads        map[string]Upload
+	waitErr        error
+}
+
+// Upload is a file given to FakeClient.Upload.
+type Upload struct {
+	Content []byte
+	Mode    os.FileMode
 }
 
 func (fsc *FakeClient) Output(command string) (string, error) {
@@ -22,10 +35,27 @@ func (fsc *FakeClient) Shell(args ...string) error {
 	return nil
 }
 
+// Start gives the output of command as its standard output, the error is
+// returned by Wait.
 func (fsc *FakeClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
-	return nil, nil, nil
+	outerr := fsc.Outputs[command]
+	fsc.waitErr = outerr.Err
+	return ioutil.NopCloser(strings.NewReader(outerr.Out)), ioutil.NopCloser(strings.NewReader("")), nil
 }
 
 func (fsc *FakeClient) Wait() error {
+	return fsc.waitErr
+}
+
+func (fsc *FakeClient) Upload(src io.Reader, size int64, dst string, mode os.FileMode) error {
+	content, err := ioutil.ReadAll(io.LimitReader(src, size))
+	if err != nil {
+		return err
+	}
+
+	if fsc.Uploads == nil {
+		fsc.Uploads = map[string]Upload{}
+	}
+	fsc.Uploads[dst] = Upload{Content: content, Mode: mode}
 	return nil
 }
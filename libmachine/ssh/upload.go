@@ -0,0 +1,144 @@
+package ssh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// scpSinkCommand returns the remote command receiving a file sent with the
+// scp protocol to dst.
+func scpSinkCommand(dst string) string {
+	return "scp -t " + ShellQuote(dst)
+}
+
+// ShellQuote quotes s as a single word for a POSIX shell.
+func ShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// scpSend sends a file to a remote "scp -t" through its standard input and
+// output. The content never appears on a command line.
+func scpSend(stdin io.WriteCloser, stdout io.Reader, src io.Reader, size int64, dst string, mode os.FileMode) error {
+	defer stdin.Close()
+
+	acks := bufio.NewReader(stdout)
+	if err := scpAck(acks); err != nil {
+		return err
+	}
+
+	return scpSendFile(stdin, acks, path.Base(dst), mode, size, src)
+}
+
+// scpSendFile sends the file name of the current directory of the remote
+// scp, once it acknowledged the previous message.
+func scpSendFile(stdin io.Writer, acks *bufio.Reader, name string, mode os.FileMode, size int64, src io.Reader) error {
+	if _, err := fmt.Fprintf(stdin, "C%04o %d %s\n", mode.Perm(), size, name); err != nil {
+		return err
+	}
+	if err := scpAck(acks); err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(stdin, src, size); err != nil {
+		return err
+	}
+	if _, err := stdin.Write([]byte{0}); err != nil {
+		return err
+	}
+
+	return scpAck(acks)
+}
+
+// scpAck reads the acknowledgment of the remote scp, a zero byte, or an
+// error message.
+func scpAck(r *bufio.Reader) error {
+	code, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("Error reading the scp acknowledgment: %s", err)
+	}
+	if code == 0 {
+		return nil
+	}
+
+	msg, _ := r.ReadString('\n')
+	msg = strings.TrimSpace(msg)
+	if msg == "" {
+		return errors.New("scp failed")
+	}
+	return fmt.Errorf("scp failed: %s", msg)
+}
+
+func (client *NativeClient) Upload(src io.Reader, size int64, dst string, mode os.FileMode) error {
+	command := scpSinkCommand(dst)
+
+	conn, session, err := client.session(command)
+	if err != nil {
+		return err
+	}
+	defer client.release(conn)
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	var stderr strings.Builder
+	session.Stderr = &stderr
+
+	if err := session.Start(command); err != nil {
+		return err
+	}
+
+	sendErr := scpSend(stdin, stdout, src, size, dst, mode)
+	waitErr := session.Wait()
+	if sendErr != nil {
+		return sendErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("%s: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+func (client *ExternalClient) Upload(src io.Reader, size int64, dst string, mode os.FileMode) error {
+	args := append(append([]string{}, client.BaseArgs...), scpSinkCommand(dst))
+	cmd := getSSHCmd(client.BinaryPath, args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	sendErr := scpSend(stdin, stdout, src, size, dst, mode)
+	waitErr := cmd.Wait()
+	if sendErr != nil {
+		return sendErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("%s: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeScpSink plays the remote "scp -t" end, answering each step with ack,
+// and returns the header and the content it received.
+func fakeScpSink(stdin io.Reader, stdout io.WriteCloser, ack string) (chan string, chan string) {
+	headers := make(chan string, 1)
+	contents := make(chan string, 1)
+
+	go func() {
+		defer stdout.Close()
+		r := bufio.NewReader(stdin)
+
+		io.WriteString(stdout, "\x00")
+		header, _ := r.ReadString('\n')
+		headers <- header
+		io.WriteString(stdout, ack)
+
+		var mode, size int
+		var name string
+		fmt.Sscanf(header, "C%o %d %s", &mode, &size, &name)
+		content := make([]byte, size+1)
+		io.ReadFull(r, content)
+		contents <- string(content)
+		io.WriteString(stdout, "\x00")
+	}()
+
+	return headers, contents
+}
+
+func TestScpSend(t *testing.T) {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	headers, contents := fakeScpSink(stdinReader, stdoutWriter, "\x00")
+
+	err := scpSend(stdinWriter, stdoutReader, strings.NewReader("-----BEGIN CERTIFICATE-----\n'quoted'\n"), 37, "/etc/docker/ca.pem", 0644)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "C0644 37 ca.pem\n", <-headers)
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----\n'quoted'\n\x00", <-contents)
+}
+
+func TestScpSendError(t *testing.T) {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	defer stdoutReader.Close()
+	fakeScpSink(stdinReader, stdoutWriter, "\x01scp: /etc/docker/ca.pem: Permission denied\n")
+
+	err := scpSend(stdinWriter, stdoutReader, strings.NewReader("ca"), 2, "/etc/docker/ca.pem", 0644)
+
+	assert.EqualError(t, err, "scp failed: scp: /etc/docker/ca.pem: Permission denied")
+}
+
+func TestScpSinkCommand(t *testing.T) {
+	assert.Equal(t, `scp -t '/home/docker/it'\''s'`, scpSinkCommand("/home/docker/it's"))
+}
@@ -2,6 +2,13 @@ package swarm
 
 const (
 	DiscoveryServiceEndpoint = "https://discovery-stage.hub.docker.com/v1"
+
+	// Roles of a node in swarm mode.
+	ModeManager = "manager"
+	ModeWorker  = "worker"
+
+	// ModePort is the port the managers of a swarm mode cluster listen on.
+	ModePort = 2377
 )
 
 type Options struct {
@@ -19,4 +26,12 @@ type Options struct {
 	ArbitraryJoinFlags []string
 	Env                []string
 	IsExperimental     bool
+	// Swarm mode, built into the engine, as opposed to the standalone
+	// swarm containers set up by the options above. ModeRole is empty when
+	// the machine is not in swarm mode, and ModeJoin is the address of the
+	// manager to join, empty to create a new cluster.
+	ModeRole         string
+	ModeJoin         string
+	ModeManagerToken string
+	ModeWorkerToken  string
 }